@@ -0,0 +1,143 @@
+// Package afk decides which idle players may be kicked from the hub when it
+// fills up, and in which order.
+package afk
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/queue"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// Criteria that may be listed in a kick order.
+const (
+	// OrderRank kicks players of the lowest rank first.
+	OrderRank = "rank"
+	// OrderIdle kicks players who have been idle the longest first.
+	OrderIdle = "idle"
+)
+
+// DefaultOrder returns the kick order used when none is configured.
+func DefaultOrder() []string {
+	return []string{OrderRank, OrderIdle}
+}
+
+// handler is implemented by player handlers that track ranks and activity.
+type handler interface {
+	Ranks() *session.Ranks
+	Movement() *session.Movement
+}
+
+// Candidate is an online player considered by the AFK policy.
+type Candidate struct {
+	Player   *player.Player
+	Movement *session.Movement
+	Rank     rank.Rank
+	// Idle is how long ago the player was last active.
+	Idle time.Duration
+	// Exempt is set for players who are never kicked for being AFK.
+	Exempt bool
+}
+
+// Policy decides which idle players may be kicked and in which order.
+type Policy struct {
+	order        []string
+	exemptQueued bool
+}
+
+// NewPolicy creates a policy kicking players in the given order, most
+// significant criterion first. Unknown criteria are logged and skipped.
+// Players holding permission.AFKExempt are never kicked, nor are players
+// waiting in a server queue if exemptQueued is set.
+func NewPolicy(log *slog.Logger, order []string, exemptQueued bool) Policy {
+	if len(order) == 0 {
+		order = DefaultOrder()
+	}
+	order = slices.DeleteFunc(slices.Clone(order), func(c string) bool {
+		switch c {
+		case OrderRank, OrderIdle:
+			return false
+		}
+		log.Warn("unknown AFK kick order criterion", "criterion", c)
+
+		return true
+	})
+
+	return Policy{order: order, exemptQueued: exemptQueued}
+}
+
+// Candidates returns every player in the hub with their idle time. Must be
+// called on the world owner.
+func (pol Policy) Candidates(tx *world.Tx, now time.Time) []Candidate {
+	var cands []Candidate
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+		h, ok := p.Handler().(handler)
+		if !ok {
+			continue
+		}
+		cands = append(cands, Candidate{
+			Player:   p,
+			Movement: h.Movement(),
+			Rank:     h.Ranks().HighestRank(),
+			Idle:     now.Sub(h.Movement().LastActive()),
+			Exempt:   pol.exempt(p, h.Ranks()),
+		})
+	}
+
+	return cands
+}
+
+// exempt reports whether p is never kicked for being AFK.
+func (pol Policy) exempt(p *player.Player, ranks *session.Ranks) bool {
+	if ranks.HasPermission(p.XUID(), permission.AFKExempt) {
+		return true
+	}
+	if pol.exemptQueued {
+		if s, _ := queue.QueueManager.Lookup(p.H()); s != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sort orders cands so the player that should be kicked first comes first.
+// Ties are broken by idle time, longest first.
+func (pol Policy) Sort(cands []Candidate) {
+	slices.SortStableFunc(cands, func(a, b Candidate) int {
+		for _, c := range pol.order {
+			var n int
+			switch c {
+			case OrderRank:
+				n = a.Rank.Compare(b.Rank)
+			case OrderIdle:
+				n = compareIdle(a, b)
+			}
+			if n != 0 {
+				return n
+			}
+		}
+
+		return compareIdle(a, b)
+	})
+}
+
+// compareIdle orders the candidate idle the longest first.
+func compareIdle(a, b Candidate) int {
+	switch {
+	case a.Idle > b.Idle:
+		return -1
+	case a.Idle < b.Idle:
+		return 1
+	}
+
+	return 0
+}
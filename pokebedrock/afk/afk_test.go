@@ -0,0 +1,46 @@
+package afk
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+func TestPolicySort(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cands := []Candidate{
+		{Rank: rank.Supporter, Idle: 30 * time.Minute},
+		{Rank: rank.Trainer, Idle: 10 * time.Minute},
+		{Rank: rank.Trainer, Idle: 20 * time.Minute},
+		{Rank: rank.Premium, Idle: 15 * time.Minute},
+	}
+
+	tests := []struct {
+		name  string
+		order []string
+		want  []time.Duration
+	}{
+		{name: "default", order: nil, want: []time.Duration{20, 10, 30, 15}},
+		{name: "idle first", order: []string{OrderIdle, OrderRank}, want: []time.Duration{30, 20, 15, 10}},
+		{name: "unknown skipped", order: []string{"bogus", OrderRank}, want: []time.Duration{20, 10, 30, 15}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted := slices.Clone(cands)
+			NewPolicy(log, tt.order, false).Sort(sorted)
+
+			got := make([]time.Duration, 0, len(sorted))
+			for _, c := range sorted {
+				got = append(got, c.Idle/time.Minute)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("kick order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
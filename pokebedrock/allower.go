@@ -1,100 +1,388 @@
 package pokebedrock
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/netip"
 	"strings"
+	"time"
 
 	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+	"golang.org/x/text/language"
 
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/flood"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/vpn"
 )
 
-// Allower ...
-type Allower struct{}
+// FailPolicy decides whether a login is allowed when one of its checks
+// fails or doesn't finish before the login deadline.
+type FailPolicy string
+
+// FailPolicy constants.
+const (
+	FailOpen   FailPolicy = "allow"
+	FailClosed FailPolicy = "deny"
+)
+
+// ParseFailPolicy parses a fail policy, returning def for an empty string.
+func ParseFailPolicy(s string, def FailPolicy) (FailPolicy, error) {
+	switch p := FailPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return def, nil
+	case FailOpen, FailClosed:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown fail policy %q, expected %q or %q", s, FailOpen, FailClosed)
+	}
+}
+
+// AllowerConfig holds the settings of the login checks.
+type AllowerConfig struct {
+	// Deadline bounds the time spent on all checks of a single login.
+	Deadline time.Duration
+	// VPN, Rank and Infliction are the fail policies of each check. The
+	// rank check only matters for connections flagged as VPNs, where a
+	// linked account is allowed through.
+	VPN        FailPolicy
+	Rank       FailPolicy
+	Infliction FailPolicy
+	// Admit decides whether a player of rank r fits in the hub once every
+	// other check passed, refusing them once ctx is done. Nil admits
+	// everybody.
+	Admit func(ctx context.Context, xuid string, r rank.Rank) bool
+}
+
+// Allower runs the login checks of connecting players: VPN detection, the
+// rank lookup used for the linked account VPN bypass, active bans and the
+// hub capacity. The
+// checks run concurrently under one deadline, and identical in-flight
+// lookups are shared between logins from the same IP or XUID.
+type Allower struct {
+	conf   AllowerConfig
+	lookup lookups
+
+	vpnFlight        util.SingleFlight[string, *vpn.ResponseModel]
+	rankFlight       util.SingleFlight[string, []string]
+	inflictionFlight util.SingleFlight[string, *moderation.ModelResponse]
+}
+
+const (
+	// defaultLoginDeadline is used when no login deadline is configured.
+	defaultLoginDeadline = 4 * time.Second
+	// admitTimeout bounds the capacity check. It isn't part of the login
+	// deadline, which the other checks may have used up.
+	admitTimeout = time.Second
+)
+
+// lookups are the services asked by the login checks.
+type lookups struct {
+	inflictions func(ctx context.Context, xuid string) (*moderation.ModelResponse, error)
+	vpn         func(ctx context.Context, ip string) (*vpn.ResponseModel, error)
+	roles       func(ctx context.Context, xuid string) ([]string, error)
+	bypassed    func(xuid string) bool
+}
+
+// serviceLookups returns lookups asking the moderation, VPN and rank
+// services.
+func serviceLookups() lookups {
+	return lookups{
+		inflictions: func(ctx context.Context, xuid string) (*moderation.ModelResponse, error) {
+			return moderation.GlobalService().InflictionOfXUID(ctx, xuid)
+		},
+		vpn: func(ctx context.Context, ip string) (*vpn.ResponseModel, error) {
+			return vpn.GlobalService().CheckIP(ctx, ip)
+		},
+		roles: func(ctx context.Context, xuid string) ([]string, error) {
+			roles, _, err := rank.GlobalService().RolesOrCached(ctx, xuid)
+
+			return roles, err
+		},
+		bypassed: func(xuid string) bool {
+			return vpn.GlobalService().Bypassed(xuid)
+		},
+	}
+}
+
+// NewAllower creates an Allower with the given configuration.
+func NewAllower(conf AllowerConfig) *Allower {
+	return newAllower(conf, serviceLookups())
+}
+
+// newAllower creates an Allower asking the given lookups.
+func newAllower(conf AllowerConfig, lookup lookups) *Allower {
+	if conf.Deadline <= 0 {
+		conf.Deadline = defaultLoginDeadline
+	}
+
+	return &Allower{conf: conf, lookup: lookup}
+}
+
+// checkResult is the outcome of a single login check.
+type checkResult[T any] struct {
+	val      T
+	err      error
+	took     time.Duration
+	timedOut bool
+}
+
+// failed reports whether the check didn't produce a value.
+func (r checkResult[T]) failed() bool {
+	return r.err != nil || r.timedOut
+}
+
+// startCheck runs fn through the flight group for key in the background.
+// The lookup is shared with later logins, so it runs under its own timeout
+// rather than the context of the login that started it, which is cancelled
+// once that login is decided. The returned channel receives exactly one
+// result.
+func startCheck[T any](g *util.SingleFlight[string, T], key string, timeout time.Duration,
+	fn func(context.Context) (T, error),
+) <-chan checkResult[T] {
+	ch := make(chan checkResult[T], 1)
+
+	go func() {
+		start := time.Now()
+		v, err, _ := g.Do(key, func() (T, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			return fn(ctx)
+		})
+		ch <- checkResult[T]{val: v, err: err, took: time.Since(start)}
+	}()
+
+	return ch
+}
+
+// awaitCheck waits for a check started at start until ctx, the context of
+// the waiting login, is done. Logins from the same IP or XUID arriving
+// while a check runs share its result.
+func awaitCheck[T any](ctx context.Context, start time.Time, ch <-chan checkResult[T]) checkResult[T] {
+	select {
+	case r := <-ch:
+		return r
+	case <-ctx.Done():
+		return checkResult[T]{took: time.Since(start), timedOut: true}
+	}
+}
 
 // Allow ...
-func (a Allower) Allow(addr net.Addr, d login.IdentityData, _ login.ClientData) (string, bool) {
-	if reason, allowed := a.handleVPN(addr, d); !allowed {
-		return reason, allowed
+func (a *Allower) Allow(addr net.Addr, d login.IdentityData, c login.ClientData) (string, bool) {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.conf.Deadline)
+	defer cancel()
+
+	// Disconnect messages are sent in the language the player picked, so
+	// their profile is loaded first, or else in the language of the client.
+	// It is kept in memory for HandleJoin.
+	profile.Global().Hold(d.XUID)
+	if _, err := profile.Global().Load(ctx, d.XUID); err != nil {
+		slog.Default().Warn("profile not loaded in time, using client language", "xuid", d.XUID, "error", err)
 	}
+	client, _ := language.Parse(strings.ReplaceAll(c.LanguageCode, "_", "-"))
+	lang := locale.LanguageOf(d.XUID, client)
 
-	resp, err := moderation.GlobalService().InflictionOfXUID(d.XUID)
-	if err != nil {
-		// TODO: Change this back to disabled once fixed.
-		slog.Default().Error("error whilst loading inflictions", "xuid", d.XUID, "error", err)
+	reason, ok := a.check(ctx, start, lang, addr, d)
+	if ok {
+		reason, ok = a.admit(lang, d)
+	}
+	if !ok {
+		// Free the account slot reserved by the join limiter.
+		flood.Global().Release(flood.AddrOf(addr), d.XUID)
+	}
 
-		return locale.Translate("error.inflictions.load"), true
+	return reason, ok
+}
+
+// check runs every login check but the capacity one.
+func (a *Allower) check(ctx context.Context, start time.Time, lang language.Tag, addr net.Addr, d login.IdentityData) (string, bool) {
+	ip, checkVPN, reason, ok := a.vpnTarget(addr, d)
+	if !ok {
+		return reason, false
 	}
 
-	for _, i := range resp.CurrentInflictions {
-		if i.Type == moderation.InflictionBanned {
-			return locale.Translate("error.ban.message", i.Reason, i.ExpiryDate, i.Prosecutor), false
+	// Join limits are checked first so a flood never reaches the APIs.
+	if r := flood.Global().Allow(flood.AddrOf(addr), d.XUID, start); r != flood.ReasonNone {
+		slog.Default().Info("join denied by flood protection", "name", d.DisplayName, "xuid", d.XUID, "ip", ip, "reason", r)
+
+		return locale.TranslateL(lang, r.LocaleKey()), false
+	}
+
+	inflictionCh := startCheck(&a.inflictionFlight, d.XUID, a.conf.Deadline, func(ctx context.Context) (*moderation.ModelResponse, error) {
+		return a.lookup.inflictions(ctx, d.XUID)
+	})
+
+	var (
+		vpnCh  <-chan checkResult[*vpn.ResponseModel]
+		rankCh <-chan checkResult[[]string]
+	)
+	if checkVPN {
+		vpnCh = startCheck(&a.vpnFlight, ip, a.conf.Deadline, func(ctx context.Context) (*vpn.ResponseModel, error) {
+			return a.lookup.vpn(ctx, ip)
+		})
+		// Roles are only needed for flagged connections, but looking them
+		// up alongside the VPN check keeps them off the critical path.
+		rankCh = startCheck(&a.rankFlight, d.XUID, a.conf.Deadline, func(ctx context.Context) ([]string, error) {
+			return a.lookup.roles(ctx, d.XUID)
+		})
+	}
+
+	log := slog.Default().With("name", d.DisplayName, "xuid", d.XUID, "ip", ip)
+	var timings []any
+
+	defer func() {
+		total := time.Since(start)
+		if total >= a.conf.Deadline/2 {
+			log.Warn("slow login checks", append(timings, "total", total)...)
+		} else {
+			log.Debug("login checks finished", append(timings, "total", total)...)
 		}
+	}()
+
+	infl := awaitCheck(ctx, start, inflictionCh)
+	timings = append(timings, "inflictions", infl.took)
+
+	if reason, allowed := a.handleInflictions(log, lang, infl); !allowed {
+		return reason, false
 	}
 
-	return "", true
+	if !checkVPN {
+		return "", true
+	}
+
+	v := awaitCheck(ctx, start, vpnCh)
+	timings = append(timings, "vpn", v.took)
+
+	reason, allowed, needRanks := a.handleVPN(log, lang, v)
+	if !needRanks {
+		return reason, allowed
+	}
+
+	r := awaitCheck(ctx, start, rankCh)
+	timings = append(timings, "ranks", r.took)
+
+	return a.handleVPNBypass(log, lang, v.val, r)
 }
 
-// handleVPN checks if the given network address is using a VPN and returns the reason and whether it's allowed.
-// Accounts linked to the Discord server (holding at least one role) are allowed to connect through a VPN/proxy.
-func (Allower) handleVPN(netAddr net.Addr, d login.IdentityData) (reason string, allowed bool) {
+// admit checks the hub has room for the player last, so nobody is displaced
+// for a login denied anyway. The rank is taken from the rank cache; players
+// without cached roles count as unlinked.
+func (a *Allower) admit(lang language.Tag, d login.IdentityData) (string, bool) {
+	if a.conf.Admit == nil {
+		return "", true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), admitTimeout)
+	defer cancel()
+
+	r, _ := rank.GlobalService().CachedRank(d.XUID)
+	if a.conf.Admit(ctx, d.XUID, r) {
+		return "", true
+	}
+	slog.Default().Info("join denied, hub full", "name", d.DisplayName, "xuid", d.XUID, "rank", r.Name())
+
+	return locale.TranslateL(lang, "capacity.full"), false
+}
+
+// vpnTarget returns the IP to run the VPN check for and whether it should
+// run at all. Local connections and accounts granted a bypass by staff
+// skip the check.
+func (a *Allower) vpnTarget(netAddr net.Addr, d login.IdentityData) (ip string, check bool, reason string, ok bool) {
 	addr, err := netip.ParseAddrPort(netAddr.String())
 	if err != nil {
 		slog.Default().Error("error whilst parsing address", "address", netAddr.String(), "error", err)
 
-		return "Invalid address format.", false
+		return "", false, "Invalid address format.", false
 	}
 
-	ip := addr.Addr()
-	if ip.IsLoopback() || ip.IsUnspecified() {
-		return "", true
+	parsed := addr.Addr().Unmap()
+	if parsed.IsLoopback() || parsed.IsUnspecified() {
+		return parsed.String(), false, "", true
 	}
 
-	addrString := ip.String()
+	// Staff granted this account a bypass, no need to ask any provider.
+	if a.lookup.bypassed(d.XUID) {
+		return parsed.String(), false, "", true
+	}
 
-	m, err := vpn.GlobalService().CheckIP(addrString)
-	if err != nil {
-		// Allow players through when VPN service is rate limited
-		if strings.Contains(err.Error(), "rate limit active") {
-			slog.Default().Warn("VPN check skipped due to rate limit", "ip", addrString, "error", err)
+	return parsed.String(), true, "", true
+}
 
-			return "", true
-		}
+// handleInflictions denies players with an active ban.
+func (a *Allower) handleInflictions(log *slog.Logger, lang language.Tag, r checkResult[*moderation.ModelResponse]) (string, bool) {
+	if r.failed() {
+		log.Error("error whilst loading inflictions", "timed_out", r.timedOut, "took", r.took, "error", r.err)
 
-		return err.Error(), false
+		return locale.TranslateL(lang, "error.inflictions.load"), a.conf.Infliction == FailOpen
 	}
 
-	if m.Status != vpn.StatusSuccess {
-		return m.Message, false
+	for _, i := range r.val.CurrentInflictions {
+		if i.Type == moderation.InflictionBanned {
+			return locale.TranslateL(lang, "error.ban.message", i.Reason, i.ExpiryDate, i.Prosecutor), false
+		}
 	}
 
-	if !m.Proxy {
-		return "", true
+	return "", true
+}
+
+// handleVPN decides on the VPN verdict. needRanks is set when the
+// connection was flagged and the linked account bypass must be checked.
+func (a *Allower) handleVPN(log *slog.Logger, lang language.Tag, r checkResult[*vpn.ResponseModel]) (reason string, allowed, needRanks bool) {
+	switch {
+	case errors.Is(r.err, vpn.ErrRateLimited):
+		// Allow players through when every provider is rate limited.
+		log.Warn("VPN check skipped due to rate limit", "error", r.err)
+
+		return "", true, false
+	case r.failed():
+		log.Error("VPN check failed", "timed_out", r.timedOut, "took", r.took, "error", r.err)
+
+		if a.conf.VPN == FailOpen {
+			return "", true, false
+		}
+		if r.timedOut {
+			return locale.TranslateL(lang, "error.login.timeout"), false, false
+		}
+
+		return locale.TranslateL(lang, "error.vpn.failed"), false, false
+	case r.val.Status != vpn.StatusSuccess:
+		return r.val.Message, false, false
+	case !r.val.Proxy:
+		return "", true, false
 	}
 
-	// Linked accounts (any Discord role) may connect through a VPN/proxy.
-	roles, err := rank.GlobalService().RolesOfXUID(d.XUID)
-	if err == nil && len(roles) > 0 {
-		slog.Default().Info("allowing VPN connection for linked account",
-			"ip", addrString, "name", d.DisplayName, "xuid", d.XUID)
+	return "", false, true
+}
+
+// handleVPNBypass allows flagged connections of accounts linked to the
+// Discord server (holding at least one role).
+func (a *Allower) handleVPNBypass(log *slog.Logger, lang language.Tag, m *vpn.ResponseModel, r checkResult[[]string]) (string, bool) {
+	if !r.failed() && len(r.val) > 0 {
+		log.Info("allowing VPN connection for linked account")
 
 		return "", true
 	}
 
-	if err != nil && !errors.Is(err, rank.ErrUserNotFound) {
-		slog.Default().Error("error whilst checking roles for VPN bypass", "xuid", d.XUID, "error", err)
+	if r.failed() && !errors.Is(r.err, rank.ErrUserNotFound) {
+		log.Error("error whilst checking roles for VPN bypass", "timed_out", r.timedOut, "took", r.took, "error", r.err)
+
+		if a.conf.Rank == FailOpen {
+			return "", true
+		}
 	}
 
 	// Log denials with ISP details so misclassified residential ISP
 	// ranges can be spotted and added to the VpnWhitelist config.
-	slog.Default().Warn("blocked VPN/proxy connection",
-		"ip", addrString, "isp", m.Isp, "org", m.Org, "name", d.DisplayName, "xuid", d.XUID)
+	log.Warn("blocked VPN/proxy connection", "isp", m.Isp, "org", m.Org, "provider", m.Provider)
 
-	return locale.Translate("error.vpn.blocked"), false
+	return locale.TranslateL(lang, "error.vpn.blocked"), false
 }
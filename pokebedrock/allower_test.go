@@ -0,0 +1,262 @@
+package pokebedrock
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sandertv/gophertunnel/minecraft/protocol/login"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/flood"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/vpn"
+)
+
+// setupLoginGlobals creates the join limiter and profile store every login
+// check relies on.
+func setupLoginGlobals(t *testing.T) {
+	t.Helper()
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	l := flood.NewLimiter(log, flood.Config{})
+	s := profile.NewStore(log, profile.Config{Dir: t.TempDir()})
+	t.Cleanup(func() {
+		l.Close()
+		s.Close()
+	})
+}
+
+// blockUntilDone is a lookup that only returns once ctx is done, recording
+// that it was given up.
+func blockUntilDone[T any](stopped *atomic.Bool) func(ctx context.Context, _ string) (T, error) {
+	return func(ctx context.Context, _ string) (T, error) {
+		<-ctx.Done()
+		stopped.Store(true)
+
+		var zero T
+
+		return zero, ctx.Err()
+	}
+}
+
+func TestAllowerChecks(t *testing.T) {
+	setupLoginGlobals(t)
+
+	clean := func(context.Context, string) (*moderation.ModelResponse, error) {
+		return &moderation.ModelResponse{}, nil
+	}
+	banned := func(context.Context, string) (*moderation.ModelResponse, error) {
+		return &moderation.ModelResponse{CurrentInflictions: []moderation.Infliction{
+			{Type: moderation.InflictionBanned, Reason: "cheating", Prosecutor: "Staff"},
+		}}, nil
+	}
+	proxy := func(context.Context, string) (*vpn.ResponseModel, error) {
+		return &vpn.ResponseModel{Status: vpn.StatusSuccess, Proxy: true}, nil
+	}
+	linked := func(context.Context, string) ([]string, error) {
+		return []string{"role"}, nil
+	}
+
+	tests := []struct {
+		name string
+		conf AllowerConfig
+		// modify changes the lookups of a clean, residential, unlinked
+		// player. Lookups blocking until the deadline record in stopped
+		// that they were given up.
+		modify  func(l *lookups, stopped *atomic.Bool)
+		allowed bool
+		blocks  bool
+	}{
+		{
+			name:    "clean",
+			allowed: true,
+		},
+		{
+			name:   "banned",
+			modify: func(l *lookups, _ *atomic.Bool) { l.inflictions = banned },
+		},
+		{
+			name:    "linked account on VPN",
+			modify:  func(l *lookups, _ *atomic.Bool) { l.vpn, l.roles = proxy, linked },
+			allowed: true,
+		},
+		{
+			name:   "unlinked account on VPN",
+			modify: func(l *lookups, _ *atomic.Bool) { l.vpn = proxy },
+		},
+		{
+			name: "bypassed account on VPN",
+			modify: func(l *lookups, _ *atomic.Bool) {
+				l.vpn, l.bypassed = proxy, func(string) bool { return true }
+			},
+			allowed: true,
+		},
+		{
+			name: "infliction lookup failing open",
+			conf: AllowerConfig{Infliction: FailOpen, VPN: FailOpen},
+			modify: func(l *lookups, stopped *atomic.Bool) {
+				l.inflictions = blockUntilDone[*moderation.ModelResponse](stopped)
+			},
+			allowed: true,
+			blocks:  true,
+		},
+		{
+			name: "VPN lookup failing closed",
+			conf: AllowerConfig{VPN: FailClosed},
+			modify: func(l *lookups, stopped *atomic.Bool) {
+				l.vpn = blockUntilDone[*vpn.ResponseModel](stopped)
+			},
+			blocks: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stopped atomic.Bool
+			l := lookups{
+				inflictions: clean,
+				vpn: func(context.Context, string) (*vpn.ResponseModel, error) {
+					return &vpn.ResponseModel{Status: vpn.StatusSuccess}, nil
+				},
+				roles: func(context.Context, string) ([]string, error) {
+					return nil, rank.ErrUserNotFound
+				},
+				bypassed: func(string) bool { return false },
+			}
+			if tt.modify != nil {
+				tt.modify(&l, &stopped)
+			}
+			tt.conf.Deadline = 50 * time.Millisecond
+
+			// Every case logs in from its own address and account so no
+			// lookup is shared between them.
+			a := newAllower(tt.conf, l)
+			addr := &net.UDPAddr{IP: net.IPv4(203, 0, 113, byte(i+1)), Port: 19132}
+			d := login.IdentityData{XUID: strconv.Itoa(i + 1), DisplayName: "Player"}
+			if _, allowed := a.Allow(addr, d, login.ClientData{}); allowed != tt.allowed {
+				t.Fatalf("Allow() allowed = %v, want %v", allowed, tt.allowed)
+			}
+
+			if !tt.blocks {
+				return
+			}
+			deadline := time.Now().Add(time.Second)
+			for !stopped.Load() {
+				if time.Now().After(deadline) {
+					t.Fatal("lookup kept running after the login deadline")
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestAllowerSharesLookups(t *testing.T) {
+	setupLoginGlobals(t)
+
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+	)
+	a := newAllower(AllowerConfig{Deadline: time.Second}, lookups{
+		inflictions: func(context.Context, string) (*moderation.ModelResponse, error) {
+			calls.Add(1)
+			<-release
+
+			return &moderation.ModelResponse{}, nil
+		},
+		vpn: func(context.Context, string) (*vpn.ResponseModel, error) {
+			return nil, errors.New("unused")
+		},
+		bypassed: func(string) bool { return false },
+	})
+
+	// Loopback logins skip the VPN check and only look up inflictions.
+	var wg sync.WaitGroup
+	results := make([]bool, 2)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, byte(i+1)), Port: 19132}
+			_, results[i] = a.Allow(addr, login.IdentityData{XUID: "1"}, login.ClientData{})
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("inflictions looked up %d times, want 1", n)
+	}
+	for i, ok := range results {
+		if !ok {
+			t.Errorf("login %d denied", i)
+		}
+	}
+}
+
+func TestAllowerSharedLookupOutlivesFirstLogin(t *testing.T) {
+	setupLoginGlobals(t)
+
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+	)
+	a := newAllower(AllowerConfig{Deadline: time.Second, VPN: FailClosed}, lookups{
+		inflictions: func(_ context.Context, xuid string) (*moderation.ModelResponse, error) {
+			if xuid == "1" {
+				return &moderation.ModelResponse{CurrentInflictions: []moderation.Infliction{
+					{Type: moderation.InflictionBanned, Reason: "cheating", Prosecutor: "Staff"},
+				}}, nil
+			}
+
+			return &moderation.ModelResponse{}, nil
+		},
+		vpn: func(ctx context.Context, _ string) (*vpn.ResponseModel, error) {
+			calls.Add(1)
+			select {
+			case <-release:
+				return &vpn.ResponseModel{Status: vpn.StatusSuccess}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+		roles: func(context.Context, string) ([]string, error) {
+			return nil, rank.ErrUserNotFound
+		},
+		bypassed: func(string) bool { return false },
+	})
+	addr := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 200), Port: 19132}
+
+	// The banned login is denied while its VPN lookup is still running.
+	if _, allowed := a.Allow(addr, login.IdentityData{XUID: "1"}, login.ClientData{}); allowed {
+		t.Fatal("banned login allowed")
+	}
+
+	done := make(chan bool)
+	go func() {
+		_, allowed := a.Allow(addr, login.IdentityData{XUID: "2"}, login.ClientData{})
+		done <- allowed
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if !<-done {
+		t.Fatal("login sharing the lookup of a decided login was denied")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("VPN looked up %d times, want 1", n)
+	}
+}
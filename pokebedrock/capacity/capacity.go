@@ -0,0 +1,204 @@
+// Package capacity reserves hub slots for higher ranks. Everybody may join
+// up to a soft cap, each reserved tier adds slots for its rank and the
+// ranks above, and a full hub makes room for a higher ranked player by
+// disconnecting an AFK player of a lower rank.
+package capacity
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/afk"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+// Tier reserves slots for players of a rank or higher.
+type Tier struct {
+	Rank  rank.Rank
+	Slots int
+}
+
+// Reservation is the config form of a Tier, naming its rank by ID.
+type Reservation struct {
+	Rank  string
+	Slots int
+}
+
+// Config holds the settings of a Manager.
+type Config struct {
+	// SoftCap is the number of players anyone may join alongside. 0
+	// disables the capacity checks.
+	SoftCap int
+	// Reserved are the tiers of slots reserved above the soft cap.
+	Reserved []Tier
+	// Displace lets a player who finds the hub full take the place of an
+	// AFK player of a lower rank.
+	Displace bool
+	// Policy picks the AFK player displaced first.
+	Policy afk.Policy
+}
+
+// admissionTimeout is how long an admitted player holds their slot before
+// spawning in the hub, such as while downloading resource packs.
+const admissionTimeout = time.Minute
+
+// Manager decides whether a player fits in the hub. Players admitted but not
+// yet spawned hold a slot until they join or admissionTimeout passes, so
+// logins at the same time can't all take the last slot.
+type Manager struct {
+	log  *slog.Logger
+	conf Config
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+// NewManager creates a capacity manager.
+func NewManager(log *slog.Logger, conf Config) *Manager {
+	return &Manager{log: log, conf: conf, pending: make(map[string]time.Time)}
+}
+
+// Enabled reports whether the capacity checks run.
+func (m *Manager) Enabled() bool {
+	return m.conf.SoftCap > 0
+}
+
+// Limit returns the number of players a player of rank r may join
+// alongside.
+func (m *Manager) Limit(r rank.Rank) int {
+	limit := m.conf.SoftCap
+	for _, t := range m.conf.Reserved {
+		if r.AtLeast(t.Rank) {
+			limit += t.Slots
+		}
+	}
+
+	return limit
+}
+
+// MaxPlayers returns the player count the server should refuse connections
+// at before they reach the capacity checks. It leaves a slot above every
+// tier so a displacing player can connect while the hub is full.
+func (m *Manager) MaxPlayers() int {
+	limit := m.conf.SoftCap
+	for _, t := range m.conf.Reserved {
+		limit += t.Slots
+	}
+	if m.conf.Displace {
+		limit++
+	}
+
+	return limit
+}
+
+// Admit reports whether the player with the given XUID and rank fits in
+// the hub, disconnecting an AFK player of a lower rank to make room if
+// needed. Players already in the hub, such as those reconnecting, always
+// fit. It waits for the world owner until ctx is done, refusing the player
+// if it doesn't answer in time.
+func (m *Manager) Admit(ctx context.Context, w *world.World, xuid string, r rank.Rank) bool {
+	if !m.Enabled() {
+		return true
+	}
+
+	admitted := false
+	task := w.Do(func(tx *world.Tx) {
+		admitted = m.admit(tx, xuid, r)
+	})
+	if err := task.Wait(ctx); err != nil {
+		if !task.Cancel() {
+			// The check ran anyway; release any slot it reserved.
+			m.Joined(xuid)
+		}
+		m.log.Warn("capacity check timed out, refusing player", "xuid", xuid, "error", err)
+
+		return false
+	}
+
+	return admitted
+}
+
+// Joined releases the slot held for the player with the given XUID since
+// they were admitted. It is called once they spawn in the hub.
+func (m *Manager) Joined(xuid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.pending, xuid)
+}
+
+// admit decides on a joining player on the world owner.
+func (m *Manager) admit(tx *world.Tx, xuid string, r rank.Rank) bool {
+	online := 0
+	for ent := range tx.Players() {
+		if ent.(*player.Player).XUID() == xuid {
+			return true
+		}
+		online++
+	}
+
+	now := time.Now()
+	online += m.pendingOthers(xuid, now)
+
+	limit := m.Limit(r)
+	if online < limit {
+		m.hold(xuid, now)
+
+		return true
+	}
+	if !m.conf.Displace {
+		return false
+	}
+
+	cands := slices.DeleteFunc(m.conf.Policy.Candidates(tx, time.Now()), func(c afk.Candidate) bool {
+		return c.Exempt || !c.Movement.AFK() || c.Rank.Compare(r) >= 0
+	})
+	if len(cands) == 0 {
+		return false
+	}
+	m.conf.Policy.Sort(cands)
+
+	victim := cands[0]
+	m.log.Info("displacing AFK player for higher ranked join",
+		"player", victim.Player.Name(), "rank", victim.Rank.Name(), "idle", victim.Idle,
+		"joining", xuid, "joining_rank", r.Name(), "online", online, "limit", limit)
+	victim.Player.Disconnect(locale.TranslateFor(victim.Player, "capacity.displaced"))
+	m.hold(xuid, now)
+
+	return true
+}
+
+// pendingOthers drops expired admissions and returns the number of players
+// other than xuid admitted but not yet spawned.
+func (m *Manager) pendingOthers(xuid string, now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for other, expiry := range m.pending {
+		switch {
+		case now.After(expiry):
+			delete(m.pending, other)
+		case other != xuid:
+			n++
+		}
+	}
+
+	return n
+}
+
+// hold reserves a slot for the admitted player with the given XUID until
+// they join or admissionTimeout passes.
+func (m *Manager) hold(xuid string, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pending[xuid] = now.Add(admissionTimeout)
+}
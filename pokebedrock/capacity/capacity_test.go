@@ -0,0 +1,80 @@
+package capacity
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+func TestLimit(t *testing.T) {
+	m := NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		SoftCap: 50,
+		Reserved: []Tier{
+			{Rank: rank.Supporter, Slots: 10},
+			{Rank: rank.Helper, Slots: 5},
+		},
+		Displace: true,
+	})
+
+	tests := []struct {
+		name string
+		rank rank.Rank
+		want int
+	}{
+		{"unlinked", rank.UnLinked, 50},
+		{"trainer", rank.Trainer, 50},
+		{"supporter", rank.Supporter, 60},
+		{"helper", rank.Helper, 65},
+		{"owner", rank.Owner, 65},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.Limit(tt.rank); got != tt.want {
+				t.Errorf("Limit(%s) = %d, want %d", tt.name, got, tt.want)
+			}
+		})
+	}
+
+	if got := m.MaxPlayers(); got != 66 {
+		t.Errorf("MaxPlayers() = %d, want 66", got)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	m := NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		Reserved: []Tier{{Rank: rank.Supporter, Slots: 10}},
+	})
+	if m.Enabled() {
+		t.Fatal("manager without a soft cap is enabled")
+	}
+	if !m.Admit(t.Context(), nil, "1", rank.UnLinked) {
+		t.Fatal("disabled manager refused a player")
+	}
+}
+
+func TestPendingAdmissions(t *testing.T) {
+	m := NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{SoftCap: 1})
+	now := time.Now()
+
+	m.hold("1", now)
+	m.hold("2", now)
+	m.hold("3", now.Add(-2*admissionTimeout))
+
+	if got := m.pendingOthers("1", now); got != 1 {
+		t.Fatalf("pendingOthers() = %d, want 1 after dropping the expired admission", got)
+	}
+	if _, ok := m.pending["3"]; ok {
+		t.Fatal("expired admission was kept")
+	}
+
+	m.Joined("2")
+	if got := m.pendingOthers("1", now); got != 0 {
+		t.Fatalf("pendingOthers() = %d after the other player joined, want 0", got)
+	}
+	if got := m.pendingOthers("4", now); got != 1 {
+		t.Fatalf("pendingOthers() = %d for another player, want 1", got)
+	}
+}
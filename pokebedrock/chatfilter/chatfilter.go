@@ -0,0 +1,186 @@
+// Package chatfilter provides the chain of filters every public chat message
+// passes through before it is broadcast: rate limits, duplicate detection,
+// caps and character flood limits, blocked words and link blocking.
+package chatfilter
+
+import (
+	"sync"
+	"time"
+)
+
+// Action is the outcome of running a message through a filter.
+type Action int
+
+// Action constants, ordered by severity.
+const (
+	// ActionAllow lets the message through unchanged.
+	ActionAllow Action = iota
+	// ActionCensor lets the message through with its text replaced.
+	ActionCensor
+	// ActionDrop silently drops the message, telling only the sender.
+	ActionDrop
+	// ActionWarn drops the message, warns the sender and counts a strike.
+	ActionWarn
+	// ActionMute drops the message and mutes the sender. Only returned by
+	// the Chain once a player has collected too many strikes.
+	ActionMute
+)
+
+// Message is a chat message being filtered.
+type Message struct {
+	XUID string
+	Text string
+	Time time.Time
+}
+
+// Verdict is the result of filtering a message.
+type Verdict struct {
+	Action Action
+	// Text is the message text to broadcast, possibly censored.
+	Text string
+	// Reason is the locale key explaining the verdict to the sender.
+	Reason string
+	// Category is the offence category recorded on automatic mutes, which
+	// matches the moderation escalation ladders (e.g. "spam").
+	Category string
+	// MuteDuration is set when Action is ActionMute.
+	MuteDuration time.Duration
+}
+
+// Filter inspects a single chat message.
+type Filter interface {
+	// Check returns the verdict for m. Filters that keep per-player state
+	// record the message as part of the check.
+	Check(m Message) Verdict
+}
+
+// Forgetter is implemented by filters that keep per-player state, so it can
+// be released when the player leaves.
+type Forgetter interface {
+	Forget(xuid string)
+}
+
+// Chain runs messages through filters in order. Censoring filters rewrite the
+// text seen by later filters; the first filter that drops or warns stops the
+// chain. Warnings count as strikes, and collecting StrikesBeforeMute strikes
+// within StrikeWindow escalates to an automatic mute.
+type Chain struct {
+	filters []Filter
+
+	strikesBeforeMute int
+	strikeWindow      time.Duration
+	muteDuration      time.Duration
+
+	mu      sync.Mutex
+	strikes map[string][]time.Time
+}
+
+// NewChain creates a chain running the given filters in order. A
+// strikesBeforeMute of zero disables automatic mutes.
+func NewChain(strikesBeforeMute int, strikeWindow, muteDuration time.Duration, filters ...Filter) *Chain {
+	return &Chain{
+		filters:           filters,
+		strikesBeforeMute: strikesBeforeMute,
+		strikeWindow:      strikeWindow,
+		muteDuration:      muteDuration,
+		strikes:           make(map[string][]time.Time),
+	}
+}
+
+// Check runs m through every filter and returns the combined verdict.
+func (c *Chain) Check(m Message) Verdict {
+	if m.Time.IsZero() {
+		m.Time = time.Now()
+	}
+
+	out := Verdict{Action: ActionAllow, Text: m.Text}
+
+	for _, f := range c.filters {
+		v := f.Check(m)
+
+		switch v.Action {
+		case ActionAllow:
+			continue
+		case ActionCensor:
+			m.Text = v.Text
+			out.Action, out.Text = ActionCensor, v.Text
+			if out.Reason == "" {
+				out.Reason, out.Category = v.Reason, v.Category
+			}
+
+			continue
+		case ActionWarn:
+			if c.strike(m.XUID, m.Time) {
+				v.Action = ActionMute
+				v.MuteDuration = c.muteDuration
+			}
+		}
+
+		v.Text = m.Text
+
+		return v
+	}
+
+	return out
+}
+
+// strike records a strike for the player and reports whether they have now
+// reached the automatic mute threshold. The strikes are cleared when it is.
+func (c *Chain) strike(xuid string, now time.Time) bool {
+	if c.strikesBeforeMute <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	strikes := pruneBefore(c.strikes[xuid], now.Add(-c.strikeWindow))
+	strikes = append(strikes, now)
+
+	if len(strikes) >= c.strikesBeforeMute {
+		delete(c.strikes, xuid)
+
+		return true
+	}
+
+	c.strikes[xuid] = strikes
+
+	return false
+}
+
+// Forget releases all state kept for the player.
+func (c *Chain) Forget(xuid string) {
+	c.mu.Lock()
+	delete(c.strikes, xuid)
+	c.mu.Unlock()
+
+	for _, f := range c.filters {
+		if fg, ok := f.(Forgetter); ok {
+			fg.Forget(xuid)
+		}
+	}
+}
+
+// pruneBefore drops the leading timestamps before cutoff. Timestamps are
+// expected in ascending order.
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+
+	return times[i:]
+}
+
+// global holds the chain used for public chat.
+var global = NewChain(0, 0, 0)
+
+// Global returns the chain used for public chat and private messages.
+func Global() *Chain {
+	return global
+}
+
+// SetGlobal replaces the chain used for public chat.
+func SetGlobal(c *Chain) {
+	global = c
+}
@@ -0,0 +1,99 @@
+package chatfilter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChainEscalatesStrikesToMute(t *testing.T) {
+	now := time.Now()
+	c := NewChain(2, time.Minute, 10*time.Minute, NewRateLimit(1, 10*time.Second))
+
+	check := func(offset time.Duration) Verdict {
+		return c.Check(Message{XUID: "1", Text: "hi", Time: now.Add(offset)})
+	}
+
+	if v := check(0); v.Action != ActionAllow {
+		t.Fatalf("first message = %v, want allow", v.Action)
+	}
+	if v := check(time.Second); v.Action != ActionWarn {
+		t.Fatalf("rate limited message = %v, want warn", v.Action)
+	}
+	v := check(2 * time.Second)
+	if v.Action != ActionMute || v.MuteDuration != 10*time.Minute || v.Category != "spam" {
+		t.Fatalf("second strike = %+v, want 10m spam mute", v)
+	}
+	if v := check(20 * time.Second); v.Action != ActionAllow {
+		t.Fatalf("message after window = %v, want allow", v.Action)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	now := time.Now()
+	d := NewDuplicate(30 * time.Second)
+
+	if v := d.Check(Message{XUID: "1", Text: "Hello there", Time: now}); v.Action != ActionAllow {
+		t.Fatal("first message blocked")
+	}
+	if v := d.Check(Message{XUID: "1", Text: "hello   THERE", Time: now.Add(time.Second)}); v.Action != ActionWarn {
+		t.Fatal("duplicate message allowed")
+	}
+	if v := d.Check(Message{XUID: "2", Text: "hello there", Time: now.Add(time.Second)}); v.Action != ActionAllow {
+		t.Fatal("duplicate from another player blocked")
+	}
+	if v := d.Check(Message{XUID: "1", Text: "hello there", Time: now.Add(time.Minute)}); v.Action != ActionAllow {
+		t.Fatal("duplicate after window blocked")
+	}
+}
+
+func TestCensoringFilters(t *testing.T) {
+	b, err := NewBlocklist([]string{"noob"}, []string{`bad\w*`}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		filter Filter
+		in     string
+		want   string
+	}{
+		{"caps", NewCaps(5, 0.6), "HELLO EVERYONE", "hello everyone"},
+		{"caps short", NewCaps(5, 0.6), "GG", "GG"},
+		{"flood", NewCharFlood(3), "hiiiiiii!!!!!", "hiii!!!"},
+		{"blocked word", b, "you n00b", "you ****"},
+		{"blocked word boundary", b, "noobish", "noobish"},
+		{"blocked pattern", b, "so B4DDY", "so *****"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := tc.filter.Check(Message{XUID: "1", Text: tc.in})
+			got := tc.in
+			if v.Action == ActionCensor {
+				got = v.Text
+			}
+			if got != tc.want {
+				t.Errorf("Check(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	l := NewLinks([]string{"pokebedrock.com"})
+
+	for text, blocked := range map[string]bool{
+		"join evil.gg now":                 true,
+		"https://scam.com/free":            true,
+		"see https://pokebedrock.com/wiki": false,
+		"store.pokebedrock.com":            false,
+		"pokebedrock.com.evil.ru/x":        true,
+		"notpokebedrock.com":               true,
+		"pokebedrock.com:443/store":        false,
+		"ok.so what now":                   false,
+	} {
+		if got := l.Check(Message{Text: text}).Action == ActionWarn; got != blocked {
+			t.Errorf("Check(%q) blocked = %v, want %v", text, got, blocked)
+		}
+	}
+}
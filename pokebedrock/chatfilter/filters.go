@@ -0,0 +1,333 @@
+package chatfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RateLimit allows each player at most a fixed number of messages within a
+// sliding window.
+type RateLimit struct {
+	messages int
+	window   time.Duration
+
+	mu   sync.Mutex
+	sent map[string][]time.Time
+}
+
+// NewRateLimit creates a per-player rate limit filter.
+func NewRateLimit(messages int, window time.Duration) *RateLimit {
+	return &RateLimit{messages: messages, window: window, sent: make(map[string][]time.Time)}
+}
+
+// Check ...
+func (r *RateLimit) Check(m Message) Verdict {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sent := pruneBefore(r.sent[m.XUID], m.Time.Add(-r.window))
+	if len(sent) >= r.messages {
+		r.sent[m.XUID] = sent
+
+		return Verdict{Action: ActionWarn, Reason: "chat.filter.rate", Category: "spam"}
+	}
+
+	r.sent[m.XUID] = append(sent, m.Time)
+
+	return Verdict{Action: ActionAllow}
+}
+
+// Forget ...
+func (r *RateLimit) Forget(xuid string) {
+	r.mu.Lock()
+	delete(r.sent, xuid)
+	r.mu.Unlock()
+}
+
+// Duplicate drops a message that repeats one of the player's recent messages
+// within the window. Messages are compared case- and whitespace-insensitively.
+type Duplicate struct {
+	window time.Duration
+
+	mu   sync.Mutex
+	last map[string][]sentMessage
+}
+
+// sentMessage is a normalised message and when it was sent.
+type sentMessage struct {
+	text string
+	at   time.Time
+}
+
+// duplicateHistory is the number of recent messages compared per player.
+const duplicateHistory = 3
+
+// NewDuplicate creates a duplicate message filter.
+func NewDuplicate(window time.Duration) *Duplicate {
+	return &Duplicate{window: window, last: make(map[string][]sentMessage)}
+}
+
+// Check ...
+func (d *Duplicate) Check(m Message) Verdict {
+	text := strings.Join(strings.Fields(strings.ToLower(m.Text)), " ")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	recent := d.last[m.XUID][:0:0]
+	for _, s := range d.last[m.XUID] {
+		if m.Time.Sub(s.at) <= d.window {
+			recent = append(recent, s)
+		}
+	}
+
+	for _, s := range recent {
+		if s.text == text {
+			d.last[m.XUID] = recent
+
+			return Verdict{Action: ActionWarn, Reason: "chat.filter.duplicate", Category: "spam"}
+		}
+	}
+
+	recent = append(recent, sentMessage{text: text, at: m.Time})
+	if len(recent) > duplicateHistory {
+		recent = recent[len(recent)-duplicateHistory:]
+	}
+	d.last[m.XUID] = recent
+
+	return Verdict{Action: ActionAllow}
+}
+
+// Forget ...
+func (d *Duplicate) Forget(xuid string) {
+	d.mu.Lock()
+	delete(d.last, xuid)
+	d.mu.Unlock()
+}
+
+// Caps lowercases messages of at least minLength letters of which more than
+// maxRatio are upper case.
+type Caps struct {
+	minLength int
+	maxRatio  float64
+}
+
+// NewCaps creates a caps filter.
+func NewCaps(minLength int, maxRatio float64) Caps {
+	return Caps{minLength: minLength, maxRatio: maxRatio}
+}
+
+// Check ...
+func (c Caps) Check(m Message) Verdict {
+	var letters, upper int
+
+	for _, r := range m.Text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	if letters < c.minLength || float64(upper)/float64(letters) <= c.maxRatio {
+		return Verdict{Action: ActionAllow}
+	}
+
+	return Verdict{Action: ActionCensor, Text: strings.ToLower(m.Text), Reason: "chat.filter.caps", Category: "spam"}
+}
+
+// CharFlood collapses runs of the same character longer than maxRepeat down
+// to maxRepeat characters.
+type CharFlood struct {
+	maxRepeat int
+}
+
+// NewCharFlood creates a character flood filter.
+func NewCharFlood(maxRepeat int) CharFlood {
+	return CharFlood{maxRepeat: maxRepeat}
+}
+
+// Check ...
+func (c CharFlood) Check(m Message) Verdict {
+	var (
+		b       strings.Builder
+		prev    rune
+		run     int
+		flooded bool
+	)
+
+	for i, r := range m.Text {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+
+		if run > c.maxRepeat {
+			flooded = true
+
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	if !flooded {
+		return Verdict{Action: ActionAllow}
+	}
+
+	return Verdict{Action: ActionCensor, Text: b.String(), Reason: "chat.filter.flood", Category: "spam"}
+}
+
+// leetspeak maps common character substitutions back to the letter they
+// stand in for. Every replacement is a single rune so offsets stay aligned.
+var leetspeak = map[rune]rune{
+	'0': 'o', '1': 'i', '!': 'i', '|': 'i', '3': 'e', '4': 'a', '@': 'a',
+	'5': 's', '$': 's', '7': 't', '+': 't', '8': 'b', '9': 'g',
+}
+
+// normalise lowercases text and undoes leetspeak substitutions, keeping a
+// one-to-one mapping between input and output runes.
+func normalise(text []rune) string {
+	out := make([]rune, len(text))
+	for i, r := range text {
+		r = unicode.ToLower(r)
+		if n, ok := leetspeak[r]; ok {
+			r = n
+		}
+		out[i] = r
+	}
+
+	return string(out)
+}
+
+// Blocklist censors blocked words and regular expressions. Matching is done
+// on a lowercased, leetspeak-normalised copy of the message. When drop is
+// set, matching messages are dropped with a warning instead of censored.
+type Blocklist struct {
+	patterns []*regexp.Regexp
+	drop     bool
+}
+
+// NewBlocklist creates a blocklist filter from plain words, matched as whole
+// words, and regular expressions.
+func NewBlocklist(words, patterns []string, drop bool) (*Blocklist, error) {
+	b := &Blocklist{drop: drop}
+
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		b.patterns = append(b.patterns, regexp.MustCompile(`\b`+regexp.QuoteMeta(normalise([]rune(w)))+`\b`))
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blocked pattern %q: %w", p, err)
+		}
+		b.patterns = append(b.patterns, re)
+	}
+
+	return b, nil
+}
+
+// Check ...
+func (b *Blocklist) Check(m Message) Verdict {
+	if len(b.patterns) == 0 {
+		return Verdict{Action: ActionAllow}
+	}
+
+	runes := []rune(m.Text)
+	normalised := normalise(runes)
+
+	// Map byte offsets in the normalised string back to rune indices.
+	runeAt := make([]int, len(normalised)+1)
+	var ri int
+	for i := range normalised {
+		runeAt[i] = ri
+		ri++
+	}
+	runeAt[len(normalised)] = ri
+
+	var matched bool
+	for _, re := range b.patterns {
+		for _, loc := range re.FindAllStringIndex(normalised, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			matched = true
+			for i := runeAt[loc[0]]; i < runeAt[loc[1]]; i++ {
+				if !unicode.IsSpace(runes[i]) {
+					runes[i] = '*'
+				}
+			}
+		}
+	}
+
+	switch {
+	case !matched:
+		return Verdict{Action: ActionAllow}
+	case b.drop:
+		return Verdict{Action: ActionWarn, Reason: "chat.filter.blocked", Category: "toxicity"}
+	default:
+		return Verdict{Action: ActionCensor, Text: string(runes), Reason: "chat.filter.blocked", Category: "toxicity"}
+	}
+}
+
+// linkPattern matches things that look like links: an optional scheme
+// followed by a domain ending in a common TLD. Restricting the TLDs keeps
+// ordinary sentences such as "ok.so" from being treated as links.
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://)?(?:[a-z0-9-]+\.)+` +
+	`(?:com|net|org|gg|io|me|co|xyz|tk|ly|us|uk|de|ru|tv|gl|info|live|club|online|site|store|link)\b(?:/\S*)?`)
+
+// Links drops messages containing links, except to allowed domains and
+// their subdomains.
+type Links struct {
+	allowed []string
+}
+
+// NewLinks creates a link filter allowing the given domains.
+func NewLinks(allowed []string) Links {
+	l := Links{}
+	for _, d := range allowed {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			l.allowed = append(l.allowed, d)
+		}
+	}
+
+	return l
+}
+
+// Check ...
+func (l Links) Check(m Message) Verdict {
+	for _, link := range linkPattern.FindAllString(m.Text, -1) {
+		if !l.isAllowed(link) {
+			return Verdict{Action: ActionWarn, Reason: "chat.filter.link", Category: "spam"}
+		}
+	}
+
+	return Verdict{Action: ActionAllow}
+}
+
+// isAllowed reports whether the link points to an allowed domain.
+func (l Links) isAllowed(link string) bool {
+	link = strings.ToLower(link)
+	link = strings.TrimPrefix(strings.TrimPrefix(link, "https://"), "http://")
+	host, _, _ := strings.Cut(link, "/")
+	host, _, _ = strings.Cut(host, ":")
+
+	for _, d := range l.allowed {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
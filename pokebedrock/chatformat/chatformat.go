@@ -0,0 +1,141 @@
+// Package chatformat formats public chat messages: it gates formatting codes
+// by rank, highlights @mentions and announces players of high enough rank
+// joining and leaving the hub.
+package chatformat
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+)
+
+// formatCodes holds the formatting codes players may use in chat: every
+// colour code, bold, italic and reset. Obfuscated text (k) is never allowed.
+const formatCodes = "0123456789abcdefghijmnpqrstuvlor"
+
+// Colours gates the formatting codes in message. When allowed, codes
+// written with § or & are kept as § codes; otherwise every § code is
+// removed and & is left as typed.
+func Colours(message string, allowed bool) string {
+	var b strings.Builder
+	b.Grow(len(message))
+
+	for i := 0; i < len(message); {
+		r, size := utf8.DecodeRuneInString(message[i:])
+		if r != '§' && (r != '&' || !allowed) {
+			b.WriteRune(r)
+			i += size
+
+			continue
+		}
+
+		code, codeSize := utf8.DecodeRuneInString(message[i+size:])
+		code = unicode.ToLower(code)
+		valid := codeSize > 0 && strings.ContainsRune(formatCodes, code)
+		switch {
+		case r == '&' && !valid:
+			b.WriteRune(r)
+			i += size
+
+			continue
+		case allowed && valid:
+			b.WriteRune('§')
+			b.WriteRune(code)
+		}
+		i += size + codeSize
+	}
+
+	return b.String()
+}
+
+// mentionPrefix starts a mention of another player.
+const mentionPrefix = '@'
+
+// Mentions highlights every @name in message naming a player in the hub.
+// It returns the highlighted message and the players mentioned, each once.
+// resume is the formatting code the message continues in after a mention.
+func Mentions(tx *world.Tx, message, resume string) (string, []*player.Player) {
+	if !strings.ContainsRune(message, mentionPrefix) {
+		return message, nil
+	}
+
+	players := make(map[string]*player.Player)
+	online := make(map[string]string)
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+		players[strings.ToLower(p.Name())] = p
+		online[strings.ToLower(p.Name())] = p.Name()
+	}
+
+	message, names := highlight(online, message, resume)
+	mentioned := make([]*player.Player, 0, len(names))
+	for _, name := range names {
+		mentioned = append(mentioned, players[name])
+	}
+
+	return message, mentioned
+}
+
+// highlight highlights every @name in message naming a player in online,
+// which maps lowercase names to the names of players as displayed. It
+// returns the highlighted message and the lowercase names mentioned, each
+// once.
+func highlight(online map[string]string, message, resume string) (string, []string) {
+	var (
+		b         strings.Builder
+		mentioned []string
+	)
+	for i := 0; i < len(message); {
+		at := strings.IndexRune(message[i:], mentionPrefix)
+		if at < 0 {
+			b.WriteString(message[i:])
+
+			break
+		}
+		b.WriteString(message[i : i+at])
+		i += at + 1
+
+		name := longestName(online, message[i:])
+		if name == "" {
+			b.WriteRune(mentionPrefix)
+
+			continue
+		}
+		b.WriteString(text.Yellow + string(mentionPrefix) + online[name] + text.Reset + resume)
+		i += len(name)
+
+		if !slices.Contains(mentioned, name) {
+			mentioned = append(mentioned, name)
+		}
+	}
+
+	return b.String(), mentioned
+}
+
+// longestName returns the lowercase name in online that is the longest
+// prefix of s ending at a word boundary, or "" if there is none. Names may
+// contain spaces, so "@Ash Ketchum hi" mentions "Ash Ketchum" over "Ash".
+func longestName(online map[string]string, s string) string {
+	var best string
+	for name := range online {
+		if len(name) <= len(best) || len(name) > len(s) || !strings.EqualFold(s[:len(name)], name) {
+			continue
+		}
+		if next, _ := utf8.DecodeRuneInString(s[len(name):]); len(name) < len(s) && isNameRune(next) {
+			continue
+		}
+		best = name
+	}
+
+	return best
+}
+
+// isNameRune reports whether r may be part of a player name.
+func isNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
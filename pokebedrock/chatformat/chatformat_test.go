@@ -0,0 +1,104 @@
+package chatformat
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/text"
+)
+
+func TestColours(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		allowed bool
+		want    string
+	}{
+		{name: "plain", in: "hello", allowed: false, want: "hello"},
+		{name: "stripped", in: "§chello §lthere", allowed: false, want: "hello there"},
+		{name: "ampersand kept when not allowed", in: "rock &croll", allowed: false, want: "rock &croll"},
+		{name: "section kept", in: "§chello", allowed: true, want: "§chello"},
+		{name: "ampersand converted", in: "&Chello &lbold", allowed: true, want: "§chello §lbold"},
+		{name: "obfuscated removed", in: "§kx&ky", allowed: true, want: "x&ky"},
+		{name: "lone ampersand", in: "you & me &", allowed: true, want: "you & me &"},
+		{name: "trailing section", in: "end§", allowed: true, want: "end"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Colours(tt.in, tt.allowed); got != tt.want {
+				t.Fatalf("Colours(%q, %v) = %q, want %q", tt.in, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLongestName(t *testing.T) {
+	online := map[string]string{
+		"ash":         "Ash",
+		"ash ketchum": "Ash Ketchum",
+		"misty":       "Misty",
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "exact", in: "misty", want: "misty"},
+		{name: "case insensitive", in: "MISTY hi", want: "misty"},
+		{name: "name with space", in: "Ash Ketchum hi", want: "ash ketchum"},
+		{name: "shorter name", in: "Ash Kanto", want: "ash"},
+		{name: "punctuation ends a name", in: "Misty, hi", want: "misty"},
+		{name: "longer word", in: "Ashley hi", want: ""},
+		{name: "digit continues a name", in: "Misty2", want: ""},
+		{name: "unknown", in: "Brock", want: ""},
+		{name: "empty", in: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := longestName(online, tt.in); got != tt.want {
+				t.Fatalf("longestName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	online := map[string]string{"ash": "Ash", "ash ketchum": "Ash Ketchum", "misty": "Misty"}
+	mention := func(name string) string {
+		return text.Yellow + "@" + name + text.Reset + text.Grey
+	}
+
+	tests := []struct {
+		name      string
+		in        string
+		want      string
+		mentioned []string
+	}{
+		{name: "no mention", in: "hello there", want: "hello there"},
+		{name: "display name", in: "hi @misty!", want: "hi " + mention("Misty") + "!", mentioned: []string{"misty"}},
+		{name: "name with space", in: "@ash ketchum go", want: mention("Ash Ketchum") + " go", mentioned: []string{"ash ketchum"}},
+		{
+			name:      "repeated mentions",
+			in:        "@Ash @misty @ASH",
+			want:      mention("Ash") + " " + mention("Misty") + " " + mention("Ash"),
+			mentioned: []string{"ash", "misty"},
+		},
+		{name: "unknown kept", in: "@brock @", want: "@brock @"},
+		{name: "email kept", in: "ash@mistyland", want: "ash@mistyland"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, mentioned := highlight(online, tt.in, text.Grey)
+			if got != tt.want {
+				t.Fatalf("highlight(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !slices.Equal(mentioned, tt.mentioned) {
+				t.Fatalf("highlight(%q) mentioned %v, want %v", tt.in, mentioned, tt.mentioned)
+			}
+		})
+	}
+}
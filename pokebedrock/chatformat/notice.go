@@ -0,0 +1,119 @@
+package chatformat
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/privatemsg"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+// Config holds the settings of the chat formatter.
+type Config struct {
+	// Mentions highlights @mentions of players in the hub and plays a
+	// sound for them.
+	Mentions bool
+	// NoticeRank is the lowest rank whose players are announced joining
+	// and leaving the hub. Nil announces nobody.
+	NoticeRank *rank.Rank
+}
+
+// Formatter holds the chat settings and tracks which players had their
+// join announced, so only they are announced leaving.
+type Formatter struct {
+	log  *slog.Logger
+	conf Config
+
+	mu        sync.Mutex
+	announced map[string]struct{}
+}
+
+// global holds the singleton chat formatter. It starts out without mentions
+// or notices until NewFormatter is called.
+var global = newFormatter(slog.Default(), Config{})
+
+// Global returns the singleton chat formatter.
+func Global() *Formatter {
+	return global
+}
+
+// NewFormatter initialises the singleton chat formatter.
+func NewFormatter(log *slog.Logger, conf Config) *Formatter {
+	global = newFormatter(log, conf)
+
+	return global
+}
+
+// newFormatter creates a chat formatter.
+func newFormatter(log *slog.Logger, conf Config) *Formatter {
+	return &Formatter{log: log, conf: conf, announced: make(map[string]struct{})}
+}
+
+// MentionsEnabled reports whether @mentions are highlighted.
+func (f *Formatter) MentionsEnabled() bool {
+	return f.conf.Mentions
+}
+
+// HandleRanks announces p joining the hub the first time their ranks are
+// resolved, if their highest rank is at least the notice rank. Ranks are
+// fetched after joining, so the announcement waits for them. It is called
+// on the world owner.
+func (f *Formatter) HandleRanks(tx *world.Tx, p *player.Player, highest rank.Rank) {
+	if f.joined(p.XUID(), highest) {
+		f.broadcast(tx, p, "chat.join", highest)
+	}
+}
+
+// HandleQuit announces p leaving the hub if their join was announced.
+func (f *Formatter) HandleQuit(p *player.Player, highest rank.Rank) {
+	if f.quit(p.XUID()) {
+		f.broadcast(p.Tx(), p, "chat.quit", highest)
+	}
+}
+
+// joined records the ranks of the player with the given XUID being resolved
+// and reports whether their join should be announced: only the first time,
+// and only if highest is at least the notice rank.
+func (f *Formatter) joined(xuid string, highest rank.Rank) bool {
+	if f.conf.NoticeRank == nil || !highest.AtLeast(*f.conf.NoticeRank) {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.announced[xuid]
+	f.announced[xuid] = struct{}{}
+
+	return !ok
+}
+
+// quit forgets the player with the given XUID and reports whether their
+// leaving should be announced, which is the case if their join was.
+func (f *Formatter) quit(xuid string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	_, ok := f.announced[xuid]
+	delete(f.announced, xuid)
+
+	return ok
+}
+
+// broadcast sends a join or quit notice about p to every other player who
+// isn't ignoring them.
+func (f *Formatter) broadcast(tx *world.Tx, p *player.Player, key string, highest rank.Rank) {
+	name := highest.FormatName(p.Name())
+	for ent := range tx.Players() {
+		other := ent.(*player.Player)
+		if other.H() == p.H() || privatemsg.Global().Ignoring(other.XUID(), p.XUID()) {
+			continue
+		}
+		other.Message(locale.TranslateFor(other, key, locale.Args{"name": name}))
+	}
+	f.log.Debug("chat notice", "key", key, "player", p.Name())
+}
@@ -0,0 +1,42 @@
+package chatformat
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+func TestNotices(t *testing.T) {
+	noticeRank := rank.Supporter
+	f := newFormatter(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{NoticeRank: &noticeRank})
+
+	if f.joined("1", rank.Trainer) {
+		t.Fatal("player below the notice rank was announced joining")
+	}
+	if f.quit("1") {
+		t.Fatal("player whose join wasn't announced was announced leaving")
+	}
+
+	if !f.joined("2", rank.Supporter) {
+		t.Fatal("player at the notice rank wasn't announced joining")
+	}
+	if f.joined("2", rank.Admin) {
+		t.Fatal("join was announced again when ranks were resolved again")
+	}
+	if !f.quit("2") {
+		t.Fatal("player whose join was announced wasn't announced leaving")
+	}
+	if f.quit("2") {
+		t.Fatal("leaving was announced twice")
+	}
+	if !f.joined("2", rank.Supporter) {
+		t.Fatal("rejoining player wasn't announced")
+	}
+
+	off := newFormatter(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{})
+	if off.joined("3", rank.Owner) || off.quit("3") {
+		t.Fatal("notices were announced without a notice rank")
+	}
+}
@@ -0,0 +1,37 @@
+package command
+
+import (
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// AFK represents a command that marks the player AFK, or back when they
+// already are.
+type AFK struct{}
+
+// NewAFK creates a new AFK command.
+func NewAFK() cmd.Command {
+	return cmd.New("afk", "Mark yourself as away from keyboard", nil, AFK{})
+}
+
+// Run executes the AFK command.
+func (AFK) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("afk can only be used by players")
+
+		return
+	}
+
+	if session.SetAFK(p, true) {
+		p.Message(locale.TranslateFor(p, "afk.enabled"))
+
+		return
+	}
+	session.SetAFK(p, false)
+	p.Message(locale.TranslateFor(p, "afk.back"))
+}
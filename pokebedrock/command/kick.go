@@ -11,7 +11,6 @@ import (
 	"github.com/sandertv/gophertunnel/minecraft/text"
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
 )
 
 // Kick represents a command to kick a player from the server.
@@ -20,12 +19,12 @@ type Kick struct {
 	Target []cmd.Target `name:"target"`
 	Reason string       `name:"reason" optional:"true" type:"text"`
 
-	rankAllower
+	permissionAllower
 }
 
-// NewKick creates a new kick command with the specified rank requirement.
-func NewKick(r rank.Rank) cmd.Command {
-	return cmd.New("kick", "Kick a player from the server", []string{"k"}, Kick{rankAllower: rankAllower{rank: r}})
+// NewKick creates a new kick command with the specified permission node requirement.
+func NewKick(node string) cmd.Command {
+	return cmd.New("kick", "Kick a player from the server", []string{"k"}, Kick{permissionAllower: permissionAllower{node: node}})
 }
 
 // Run executes the kick command.
@@ -0,0 +1,42 @@
+package command
+
+import (
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/form"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/link"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// Link represents a command that issues the player a one-time code for
+// linking their Discord account.
+type Link struct{}
+
+// NewLink creates a new link command.
+func NewLink() cmd.Command {
+	return cmd.New("link", "Link your Discord account", nil, Link{})
+}
+
+// Run executes the link command.
+func (Link) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("link can only be used by players")
+
+		return
+	}
+
+	if h, ok := p.Handler().(rankHandler); ok && h.Ranks() != nil && h.Ranks().HighestRank() != rank.UnLinked {
+		p.Message(locale.TranslateFor(p, "link.already"))
+
+		return
+	}
+
+	code := link.Global().Issue(p.XUID(), p.Name())
+	p.Message(locale.TranslateFor(p, "link.code", code.Code))
+	session.SendForm(p, form.NewLink(p, code))
+}
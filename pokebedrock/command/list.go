@@ -6,21 +6,21 @@ import (
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/sandertv/gophertunnel/minecraft/text"
 
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
 )
 
 // List represents a command that displays all online players.
 type List struct {
-	rankAllower
+	permissionAllower
 }
 
-// NewList creates a new list command with the specified rank requirement.
-func NewList(r rank.Rank) cmd.Command {
-	return cmd.New("list", "Lists all online players", []string{"ls"}, List{rankAllower: rankAllower{rank: r}})
+// NewList creates a new list command with the specified permission node requirement.
+func NewList(node string) cmd.Command {
+	return cmd.New("list", "Lists all online players", []string{"ls"}, List{permissionAllower: permissionAllower{node: node}})
 }
 
 // Run executes the list command.
-func (l List) Run(_ cmd.Source, o *cmd.Output, tx *world.Tx) {
+func (l List) Run(src cmd.Source, o *cmd.Output, tx *world.Tx) {
 	if tx == nil {
 		o.Error("list requires a world-attached source")
 		return
@@ -34,18 +34,11 @@ func (l List) Run(_ cmd.Source, o *cmd.Output, tx *world.Tx) {
 		players = append(players, p)
 	}
 
-	count := len(players)
-	if count == 0 {
-		o.Print("There are 0 players online")
-
-		return
-	}
-
-	// Display the list of players
-	if count == 1 {
-		o.Print("There is 1 player online:")
+	header := locale.Args{"count": len(players)}
+	if p, ok := src.(locale.Player); ok {
+		o.Print(locale.TranslateFor(p, "command.list.header", header))
 	} else {
-		o.Print("There are", "count", count, "players online:")
+		o.Print(locale.Translate("command.list.header", header))
 	}
 
 	// Get player names, potentially with rank information if possible
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+)
+
+// NewLocale creates the locale management command with the specified
+// permission node requirement. Language files are reloaded from dir.
+func NewLocale(node, dir string) cmd.Command {
+	return cmd.New("locale", "Manage language files", nil,
+		LocaleReload{permissionAllower: permissionAllower{node: node}, dir: dir},
+	)
+}
+
+// LocaleReload reloads every language file without restarting the hub.
+type LocaleReload struct {
+	Sub cmd.SubCommand `cmd:"reload"`
+
+	permissionAllower
+	dir string
+}
+
+// Run ...
+func (l LocaleReload) Run(_ cmd.Source, o *cmd.Output, _ *world.Tx) {
+	langs, err := locale.RegisterAll(l.dir)
+	if err != nil {
+		// The languages loaded before stay in use.
+		o.Errorf("Failed to reload language files: %v", err)
+
+		return
+	}
+
+	o.Printf("Reloaded %d language(s).", len(langs))
+	for _, c := range locale.Check() {
+		if len(c.Missing) > 0 {
+			o.Printf("- %s is missing %s", c.Language, strings.Join(c.Missing, ", "))
+		}
+		if len(c.Unused) > 0 {
+			o.Printf("- %s has unused %s", c.Language, strings.Join(c.Unused, ", "))
+		}
+	}
+}
@@ -6,23 +6,23 @@ import (
 	"github.com/df-mc/dragonfly/server/world"
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/form"
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 )
 
-// Moderate represents a moderation command that can be executed by players with sufficient rank.
-// It includes a target player to apply moderation actions to, as well as the rank requirement.
+// Moderate represents a moderation command that can be executed by players with the required permission.
+// It includes a target player to apply moderation actions to, as well as the permission requirement.
 type Moderate struct {
 	Target string `name:"target"`
 
-	rankAllower
+	permissionAllower
 }
 
 // NewModerate ...
-func NewModerate(r rank.Rank) cmd.Command {
-	return cmd.New("moderate", "", nil, Moderate{rankAllower: rankAllower{rank: r}})
+func NewModerate(node string) cmd.Command {
+	return cmd.New("moderate", "", nil, Moderate{permissionAllower: permissionAllower{node: node}})
 }
 
 // Run ...
 func (m Moderate) Run(src cmd.Source, _ *cmd.Output, _ *world.Tx) {
-	src.(*player.Player).SendForm(form.NewModerate(m.Target))
+	session.SendForm(src.(*player.Player), form.NewModerate(m.Target))
 }
@@ -0,0 +1,192 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/privatemsg"
+)
+
+// Msg represents a command that sends a private message to another player
+// in the hub. The target is a name rather than a target selector, so
+// players who just left get a clean "offline" message.
+type Msg struct {
+	Target  string      `cmd:"player"`
+	Message cmd.Varargs `cmd:"message"`
+}
+
+// NewMsg creates a new private message command.
+func NewMsg() cmd.Command {
+	return cmd.New("msg", "Send a private message to a player", []string{"tell", "w", "whisper"}, Msg{})
+}
+
+// Run executes the private message command.
+func (m Msg) Run(src cmd.Source, o *cmd.Output, tx *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("msg can only be used by players")
+
+		return
+	}
+
+	target, ok := privatemsg.Find(tx, m.Target)
+	if !ok {
+		p.Message(locale.TranslateFor(p, "msg.offline", locale.Args{"name": m.Target}))
+
+		return
+	}
+	if reason := privatemsg.Global().Send(tx, p, target, string(m.Message)); reason != "" {
+		p.Message(locale.TranslateFor(p, reason))
+	}
+}
+
+// Reply represents a command that replies to the player who last exchanged
+// a private message with the sender.
+type Reply struct {
+	Message cmd.Varargs `cmd:"message"`
+}
+
+// NewReply creates a new reply command.
+func NewReply() cmd.Command {
+	return cmd.New("r", "Reply to your last private message", []string{"reply"}, Reply{})
+}
+
+// Run executes the reply command.
+func (r Reply) Run(src cmd.Source, o *cmd.Output, tx *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("r can only be used by players")
+
+		return
+	}
+
+	s := privatemsg.Global()
+	xuid, name, ok := s.ReplyTarget(p.XUID())
+	if !ok {
+		p.Message(locale.TranslateFor(p, "msg.reply.none"))
+
+		return
+	}
+	target, ok := privatemsg.FindXUID(tx, xuid)
+	if !ok {
+		p.Message(locale.TranslateFor(p, "msg.offline", locale.Args{"name": name}))
+
+		return
+	}
+	if reason := s.Send(tx, p, target, string(r.Message)); reason != "" {
+		p.Message(locale.TranslateFor(p, reason))
+	}
+}
+
+// IgnoreAdd represents a command that adds an online player to the sender's
+// ignore list, hiding their chat and blocking their private messages.
+type IgnoreAdd struct {
+	Sub    cmd.SubCommand `cmd:"add"`
+	Target string         `cmd:"player"`
+}
+
+// IgnoreRemove represents a command that removes a player from the sender's
+// ignore list.
+type IgnoreRemove struct {
+	Sub    cmd.SubCommand `cmd:"remove"`
+	Target string         `cmd:"player"`
+}
+
+// IgnoreList represents a command that lists the sender's ignore list.
+type IgnoreList struct {
+	Sub cmd.SubCommand `cmd:"list"`
+}
+
+// NewIgnore creates a new ignore command.
+func NewIgnore() cmd.Command {
+	return cmd.New("ignore", "Hide a player's chat and private messages", nil, IgnoreAdd{}, IgnoreRemove{}, IgnoreList{})
+}
+
+// Run executes the ignore add command.
+func (i IgnoreAdd) Run(src cmd.Source, o *cmd.Output, tx *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("ignore can only be used by players")
+
+		return
+	}
+
+	target, ok := privatemsg.Find(tx, i.Target)
+	if !ok {
+		p.Message(locale.TranslateFor(p, "msg.offline", locale.Args{"name": i.Target}))
+
+		return
+	}
+	if reason := privatemsg.Global().Ignore(p.XUID(), privatemsg.Ignored{XUID: target.XUID(), Name: target.Name()}); reason != "" {
+		p.Message(locale.TranslateFor(p, reason, locale.Args{"name": target.Name()}))
+
+		return
+	}
+	p.Message(locale.TranslateFor(p, "ignore.added", locale.Args{"name": target.Name()}))
+}
+
+// Run executes the ignore remove command.
+func (i IgnoreRemove) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("ignore can only be used by players")
+
+		return
+	}
+
+	removed, ok := privatemsg.Global().Unignore(p.XUID(), i.Target)
+	if !ok {
+		p.Message(locale.TranslateFor(p, "ignore.notfound", locale.Args{"name": i.Target}))
+
+		return
+	}
+	p.Message(locale.TranslateFor(p, "ignore.removed", locale.Args{"name": removed.Name}))
+}
+
+// Run executes the ignore list command.
+func (IgnoreList) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("ignore can only be used by players")
+
+		return
+	}
+
+	list := privatemsg.Global().IgnoreList(p.XUID())
+	if len(list) == 0 {
+		p.Message(locale.TranslateFor(p, "ignore.list.empty"))
+
+		return
+	}
+
+	names := make([]string, 0, len(list))
+	for _, i := range list {
+		names = append(names, i.Name)
+	}
+	p.Message(locale.TranslateFor(p, "ignore.list", locale.Args{"count": len(names), "players": strings.Join(names, ", ")}))
+}
+
+// SocialSpy represents a command that toggles reading the private messages
+// of other players.
+type SocialSpy struct {
+	permissionAllower
+}
+
+// NewSocialSpy creates a new social spy command with the specified permission node requirement.
+func NewSocialSpy(node string) cmd.Command {
+	return cmd.New("socialspy", "Read private messages between players", []string{"spy"}, SocialSpy{permissionAllower: permissionAllower{node: node}})
+}
+
+// Run executes the social spy command.
+func (SocialSpy) Run(src cmd.Source, _ *cmd.Output, _ *world.Tx) {
+	p := src.(*player.Player)
+	if privatemsg.Global().ToggleSpy(p.XUID()) {
+		p.Message(locale.TranslateFor(p, "socialspy.enabled"))
+	} else {
+		p.Message(locale.TranslateFor(p, "socialspy.disabled"))
+	}
+}
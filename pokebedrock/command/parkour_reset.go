@@ -7,7 +7,6 @@ import (
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/sandertv/gophertunnel/minecraft/text"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/parkour"
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
 )
 
 // ParkourReset ...
@@ -15,12 +14,12 @@ type ParkourReset struct {
 	Course string               `name:"course"`
 	XUID   cmd.Optional[string] `name:"xuid"`
 
-	rankAllower
+	permissionAllower
 }
 
 // NewParkourReset ...
-func NewParkourReset(r rank.Rank) cmd.Command {
-	return cmd.New("parkour_reset", "", nil, ParkourReset{rankAllower: rankAllower{rank: r}})
+func NewParkourReset(node string) cmd.Command {
+	return cmd.New("parkour_reset", "", nil, ParkourReset{permissionAllower: permissionAllower{node: node}})
 }
 
 // Run ...
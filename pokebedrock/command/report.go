@@ -0,0 +1,69 @@
+package command
+
+import (
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/form"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// Report represents a command that lets players report another online
+// player. The category is picked in a form opened by the command.
+type Report struct {
+	Target []cmd.Target              `cmd:"player"`
+	Reason cmd.Optional[cmd.Varargs] `cmd:"reason"`
+}
+
+// NewReport creates a new report command.
+func NewReport() cmd.Command {
+	return cmd.New("report", "Report a player to the staff team", nil, Report{})
+}
+
+// Run executes the report command.
+func (r Report) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("report can only be used by players")
+
+		return
+	}
+
+	if len(r.Target) != 1 {
+		o.Error("You can only report one player at a time.")
+
+		return
+	}
+
+	target, ok := r.Target[0].(*player.Player)
+	if !ok {
+		o.Error("You can only report players.")
+
+		return
+	}
+	if target.H() == p.H() {
+		p.Message(locale.TranslateFor(p, "report.self"))
+
+		return
+	}
+
+	reason, _ := r.Reason.Load()
+	session.SendForm(p, form.NewReport(p, target.Name(), target.XUID(), string(reason)))
+}
+
+// Reports represents a staff command listing open player reports.
+type Reports struct {
+	permissionAllower
+}
+
+// NewReports creates a new reports command with the specified permission node requirement.
+func NewReports(node string) cmd.Command {
+	return cmd.New("reports", "View open player reports", nil, Reports{permissionAllower: permissionAllower{node: node}})
+}
+
+// Run executes the reports command.
+func (Reports) Run(src cmd.Source, _ *cmd.Output, _ *world.Tx) {
+	session.SendForm(src.(*player.Player), form.NewReports())
+}
@@ -0,0 +1,45 @@
+package command
+
+import (
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/scoreboard"
+)
+
+// Scoreboard represents a command that turns the sidebar of the player on
+// or off.
+type Scoreboard struct{}
+
+// NewScoreboard creates a new scoreboard command.
+func NewScoreboard() cmd.Command {
+	return cmd.New("scoreboard", "Turn your sidebar on or off", []string{"sb"}, Scoreboard{})
+}
+
+// Run executes the scoreboard command.
+func (Scoreboard) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("scoreboard can only be used by players")
+
+		return
+	}
+
+	m := scoreboard.Global()
+	if !m.Available() {
+		p.Message(locale.TranslateFor(p, "scoreboard.unavailable"))
+
+		return
+	}
+
+	enabled := !m.Enabled(p)
+	m.SetEnabled(p, enabled)
+
+	if enabled {
+		p.Message(locale.TranslateFor(p, "scoreboard.enabled"))
+	} else {
+		p.Message(locale.TranslateFor(p, "scoreboard.disabled"))
+	}
+}
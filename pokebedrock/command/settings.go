@@ -0,0 +1,30 @@
+package command
+
+import (
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/form"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// Settings represents a command that opens the personal settings form.
+type Settings struct{}
+
+// NewSettings creates a new settings command.
+func NewSettings() cmd.Command {
+	return cmd.New("settings", "Change your personal settings", []string{"language"}, Settings{})
+}
+
+// Run executes the settings command.
+func (Settings) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	p, ok := src.(*player.Player)
+	if !ok {
+		o.Error("settings can only be used by players")
+
+		return
+	}
+
+	session.SendForm(p, form.NewSettings(p))
+}
@@ -0,0 +1,91 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/staffchat"
+)
+
+// StaffChat represents a command that sends a message to staff chat, or
+// toggles staff chat for all messages when used without one.
+type StaffChat struct {
+	Message cmd.Optional[cmd.Varargs] `cmd:"message"`
+
+	permissionAllower
+}
+
+// NewStaffChat creates a new staff chat command with the specified permission node requirement.
+func NewStaffChat(node string) cmd.Command {
+	return cmd.New("staffchat", "Talk in the private staff channel", []string{"sc"}, StaffChat{permissionAllower: permissionAllower{node: node}})
+}
+
+// Run executes the staff chat command.
+func (s StaffChat) Run(src cmd.Source, _ *cmd.Output, tx *world.Tx) {
+	p := src.(*player.Player)
+	c := staffchat.Global()
+
+	msg, ok := s.Message.Load()
+	if !ok || strings.TrimSpace(string(msg)) == "" {
+		if c.Toggle(p.XUID()) {
+			p.Message(locale.TranslateFor(p, "staffchat.enabled"))
+		} else {
+			p.Message(locale.TranslateFor(p, "staffchat.disabled"))
+		}
+
+		return
+	}
+
+	c.Send(tx, p, p.Handler().(rankHandler).Ranks().HighestRank(), strings.TrimSpace(string(msg)))
+}
+
+// broadcastTarget is the enum of screen locations a broadcast can target.
+type broadcastTarget string
+
+// Type ...
+func (broadcastTarget) Type() string {
+	return "BroadcastTarget"
+}
+
+// Options ...
+func (broadcastTarget) Options(cmd.Source) []string {
+	targets := staffchat.Targets()
+	opts := make([]string, 0, len(targets))
+
+	for _, t := range targets {
+		opts = append(opts, string(t))
+	}
+
+	return opts
+}
+
+// Broadcast represents a command that shows an announcement to every player
+// in the hub, in chat, as a title or on the action bar.
+type Broadcast struct {
+	Target  broadcastTarget `cmd:"target"`
+	Message cmd.Varargs     `cmd:"message"`
+
+	permissionAllower
+}
+
+// NewBroadcast creates a new broadcast command with the specified permission node requirement.
+func NewBroadcast(node string) cmd.Command {
+	return cmd.New("broadcast", "Send an announcement to everyone in the hub", []string{"bc"}, Broadcast{permissionAllower: permissionAllower{node: node}})
+}
+
+// Run executes the broadcast command.
+func (b Broadcast) Run(_ cmd.Source, o *cmd.Output, tx *world.Tx) {
+	msg := strings.TrimSpace(string(b.Message))
+	if msg == "" {
+		o.Error("Please provide a message to broadcast.")
+
+		return
+	}
+
+	n := staffchat.Broadcast(tx, staffchat.Target(b.Target), msg)
+	o.Printf("Broadcast sent to %d players.", n)
+}
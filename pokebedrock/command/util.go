@@ -4,19 +4,18 @@ import (
 	"github.com/df-mc/dragonfly/server/cmd"
 	"github.com/df-mc/dragonfly/server/player"
 
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 )
 
-// rankAllower is a structure that holds the rank requirement to allow or disallow certain actions or commands.
-// It ensures that only players with the required rank or higher are able to execute the associated actions.
-type rankAllower struct {
-	rank rank.Rank
+// permissionAllower is a structure that holds the permission node required to allow or disallow certain actions or
+// commands. It ensures that only players holding the node through their ranks or an override are able to execute
+// the associated actions.
+type permissionAllower struct {
+	node string
 }
 
-// Allow checks whether the source (player) has the required rank to perform the action.
-// It returns true if the player has a rank equal to or higher than the required rank.
-func (r rankAllower) Allow(s cmd.Source) bool {
+// Allow checks whether the source (player) holds the required permission node to perform the action.
+func (a permissionAllower) Allow(s cmd.Source) bool {
 	p, ok := s.(*player.Player)
 	if !ok {
 		return false
@@ -32,7 +31,7 @@ func (r rankAllower) Allow(s cmd.Source) bool {
 		return false
 	}
 
-	return ranks.HighestRank() >= r.rank
+	return ranks.HasPermission(p.XUID(), a.node)
 }
 
 // rankHandler ...
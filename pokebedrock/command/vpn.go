@@ -0,0 +1,240 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/cmd"
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/vpn"
+)
+
+// NewVpn creates the VPN management command with the specified permission
+// node requirement. It lets staff look up and purge cached verdicts, manage
+// the runtime whitelist and grant per-account VPN bypasses.
+func NewVpn(node string) cmd.Command {
+	allower := permissionAllower{node: node}
+
+	return cmd.New("vpn", "Manage VPN detection", nil,
+		VpnLookup{permissionAllower: allower},
+		VpnPurge{permissionAllower: allower},
+		VpnWhitelist{permissionAllower: allower},
+		VpnBypass{permissionAllower: allower},
+	)
+}
+
+// VpnLookup shows what the VPN service knows about an address.
+type VpnLookup struct {
+	Sub cmd.SubCommand `cmd:"lookup"`
+	IP  string         `cmd:"ip"`
+
+	permissionAllower
+}
+
+// Run ...
+func (l VpnLookup) Run(_ cmd.Source, o *cmd.Output, _ *world.Tx) {
+	res, err := vpn.GlobalService().Lookup(l.IP)
+	if err != nil {
+		o.Error(err)
+
+		return
+	}
+
+	o.Printf("VPN lookup for %s:", res.IP)
+	if res.Whitelisted != "" {
+		o.Printf("- whitelisted by %s", res.Whitelisted)
+	}
+	if res.Blocklisted != "" {
+		o.Printf("- blocklisted (%s)", res.Blocklisted)
+	}
+	if e := res.Cached; e != nil {
+		state := "fresh"
+		if res.Expired {
+			state = "expired"
+		}
+		o.Printf("- cached proxy=%v via %s (%s), isp %q, org %q", e.Proxy, orNone(e.Provider), state, e.Isp, e.Org)
+		o.Printf("- first seen %s, last checked %s",
+			time.UnixMilli(e.FirstSeen).UTC().Format(time.RFC3339), time.UnixMilli(e.LastChecked).UTC().Format(time.RFC3339))
+	} else {
+		o.Print("- no cached verdict")
+	}
+}
+
+// VpnPurge removes cached verdicts of an address or range.
+type VpnPurge struct {
+	Sub    cmd.SubCommand `cmd:"purge"`
+	Target string         `cmd:"ip_or_cidr"`
+
+	permissionAllower
+}
+
+// Run ...
+func (p VpnPurge) Run(_ cmd.Source, o *cmd.Output, _ *world.Tx) {
+	n, err := vpn.GlobalService().Purge(p.Target)
+	if err != nil {
+		o.Error(err)
+
+		return
+	}
+
+	o.Printf("Purged %d cached VPN verdicts for %s.", n, p.Target)
+}
+
+// vpnAction is the enum of actions on the VPN whitelist and bypasses.
+type vpnAction string
+
+// Type ...
+func (vpnAction) Type() string {
+	return "VpnAction"
+}
+
+// Options ...
+func (vpnAction) Options(cmd.Source) []string {
+	return []string{"add", "remove", "list"}
+}
+
+// VpnWhitelist adds, removes or lists runtime whitelist ranges.
+type VpnWhitelist struct {
+	Sub    cmd.SubCommand       `cmd:"whitelist"`
+	Action vpnAction            `cmd:"action"`
+	CIDR   cmd.Optional[string] `cmd:"cidr"`
+
+	permissionAllower
+}
+
+// Run ...
+func (w VpnWhitelist) Run(_ cmd.Source, o *cmd.Output, _ *world.Tx) {
+	svc := vpn.GlobalService()
+
+	if w.Action == "list" {
+		configured, runtime := svc.Whitelist()
+		o.Printf("Configured: %s", joinPrefixes(configured))
+		o.Printf("Added at runtime: %s", joinPrefixes(runtime))
+
+		return
+	}
+
+	cidr, ok := w.CIDR.Load()
+	if !ok {
+		o.Errorf("Please provide a CIDR range to %s.", w.Action)
+
+		return
+	}
+
+	if w.Action == "add" {
+		p, added, err := svc.AddWhitelist(cidr)
+		switch {
+		case err != nil:
+			o.Error(err)
+		case !added:
+			o.Printf("%s is already whitelisted.", p)
+		default:
+			o.Printf("Whitelisted %s.", p)
+		}
+
+		return
+	}
+
+	p, removed, err := svc.RemoveWhitelist(cidr)
+	switch {
+	case errors.Is(err, vpn.ErrConfigured):
+		o.Errorf("%s is whitelisted in config.toml and can only be removed there.", p)
+	case err != nil:
+		o.Error(err)
+	case !removed:
+		o.Errorf("%s is not whitelisted.", p)
+	default:
+		o.Printf("Removed %s from the whitelist.", p)
+	}
+}
+
+// VpnBypass grants, revokes or lists per-account VPN bypasses. Players
+// blocked by VPN detection can't join, so accounts are given by XUID.
+type VpnBypass struct {
+	Sub    cmd.SubCommand       `cmd:"bypass"`
+	Action vpnAction            `cmd:"action"`
+	XUID   cmd.Optional[string] `cmd:"xuid"`
+	Name   cmd.Optional[string] `cmd:"name"`
+
+	permissionAllower
+}
+
+// Run ...
+func (b VpnBypass) Run(src cmd.Source, o *cmd.Output, _ *world.Tx) {
+	svc := vpn.GlobalService()
+
+	if b.Action == "list" {
+		bypasses := svc.Bypasses()
+		if len(bypasses) == 0 {
+			o.Print("No accounts have a VPN bypass.")
+
+			return
+		}
+
+		xuids := make([]string, 0, len(bypasses))
+		for xuid := range bypasses {
+			xuids = append(xuids, xuid)
+		}
+		slices.Sort(xuids)
+
+		for _, xuid := range xuids {
+			by := bypasses[xuid]
+			o.Printf("- %s (%s), granted by %s", xuid, orNone(by.Name), orNone(by.GrantedBy))
+		}
+
+		return
+	}
+
+	xuid, ok := b.XUID.Load()
+	if !ok {
+		o.Errorf("Please provide the XUID to %s.", b.Action)
+
+		return
+	}
+
+	if b.Action == "add" {
+		if err := svc.GrantBypass(xuid, b.Name.LoadOr(""), src.(*player.Player).Name()); err != nil {
+			o.Error(err)
+
+			return
+		}
+		o.Printf("Granted a VPN bypass to %s.", xuid)
+
+		return
+	}
+
+	if !svc.RevokeBypass(xuid) {
+		o.Errorf("%s has no VPN bypass.", xuid)
+
+		return
+	}
+	o.Printf("Revoked the VPN bypass of %s.", xuid)
+}
+
+// joinPrefixes formats ranges for command output.
+func joinPrefixes[T fmt.Stringer](prefixes []T) string {
+	if len(prefixes) == 0 {
+		return "none"
+	}
+
+	s := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		s = append(s, p.String())
+	}
+
+	return strings.Join(s, ", ")
+}
+
+// orNone returns s, or "none" if it is empty.
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+
+	return s
+}
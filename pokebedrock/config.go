@@ -4,14 +4,25 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/df-mc/dragonfly/server"
+	"github.com/pelletier/go-toml/v2"
 	"github.com/restartfu/gophig"
 	"github.com/restartfu/gophig/codecs"
 	"github.com/sandertv/gophertunnel/minecraft/text"
 
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/afk"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/capacity"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/flood"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/scoreboard"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/vpn"
 )
 
 const (
@@ -26,6 +37,50 @@ const (
 	defaultQueueTimeout       = 15 * time.Minute
 	defaultMaxRestartTime     = 20 * time.Minute
 	defaultRestartCooldown    = 5 * time.Minute
+	defaultReportCooldown     = 2 * time.Minute
+	defaultLinkCodeLength     = 6
+	defaultLinkExpiry         = 10 * time.Minute
+	defaultScoreboardRefresh  = 5 * time.Second
+	defaultMsgRateLimit       = 4
+	defaultMsgRateLimitWindow = 5 * time.Second
+	defaultMaxIgnored         = 100
+
+	defaultChatRateLimitMessages = 4
+	defaultChatRateLimitWindow   = 5 * time.Second
+	defaultChatDuplicateWindow   = 30 * time.Second
+	defaultChatCapsMinLength     = 8
+	defaultChatCapsMaxRatio      = 0.7
+	defaultChatMaxRepeatedChars  = 4
+	defaultChatStrikesBeforeMute = 3
+	defaultChatStrikeWindow      = 2 * time.Minute
+	defaultChatAutoMuteDuration  = 10 * time.Minute
+
+	defaultVpnPositiveTTL     = 3 * 24 * time.Hour
+	defaultVpnNegativeTTL     = 14 * 24 * time.Hour
+	defaultVpnRefreshInterval = time.Minute
+	defaultVpnRefreshBatch    = 10
+	defaultVpnRefreshSeen     = 7 * 24 * time.Hour
+	defaultVpnEvictAfter      = 30 * 24 * time.Hour
+	defaultVpnReloadInterval  = 30 * time.Second
+
+	defaultRankCacheStaleWindow     = 7 * 24 * time.Hour
+	defaultRankCacheRefreshInterval = 30 * time.Second
+
+	defaultProxyIdleTimeout = time.Minute
+
+	defaultSupporterSlots = 10
+	defaultStaffSlots     = 10
+
+	defaultJoinPerIPPerMinute     = 6
+	defaultJoinPerIPBurst         = 3
+	defaultJoinPerSubnetPerMinute = 20
+	defaultJoinPerSubnetBurst     = 10
+	defaultJoinGlobalPerMinute    = 300
+	defaultJoinGlobalBurst        = 60
+	defaultJoinMaxAccountsPerIP   = 3
+	defaultJoinAttackThreshold    = 120
+	defaultJoinAttackCooldown     = 5 * time.Minute
+	defaultJoinAttackFactor       = 0.25
 
 	defaultParkourCountdownSeconds = 5
 	defaultParkourCompletionRadius = 1.25
@@ -62,6 +117,14 @@ type Config struct {
 		// at or above which AFK players will start getting kicked,
 		// longest-AFK first.
 		AFKFullnessThreshold float64
+		// AFKKickOrder decides which AFK players are kicked first, most
+		// significant criterion first: "rank" kicks the lowest rank first
+		// and "idle" kicks the longest idle first.
+		AFKKickOrder []string
+		// AFKExemptQueued exempts players waiting in a server queue from
+		// AFK kicks. Players holding the afk.exempt permission are always
+		// exempt.
+		AFKExemptQueued bool
 		// DowntimeLock blocks non–Sr. Moderator players from joining downstream
 		// servers while the network is in downtime. The hub itself stays open.
 		DowntimeLock bool
@@ -77,11 +140,221 @@ type Config struct {
 		// VpnCachePath is the file path used to persist VPN IP results.
 		// Defaults to resources/vpnResults.json
 		VpnCachePath string
-		VpnURL       string
+		// VpnURL is the ip-api URL used when no [[Vpn.Providers]] are
+		// configured.
+		VpnURL string
 		// VpnWhitelist is a list of CIDR ranges (e.g. "45.230.64.0/22")
 		// that are never treated as VPN/proxy connections. Used for
 		// residential ISP blocks the detection API misclassifies.
 		VpnWhitelist []string
+		// VpnBlocklist is a list of CIDR ranges and ASNs (e.g. "AS64500")
+		// that are always treated as VPN/proxy connections without asking
+		// a detection provider. ASNs need Vpn.AsnPath.
+		VpnBlocklist []string
+	}
+	RankCache struct {
+		// Path is the file the last known roles of every account are
+		// persisted in, used when the rank API is unavailable.
+		Path string
+		// StaleWindow is how long after the last successful fetch cached
+		// roles are still served during an outage.
+		StaleWindow util.Duration
+		// RefreshInterval is how often accounts served from the cache are
+		// fetched again in the background.
+		RefreshInterval util.Duration
+	}
+	Proxy struct {
+		// ProxyProtocol accepts PROXY protocol v2 headers from a UDP proxy
+		// in front of the hub, so the real client address is used for VPN
+		// checks, bans and join limits instead of the proxy's.
+		ProxyProtocol bool
+		// TrustedProxies holds the CIDR ranges of the proxies allowed to
+		// send headers. Headers from any other address are dropped.
+		TrustedProxies []string
+		// IdleTimeout is how long the client address of a proxied flow is
+		// remembered without traffic.
+		IdleTimeout util.Duration
+	}
+	Login struct {
+		// Deadline bounds the time spent on the VPN, rank and infliction
+		// checks of a single login, which run concurrently.
+		Deadline util.Duration
+		// VpnFailPolicy, RankFailPolicy and InflictionFailPolicy decide
+		// whether a login is allowed ("allow") or denied ("deny") when the
+		// check fails or misses the deadline.
+		VpnFailPolicy        string
+		RankFailPolicy       string
+		InflictionFailPolicy string
+	}
+	JoinLimits struct {
+		// PerIP, PerSubnet and Global are token bucket join limits: Burst
+		// joins in quick succession, refilled at PerMinute joins a minute.
+		// Subnets are /24 for IPv4 and /64 for IPv6. A PerMinute of 0
+		// disables the limit.
+		PerIP     flood.Limit
+		PerSubnet flood.Limit
+		Global    flood.Limit
+		// MaxAccountsPerIP caps the accounts online from one IP. 0 disables
+		// the cap.
+		MaxAccountsPerIP int
+		// AttackThreshold is the number of join attempts within a minute
+		// that enables attack mode, which scales every limit by
+		// AttackFactor until the rate stays below the threshold for
+		// AttackCooldown. 0 disables attack mode.
+		AttackThreshold int
+		AttackCooldown  util.Duration
+		AttackFactor    float64
+		// Whitelist is a list of CIDR ranges exempt from every join limit.
+		Whitelist []string
+	}
+	Vpn struct {
+		// Policy combines the provider verdicts: "primary" uses the first
+		// provider that answers, "any" flags an address if any provider
+		// does, "majority" if more than half of those that answered do.
+		Policy string
+		// Providers are the detection providers, in order of preference.
+		Providers []vpn.ProviderConfig
+		// PositiveTTL and NegativeTTL are how long proxy and clean verdicts
+		// are cached before the address is checked again.
+		PositiveTTL util.Duration
+		NegativeTTL util.Duration
+		// RefreshInterval is how often RefreshBatch expired cache entries
+		// are re-checked in the background.
+		RefreshInterval util.Duration
+		RefreshBatch    int
+		// RefreshSeenWithin limits background refreshes to addresses a
+		// player joined from within it, and EvictAfter drops the cached
+		// verdicts of addresses nobody joined from for that long.
+		RefreshSeenWithin util.Duration
+		EvictAfter        util.Duration
+		// DatacenterPath is a CSV dataset of datacenter ranges
+		// ("cidr,asn,organisation"), AsnPath a dataset in the same format
+		// mapping ranges to their ASN, and BlocklistPath a file of blocked
+		// ranges and ASNs, one per line. A range of the ASN dataset is
+		// only blocked if its ASN is. All are checked before any provider
+		// and reloaded within ReloadInterval of changing on disk.
+		DatacenterPath string
+		AsnPath        string
+		BlocklistPath  string
+		ReloadInterval util.Duration
+		// OverridesPath is the file whitelist ranges and per-account
+		// bypasses added by staff at runtime are stored in.
+		OverridesPath string
+	}
+	Moderation struct {
+		// Ladders are the offence categories offered by the moderate form,
+		// each with the punishments issued for repeat offences.
+		Ladders []moderation.LadderConfig
+		// AdjustInflictions enables extending and shortening current
+		// inflictions from the history form. It needs a moderation API
+		// that supports PATCH /updateInfliction, so it is off by default.
+		AdjustInflictions bool
+	}
+	Chat struct {
+		// RateLimitMessages is the number of messages a player may send
+		// within RateLimitWindow. 0 disables the rate limit.
+		RateLimitMessages int
+		RateLimitWindow   util.Duration
+		// DuplicateWindow is how long a repeated message is blocked for. 0
+		// disables duplicate detection.
+		DuplicateWindow util.Duration
+		// CapsMinLength is the minimum number of letters before the caps
+		// filter applies; messages above CapsMaxRatio upper case letters are
+		// lowercased.
+		CapsMinLength int
+		CapsMaxRatio  float64
+		// MaxRepeatedChars collapses longer runs of the same character. 0
+		// disables the flood filter.
+		MaxRepeatedChars int
+		// BlockedWords are censored as whole words, after leetspeak
+		// normalisation. BlockedPatterns are regular expressions matched
+		// against the lowercased, normalised message.
+		BlockedWords    []string
+		BlockedPatterns []string
+		// DropBlocked drops and warns on blocked words instead of censoring.
+		DropBlocked bool
+		// BlockLinks drops messages containing links to domains not listed
+		// in AllowedDomains.
+		BlockLinks     bool
+		AllowedDomains []string
+		// StrikesBeforeMute is the number of filter warnings within
+		// StrikeWindow that result in an automatic mute of AutoMuteDuration.
+		// 0 disables automatic mutes.
+		StrikesBeforeMute int
+		StrikeWindow      util.Duration
+		AutoMuteDuration  util.Duration
+	}
+	ChatFormat struct {
+		// Mentions highlights @mentions of players in the hub and plays a
+		// sound for them.
+		Mentions bool
+		// NoticeRank is the ID of the lowest rank announced joining and
+		// leaving the hub. "off" announces nobody.
+		NoticeRank string
+	}
+	Capacity struct {
+		// Reserved are slots reserved above Players.MaxCount for a rank
+		// and the ranks above it. Everybody else is refused once the hub
+		// holds MaxCount players. A MaxCount of 0 disables reservations.
+		Reserved []capacity.Reservation
+		// Displace lets a player who finds the hub full take the place of
+		// the AFK player of a lower rank picked by AFKKickOrder.
+		Displace bool
+	}
+	StaffChat struct {
+		// Prefix sends a single public chat message to staff chat when it
+		// starts with it. Empty disables the prefix; /sc always works.
+		Prefix string
+		// MirrorToAuditLog records staff chat messages in the moderation
+		// audit log.
+		MirrorToAuditLog bool
+	}
+	Profiles struct {
+		// Dir is the directory player profiles are stored in, one file per
+		// XUID.
+		Dir string
+	}
+	Link struct {
+		// CodeLength is the number of characters in /link codes.
+		CodeLength int
+		// Expiry is how long a /link code can be redeemed.
+		Expiry util.Duration
+		// Path is the file outstanding /link codes are stored in, so they
+		// can still be redeemed after a restart.
+		Path string
+	}
+	Scoreboard struct {
+		// Enabled shows players a sidebar, which they can turn off with
+		// /scoreboard or in /settings.
+		Enabled bool
+		// Lines are the lines shown, top first: "rank", "players",
+		// "queue", "parkour", "event" and "blank".
+		Lines []string
+		// RefreshInterval is how long it takes to refresh every sidebar.
+		// Players are refreshed in batches spread over the interval.
+		RefreshInterval util.Duration
+		// Events are the scheduled events, the next of which is shown. An
+		// event runs for Duration from Start, repeating Every interval if
+		// set.
+		Events []scoreboard.Event
+	}
+	PrivateMessages struct {
+		// RateLimitMessages is the number of private messages a player may
+		// send within RateLimitWindow. 0 disables the rate limit.
+		RateLimitMessages int
+		RateLimitWindow   util.Duration
+		// MaxIgnored caps the number of players on an ignore list. 0 leaves
+		// it uncapped.
+		MaxIgnored int
+	}
+	Reports struct {
+		// Path is the file player reports are stored in.
+		Path string
+		// Cooldown is the minimum time between two reports by the same
+		// player.
+		Cooldown util.Duration
+		// Categories are the report categories players pick from.
+		Categories []string
 	}
 	RestartManager struct {
 		MaxWaitTime     util.Duration
@@ -114,27 +387,42 @@ type Config struct {
 		AlertCooldown util.Duration
 	}
 	Ranks struct {
-		TrainerRoleID              string
-		ServerBoosterRoleID        string
-		SupporterRoleID            string
-		PremiumRoleID              string
-		ContentCreatorRoleID       string
-		MonthlyTournamentMVPRoleID string
-		RetiredStaffRoleID         string
-		HelperRoleID               string
-		TeamRoleID                 string
-		TranslatorRoleID           string
-		DevelopmentTeamRoleID      string
-		TrailModelerRoleID         string
-		ModelerRoleID              string
-		HeadModelerRoleID          string
-		ModeratorRoleID            string
-		SeniorModeratorRoleID      string
-		HeadModeratorRoleID        string
-		AdminRoleID                string
-		ManagerRoleID              string
-		OwnerRoleID                string
+		// Definitions are the ranks, lowest first. Each has an ID, a
+		// display name, a colour, whether its name is prefixed, the role
+		// IDs granting it, its queue priority weight (0 uses its position)
+		// and whether it may use public chat. The built-in rank IDs must
+		// all be defined and "unlinked" must come first; other ranks may be
+		// added anywhere.
+		Definitions []rank.Definition
+		// The role ID fields below belong to configs written before rank
+		// definitions. They are migrated into Definitions on load.
+		TrainerRoleID              string `toml:",omitempty"`
+		ServerBoosterRoleID        string `toml:",omitempty"`
+		SupporterRoleID            string `toml:",omitempty"`
+		PremiumRoleID              string `toml:",omitempty"`
+		ContentCreatorRoleID       string `toml:",omitempty"`
+		MonthlyTournamentMVPRoleID string `toml:",omitempty"`
+		RetiredStaffRoleID         string `toml:",omitempty"`
+		HelperRoleID               string `toml:",omitempty"`
+		TeamRoleID                 string `toml:",omitempty"`
+		TranslatorRoleID           string `toml:",omitempty"`
+		DevelopmentTeamRoleID      string `toml:",omitempty"`
+		TrailModelerRoleID         string `toml:",omitempty"`
+		ModelerRoleID              string `toml:",omitempty"`
+		HeadModelerRoleID          string `toml:",omitempty"`
+		ModeratorRoleID            string `toml:",omitempty"`
+		SeniorModeratorRoleID      string `toml:",omitempty"`
+		HeadModeratorRoleID        string `toml:",omitempty"`
+		AdminRoleID                string `toml:",omitempty"`
+		ManagerRoleID              string `toml:",omitempty"`
+		OwnerRoleID                string `toml:",omitempty"`
 	}
+	// Permissions grants permission nodes to ranks and accounts. Ranks maps
+	// a rank ID to the rank IDs it inherits from and the nodes it grants
+	// ("-node" revokes, "*" and "prefix.*" are wildcards). Overrides maps an
+	// XUID to nodes granted or revoked for that account only. Configs
+	// without rank permissions use the defaults.
+	Permissions permission.Config
 	server.UserConfig
 }
 
@@ -150,6 +438,8 @@ func DefaultConfig() Config {
 	c.PokeBedrock.AFKMarkAFK = util.Duration(defaultAFKMarkAFK)
 	c.PokeBedrock.AFKFinalWarning = util.Duration(defaultAFKFinalWarning)
 	c.PokeBedrock.AFKFullnessThreshold = defaultAFKFullnessThresh
+	c.PokeBedrock.AFKKickOrder = afk.DefaultOrder()
+	c.PokeBedrock.AFKExemptQueued = true
 	c.PokeBedrock.DowntimeLock = false
 
 	c.Service.GinAddress = ":8080"
@@ -166,6 +456,91 @@ func DefaultConfig() Config {
 
 	c.Service.GinAuthenticationKey = "secret-key"
 
+	c.Ranks.Definitions = rank.DefaultDefinitions()
+	c.Permissions = permission.DefaultConfig()
+
+	c.RankCache.Path = "resources/rankCache.json"
+	c.RankCache.StaleWindow = util.Duration(defaultRankCacheStaleWindow)
+	c.RankCache.RefreshInterval = util.Duration(defaultRankCacheRefreshInterval)
+
+	c.Proxy.TrustedProxies = []string{"127.0.0.1/32"}
+	c.Proxy.IdleTimeout = util.Duration(defaultProxyIdleTimeout)
+
+	c.Login.Deadline = util.Duration(defaultLoginDeadline)
+	c.Login.VpnFailPolicy = string(FailClosed)
+	c.Login.RankFailPolicy = string(FailClosed)
+	c.Login.InflictionFailPolicy = string(FailOpen)
+
+	c.JoinLimits.PerIP = flood.Limit{PerMinute: defaultJoinPerIPPerMinute, Burst: defaultJoinPerIPBurst}
+	c.JoinLimits.PerSubnet = flood.Limit{PerMinute: defaultJoinPerSubnetPerMinute, Burst: defaultJoinPerSubnetBurst}
+	c.JoinLimits.Global = flood.Limit{PerMinute: defaultJoinGlobalPerMinute, Burst: defaultJoinGlobalBurst}
+	c.JoinLimits.MaxAccountsPerIP = defaultJoinMaxAccountsPerIP
+	c.JoinLimits.AttackThreshold = defaultJoinAttackThreshold
+	c.JoinLimits.AttackCooldown = util.Duration(defaultJoinAttackCooldown)
+	c.JoinLimits.AttackFactor = defaultJoinAttackFactor
+
+	c.Vpn.Policy = string(vpn.PolicyPrimary)
+	c.Vpn.Providers = []vpn.ProviderConfig{
+		{Name: "ip-api", Type: vpn.ProviderIPAPI, URL: c.Service.VpnURL, Timeout: util.Duration(time.Second)},
+	}
+	c.Vpn.PositiveTTL = util.Duration(defaultVpnPositiveTTL)
+	c.Vpn.NegativeTTL = util.Duration(defaultVpnNegativeTTL)
+	c.Vpn.RefreshInterval = util.Duration(defaultVpnRefreshInterval)
+	c.Vpn.RefreshBatch = defaultVpnRefreshBatch
+	c.Vpn.RefreshSeenWithin = util.Duration(defaultVpnRefreshSeen)
+	c.Vpn.EvictAfter = util.Duration(defaultVpnEvictAfter)
+	c.Vpn.DatacenterPath = "resources/vpn/datacenters.csv"
+	c.Vpn.BlocklistPath = "resources/vpn/blocklist.txt"
+	c.Vpn.ReloadInterval = util.Duration(defaultVpnReloadInterval)
+	c.Vpn.OverridesPath = "resources/vpn/overrides.json"
+
+	c.Moderation.Ladders = []moderation.LadderConfig{
+		{Category: "spam", Steps: []string{"WARNED", "MUTED:1h", "MUTED:1d", "BANNED:7d"}, Window: "30d"},
+		{Category: "toxicity", Steps: []string{"WARNED", "MUTED:1d", "BANNED:3d", "BANNED:30d"}, Window: "90d"},
+		{Category: "cheating", Steps: []string{"BANNED:7d", "BANNED:30d", "BANNED"}},
+	}
+
+	c.Chat.RateLimitMessages = defaultChatRateLimitMessages
+	c.Chat.RateLimitWindow = util.Duration(defaultChatRateLimitWindow)
+	c.Chat.DuplicateWindow = util.Duration(defaultChatDuplicateWindow)
+	c.Chat.CapsMinLength = defaultChatCapsMinLength
+	c.Chat.CapsMaxRatio = defaultChatCapsMaxRatio
+	c.Chat.MaxRepeatedChars = defaultChatMaxRepeatedChars
+	c.Chat.BlockLinks = true
+	c.Chat.AllowedDomains = []string{"pokebedrock.com"}
+	c.Chat.StrikesBeforeMute = defaultChatStrikesBeforeMute
+	c.Chat.StrikeWindow = util.Duration(defaultChatStrikeWindow)
+	c.Chat.AutoMuteDuration = util.Duration(defaultChatAutoMuteDuration)
+
+	c.ChatFormat.Mentions = true
+	c.ChatFormat.NoticeRank = "supporter"
+
+	c.Capacity.Reserved = []capacity.Reservation{
+		{Rank: "supporter", Slots: defaultSupporterSlots},
+		{Rank: "helper", Slots: defaultStaffSlots},
+	}
+	c.Capacity.Displace = true
+
+	c.StaffChat.Prefix = "#"
+
+	c.Profiles.Dir = "resources/profiles"
+
+	c.Link.CodeLength = defaultLinkCodeLength
+	c.Link.Expiry = util.Duration(defaultLinkExpiry)
+	c.Link.Path = "resources/linkCodes.json"
+
+	c.Scoreboard.Enabled = true
+	c.Scoreboard.Lines = scoreboard.DefaultLines()
+	c.Scoreboard.RefreshInterval = util.Duration(defaultScoreboardRefresh)
+
+	c.PrivateMessages.RateLimitMessages = defaultMsgRateLimit
+	c.PrivateMessages.RateLimitWindow = util.Duration(defaultMsgRateLimitWindow)
+	c.PrivateMessages.MaxIgnored = defaultMaxIgnored
+
+	c.Reports.Path = "resources/reports.json"
+	c.Reports.Cooldown = util.Duration(defaultReportCooldown)
+	c.Reports.Categories = []string{"Cheating", "Spam", "Toxicity", "Inappropriate Name/Skin", "Other"}
+
 	c.RestartManager.MaxWaitTime = util.Duration(defaultMaxWaitTime)
 	c.RestartManager.BackoffInterval = util.Duration(defaultBackoffInterval)
 	c.RestartManager.RestartCooldown = util.Duration(defaultRestartCooldown)
@@ -198,6 +573,64 @@ func DefaultConfig() Config {
 	return c
 }
 
+// legacyRankRoles returns the role ID fields of configs written before rank
+// definitions, keyed by the built-in rank they granted.
+func (c *Config) legacyRankRoles() map[rank.Rank]*string {
+	return map[rank.Rank]*string{
+		rank.Trainer:              &c.Ranks.TrainerRoleID,
+		rank.ServerBooster:        &c.Ranks.ServerBoosterRoleID,
+		rank.Supporter:            &c.Ranks.SupporterRoleID,
+		rank.Premium:              &c.Ranks.PremiumRoleID,
+		rank.ContentCreator:       &c.Ranks.ContentCreatorRoleID,
+		rank.MonthlyTournamentMVP: &c.Ranks.MonthlyTournamentMVPRoleID,
+		rank.RetiredStaff:         &c.Ranks.RetiredStaffRoleID,
+		rank.Helper:               &c.Ranks.HelperRoleID,
+		rank.Team:                 &c.Ranks.TeamRoleID,
+		rank.Translator:           &c.Ranks.TranslatorRoleID,
+		rank.DevelopmentTeam:      &c.Ranks.DevelopmentTeamRoleID,
+		rank.TrailModeler:         &c.Ranks.TrailModelerRoleID,
+		rank.Modeler:              &c.Ranks.ModelerRoleID,
+		rank.HeadModeler:          &c.Ranks.HeadModelerRoleID,
+		rank.Moderator:            &c.Ranks.ModeratorRoleID,
+		rank.SeniorModerator:      &c.Ranks.SeniorModeratorRoleID,
+		rank.HeadModerator:        &c.Ranks.HeadModeratorRoleID,
+		rank.Admin:                &c.Ranks.AdminRoleID,
+		rank.Manager:              &c.Ranks.ManagerRoleID,
+		rank.Owner:                &c.Ranks.OwnerRoleID,
+	}
+}
+
+// migrateRanks moves the role IDs of configs written before rank
+// definitions into the definitions of the built-in ranks, starting from the
+// default definitions when there are none. It reports whether the config
+// changed.
+func migrateRanks(c *Config) bool {
+	migrated := false
+	if len(c.Ranks.Definitions) == 0 {
+		c.Ranks.Definitions = rank.DefaultDefinitions()
+		migrated = true
+	}
+
+	for r, field := range c.legacyRankRoles() {
+		if *field == "" {
+			continue
+		}
+
+		id, _ := rank.BuiltinID(r)
+		i := slices.IndexFunc(c.Ranks.Definitions, func(d rank.Definition) bool {
+			return strings.EqualFold(d.ID, id)
+		})
+		if i >= 0 && !slices.Contains(c.Ranks.Definitions[i].RoleIDs, *field) {
+			c.Ranks.Definitions[i].RoleIDs = append(c.Ranks.Definitions[i].RoleIDs, *field)
+		}
+
+		*field = ""
+		migrated = true
+	}
+
+	return migrated
+}
+
 // ParseLogLevel returns the appropriate slog.Level based on string configuration.
 // Returns an error if the provided log level string is not recognized.
 func ParseLogLevel(level string) (slog.Level, error) {
@@ -215,11 +648,46 @@ func ParseLogLevel(level string) (slog.Level, error) {
 	}
 }
 
+// configPath is the path of the config file.
+const configPath = "./config.toml"
+
+// backupFile copies the file at src to dst.
+func backupFile(src, dst string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(dst, data, 0o600)
+}
+
+// configSections returns the lowercased names of the top-level tables and
+// keys in the config file at path, so overlays can tell a missing section
+// from one whose values were all set to zero.
+func configSections(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	sections := make(map[string]bool, len(raw))
+	for k := range raw {
+		sections[strings.ToLower(k)] = true
+	}
+
+	return sections, nil
+}
+
 // ReadConfig loads the server configuration from config.toml.
 // If the file doesn't exist, it creates a new one with default values.
 // Returns the loaded configuration and any error encountered.
 func ReadConfig() (Config, error) {
-	g := gophig.NewGophig[Config]("./config.toml", codecs.TOMLMarshaler{}, os.ModePerm)
+	g := gophig.NewGophig[Config](configPath, codecs.TOMLMarshaler{}, os.ModePerm)
 
 	conf, err := g.LoadConf()
 	if os.IsNotExist(err) {
@@ -233,6 +701,14 @@ func ReadConfig() (Config, error) {
 		return Config{}, err
 	}
 
+	// Read before migrating, as saving the migrated config writes every
+	// section.
+	sections, err := configSections(configPath)
+	if err != nil {
+		return Config{}, err
+	}
+	migrated := migrateRanks(&conf)
+
 	// gophig unmarshals into a zero Config; missing TOML keys stay zero.
 	// Overlay defaults for unset watchdog durations so startup/world probes
 	// do not get context.WithTimeout(..., 0) → immediate deadline exceeded.
@@ -252,6 +728,127 @@ func ReadConfig() (Config, error) {
 	if conf.Watchdog.HeapAllocThresholdBytes == 0 {
 		conf.Watchdog.HeapAllocThresholdBytes = defaults.Watchdog.HeapAllocThresholdBytes
 	}
+	if len(conf.Permissions.Ranks) == 0 {
+		conf.Permissions.Ranks = defaults.Permissions.Ranks
+	}
+	if conf.Profiles.Dir == "" {
+		conf.Profiles.Dir = defaults.Profiles.Dir
+	}
+	if conf.Link.Path == "" {
+		conf.Link.Path = defaults.Link.Path
+	}
+	if conf.RankCache.Path == "" {
+		conf.RankCache.Path = defaults.RankCache.Path
+	}
+	if conf.RankCache.StaleWindow == 0 {
+		conf.RankCache.StaleWindow = defaults.RankCache.StaleWindow
+	}
+	if conf.RankCache.RefreshInterval == 0 {
+		conf.RankCache.RefreshInterval = defaults.RankCache.RefreshInterval
+	}
+	if conf.Proxy.IdleTimeout == 0 {
+		conf.Proxy.IdleTimeout = defaults.Proxy.IdleTimeout
+	}
+	if conf.Login.Deadline == 0 {
+		conf.Login.Deadline = defaults.Login.Deadline
+	}
+	// A config written before join limits existed has no [JoinLimits]
+	// section at all; use the defaults rather than disabling every limit.
+	if !sections["joinlimits"] {
+		conf.JoinLimits = defaults.JoinLimits
+	}
+	// Configs written before pluggable providers keep using the ip-api
+	// URL from the [Service] section.
+	if conf.Vpn.Providers == nil {
+		conf.Vpn.Providers = []vpn.ProviderConfig{{Name: "ip-api", Type: vpn.ProviderIPAPI, URL: conf.Service.VpnURL}}
+	}
+	if conf.Vpn.PositiveTTL == 0 {
+		conf.Vpn.PositiveTTL = defaults.Vpn.PositiveTTL
+	}
+	if conf.Vpn.NegativeTTL == 0 {
+		conf.Vpn.NegativeTTL = defaults.Vpn.NegativeTTL
+	}
+	if conf.Vpn.RefreshInterval == 0 {
+		conf.Vpn.RefreshInterval = defaults.Vpn.RefreshInterval
+	}
+	if conf.Vpn.RefreshBatch == 0 {
+		conf.Vpn.RefreshBatch = defaults.Vpn.RefreshBatch
+	}
+	if conf.Vpn.RefreshSeenWithin == 0 {
+		conf.Vpn.RefreshSeenWithin = defaults.Vpn.RefreshSeenWithin
+	}
+	if conf.Vpn.EvictAfter == 0 {
+		conf.Vpn.EvictAfter = defaults.Vpn.EvictAfter
+	}
+	if conf.Vpn.DatacenterPath == "" {
+		conf.Vpn.DatacenterPath = defaults.Vpn.DatacenterPath
+	}
+	if conf.Vpn.BlocklistPath == "" {
+		conf.Vpn.BlocklistPath = defaults.Vpn.BlocklistPath
+	}
+	if conf.Vpn.ReloadInterval == 0 {
+		conf.Vpn.ReloadInterval = defaults.Vpn.ReloadInterval
+	}
+	if conf.Vpn.OverridesPath == "" {
+		conf.Vpn.OverridesPath = defaults.Vpn.OverridesPath
+	}
+	if conf.Moderation.Ladders == nil {
+		conf.Moderation.Ladders = defaults.Moderation.Ladders
+	}
+	// A config written before the chat filters existed has no [Chat]
+	// section at all; enable the default filters for it rather than
+	// treating every zero value as "disabled".
+	if !sections["chat"] {
+		conf.Chat = defaults.Chat
+	}
+	// A config written before the AFK kick order existed kicked the
+	// longest idle first and exempted nobody; use the new defaults.
+	if conf.PokeBedrock.AFKKickOrder == nil {
+		conf.PokeBedrock.AFKKickOrder = defaults.PokeBedrock.AFKKickOrder
+		conf.PokeBedrock.AFKExemptQueued = defaults.PokeBedrock.AFKExemptQueued
+	}
+	// A config written before chat formatting existed has no [ChatFormat]
+	// section; highlight mentions and announce Supporter+ by default.
+	if !sections["chatformat"] {
+		conf.ChatFormat = defaults.ChatFormat
+	}
+	// A config written before reserved capacity existed has no [Capacity]
+	// section; reserve slots for Supporter+ and staff by default.
+	if !sections["capacity"] {
+		conf.Capacity = defaults.Capacity
+	}
+	// A config written before the scoreboard existed has no [Scoreboard]
+	// section; show it by default.
+	if !sections["scoreboard"] {
+		conf.Scoreboard = defaults.Scoreboard
+	}
+	// Likewise for [PrivateMessages]; keep the rate limit and ignore cap.
+	if !sections["privatemessages"] {
+		conf.PrivateMessages = defaults.PrivateMessages
+	}
+	if conf.Reports.Path == "" {
+		conf.Reports.Path = defaults.Reports.Path
+	}
+	if conf.Reports.Cooldown == 0 {
+		conf.Reports.Cooldown = defaults.Reports.Cooldown
+	}
+	if len(conf.Reports.Categories) == 0 {
+		conf.Reports.Categories = defaults.Reports.Categories
+	}
+
+	// Rewrite configs using the old per-rank role ID fields once, keeping
+	// the original next to it. This happens after the defaults above are
+	// applied, as the saved config has every section and later reads can
+	// no longer tell a missing section from one set to zero values.
+	if migrated {
+		if err := backupFile(configPath, configPath+".bak"); err != nil {
+			slog.Default().Warn("failed to back up config before migrating ranks", "error", err)
+		} else if err := g.SaveConf(conf); err != nil {
+			slog.Default().Warn("failed to save migrated rank definitions", "error", err)
+		} else {
+			slog.Default().Info("migrated rank role IDs to rank definitions", "backup", configPath+".bak")
+		}
+	}
 
 	return conf, nil
 }
@@ -0,0 +1,69 @@
+package pokebedrock
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := "[ChatFormat]\nMentions = false\n\n[capacity]\nDisplace = false\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sections, err := configSections(path)
+	if err != nil {
+		t.Fatalf("configSections() error = %v", err)
+	}
+	for _, name := range []string{"chatformat", "capacity"} {
+		if !sections[name] {
+			t.Fatalf("section %q with only zero values was not found", name)
+		}
+	}
+	if sections["scoreboard"] {
+		t.Fatal("missing section was found")
+	}
+}
+
+func TestReadConfigMigrationKeepsDefaults(t *testing.T) {
+	t.Chdir(t.TempDir())
+	data := "[Ranks]\nAdminRoleID = \"123\"\n"
+	if err := os.WriteFile(configPath, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := DefaultConfig()
+	for i := range 2 {
+		conf, err := ReadConfig()
+		if err != nil {
+			t.Fatalf("read %d: ReadConfig() error = %v", i, err)
+		}
+		if !conf.Chat.BlockLinks || conf.Chat.StrikesBeforeMute != defaults.Chat.StrikesBeforeMute {
+			t.Fatalf("read %d: Chat = %+v, want the defaults", i, conf.Chat)
+		}
+		if !conf.ChatFormat.Mentions {
+			t.Fatalf("read %d: ChatFormat = %+v, want the defaults", i, conf.ChatFormat)
+		}
+		if !reflect.DeepEqual(conf.Capacity, defaults.Capacity) {
+			t.Fatalf("read %d: Capacity = %+v, want the defaults", i, conf.Capacity)
+		}
+		if conf.JoinLimits.MaxAccountsPerIP != defaults.JoinLimits.MaxAccountsPerIP || conf.JoinLimits.PerIP != defaults.JoinLimits.PerIP {
+			t.Fatalf("read %d: JoinLimits = %+v, want the defaults", i, conf.JoinLimits)
+		}
+		if !conf.Scoreboard.Enabled || len(conf.Scoreboard.Lines) == 0 {
+			t.Fatalf("read %d: Scoreboard = %+v, want the defaults", i, conf.Scoreboard)
+		}
+		if conf.PrivateMessages != defaults.PrivateMessages {
+			t.Fatalf("read %d: PrivateMessages = %+v, want the defaults", i, conf.PrivateMessages)
+		}
+		if len(conf.Vpn.Providers) == 0 {
+			t.Fatalf("read %d: no VPN providers", i)
+		}
+		if conf.Login.Deadline != defaults.Login.Deadline {
+			t.Fatalf("read %d: Login.Deadline = %v, want %v", i, conf.Login.Deadline, defaults.Login.Deadline)
+		}
+	}
+}
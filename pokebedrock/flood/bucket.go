@@ -0,0 +1,49 @@
+package flood
+
+import (
+	"math"
+	"time"
+)
+
+// bucket is a token bucket. Tokens refill continuously at a rate per
+// second up to a burst, and every join takes one.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newBucket returns a full bucket.
+func newBucket(burst float64, now time.Time) *bucket {
+	return &bucket{tokens: burst, last: now}
+}
+
+// refill adds the tokens accumulated since the last call.
+func (b *bucket) refill(rate, burst float64, now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	}
+	// A lowered burst applies immediately, so entering attack mode also
+	// drains the tokens saved up before it.
+	b.tokens = math.Min(b.tokens, burst)
+	b.last = now
+}
+
+// allow reports whether a token is available without taking it.
+func (b *bucket) allow(rate, burst float64, now time.Time) bool {
+	b.refill(rate, burst, now)
+
+	return b.tokens >= 1
+}
+
+// take removes a token. It must only be called after allow returned true.
+func (b *bucket) take() {
+	b.tokens--
+}
+
+// full reports whether the bucket has refilled completely, so it holds no
+// state worth keeping.
+func (b *bucket) full(rate, burst float64, now time.Time) bool {
+	b.refill(rate, burst, now)
+
+	return b.tokens >= burst
+}
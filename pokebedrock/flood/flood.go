@@ -0,0 +1,544 @@
+// Package flood protects the hub against join floods. Logins are rate
+// limited per IP, per subnet and globally with token buckets, the number of
+// accounts online from a single IP is capped, and a sudden spike of joins
+// switches the limiter into an attack mode with tighter limits.
+package flood
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// global ...
+var global *Limiter
+
+// Global returns the limiter used for logins.
+func Global() *Limiter {
+	return global
+}
+
+// Reason is why a join was denied.
+type Reason string
+
+// Reason constants.
+const (
+	// ReasonNone is returned for allowed joins.
+	ReasonNone Reason = ""
+	// ReasonIP is returned when the address joins too often.
+	ReasonIP Reason = "ip"
+	// ReasonSubnet is returned when the address' subnet joins too often.
+	ReasonSubnet Reason = "subnet"
+	// ReasonGlobal is returned when the whole hub receives too many joins.
+	ReasonGlobal Reason = "global"
+	// ReasonAccounts is returned when too many accounts are already online
+	// from the address.
+	ReasonAccounts Reason = "accounts"
+)
+
+// LocaleKey returns the locale key of the disconnect message for r.
+func (r Reason) LocaleKey() string {
+	switch r {
+	case ReasonIP:
+		return "error.flood.ip"
+	case ReasonAccounts:
+		return "error.flood.accounts"
+	default:
+		return "error.flood.busy"
+	}
+}
+
+// Limit is a token bucket limit. A zero PerMinute disables the limit.
+type Limit struct {
+	// PerMinute is the sustained number of joins allowed per minute.
+	PerMinute float64
+	// Burst is the number of joins allowed in quick succession.
+	Burst int
+}
+
+// Config holds the settings of a Limiter.
+type Config struct {
+	// PerIP, PerSubnet and Global limit the joins of a single address, of
+	// its /24 (IPv4) or /64 (IPv6) subnet and of the whole hub.
+	PerIP     Limit
+	PerSubnet Limit
+	Global    Limit
+	// MaxAccountsPerIP caps the accounts online from one address. 0
+	// disables the cap.
+	MaxAccountsPerIP int
+	// AttackThreshold is the number of join attempts within a minute that
+	// switches the limiter into attack mode. 0 disables attack mode.
+	AttackThreshold int
+	// AttackCooldown is how long the join rate must stay below the
+	// threshold before attack mode ends.
+	AttackCooldown time.Duration
+	// AttackFactor scales the rates and bursts of every limit while under
+	// attack, e.g. 0.25 allows a quarter of the usual joins.
+	AttackFactor float64
+	// Whitelist holds CIDR ranges exempt from every limit, such as shared
+	// addresses of schools or events.
+	Whitelist []string
+}
+
+// Limiter decides whether joins are allowed. It is safe for concurrent use.
+type Limiter struct {
+	closed atomic.Bool
+
+	log       *slog.Logger
+	conf      Config
+	whitelist []netip.Prefix
+	stop      chan struct{}
+
+	mu      sync.Mutex
+	global  *bucket
+	ips     map[netip.Addr]*bucket
+	subnets map[netip.Prefix]*bucket
+	online  map[netip.Addr]map[string]struct{}
+	// pending holds accounts allowed to log in that haven't joined yet,
+	// with the time their slot is released if they never do.
+	pending map[netip.Addr]map[string]time.Time
+	window  rateWindow
+	attack  *attack
+}
+
+// attack is the state of an ongoing join flood, kept for its summary.
+type attack struct {
+	since time.Time
+	// calm is the last time the join rate was at or above the threshold.
+	calm     time.Time
+	peak     int
+	attempts int
+	denied   map[Reason]int
+	subnets  map[netip.Prefix]int
+}
+
+const (
+	// sweepInterval is how often idle buckets are dropped and the end of an
+	// attack is checked for when no joins arrive.
+	sweepInterval = 10 * time.Second
+	// pendingTimeout is how long an allowed login counts towards the
+	// account cap of its address before joining.
+	pendingTimeout = time.Minute
+)
+
+// NewLimiter creates a limiter, sets it as the global limiter and starts its
+// background sweeps. Invalid whitelist ranges are logged and skipped.
+func NewLimiter(log *slog.Logger, conf Config) *Limiter {
+	if conf.AttackFactor <= 0 || conf.AttackFactor > 1 {
+		conf.AttackFactor = 1
+	}
+
+	whitelist := make([]netip.Prefix, 0, len(conf.Whitelist))
+	for _, c := range conf.Whitelist {
+		p, err := netip.ParsePrefix(strings.TrimSpace(c))
+		if err != nil {
+			log.Warn("ignoring invalid join limit whitelist cidr", "cidr", c, "error", err)
+
+			continue
+		}
+		whitelist = append(whitelist, p.Masked())
+	}
+
+	l := &Limiter{
+		log:       log,
+		conf:      conf,
+		whitelist: whitelist,
+		stop:      make(chan struct{}),
+		ips:       make(map[netip.Addr]*bucket),
+		subnets:   make(map[netip.Prefix]*bucket),
+		online:    make(map[netip.Addr]map[string]struct{}),
+		pending:   make(map[netip.Addr]map[string]time.Time),
+	}
+	global = l
+
+	go l.sweepLoop()
+
+	return l
+}
+
+// Allow records a join attempt of xuid from addr and returns why it must be
+// denied, or ReasonNone if it is allowed. An allowed account counts towards
+// the account cap of addr until it joins, Release is called or
+// pendingTimeout passes, so simultaneous logins can't exceed the cap.
+func (l *Limiter) Allow(addr netip.Addr, xuid string, now time.Time) Reason {
+	addr = addr.Unmap()
+	if l.exempt(addr) {
+		return ReasonNone
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.window.add(now)
+	l.updateAttack(now)
+
+	reason := l.check(addr, xuid, now)
+	if reason == ReasonNone && l.conf.MaxAccountsPerIP > 0 {
+		accounts, ok := l.pending[addr]
+		if !ok {
+			accounts = make(map[string]time.Time)
+			l.pending[addr] = accounts
+		}
+		accounts[xuid] = now.Add(pendingTimeout)
+	}
+	if a := l.attack; a != nil {
+		a.attempts++
+		a.subnets[subnetOf(addr)]++
+		if reason != ReasonNone {
+			a.denied[reason]++
+		}
+	}
+
+	return reason
+}
+
+// exempt reports whether addr is whitelisted or a local connection.
+func (l *Limiter) exempt(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsUnspecified() {
+		return true
+	}
+
+	return slices.ContainsFunc(l.whitelist, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// check applies the account cap and the token buckets, taking a token from
+// each bucket only when all of them allow the join.
+func (l *Limiter) check(addr netip.Addr, xuid string, now time.Time) Reason {
+	if limit := l.conf.MaxAccountsPerIP; limit > 0 {
+		accounts := l.accountsLocked(addr, now)
+		if _, ok := accounts[xuid]; !ok && len(accounts) >= limit {
+			return ReasonAccounts
+		}
+	}
+
+	factor := 1.0
+	if l.attack != nil {
+		factor = l.conf.AttackFactor
+	}
+
+	type limited struct {
+		b      *bucket
+		limit  Limit
+		reason Reason
+	}
+
+	var checks []limited
+	if l.conf.PerIP.PerMinute > 0 {
+		b, ok := l.ips[addr]
+		if !ok {
+			b = newBucket(burstOf(l.conf.PerIP, factor), now)
+			l.ips[addr] = b
+		}
+		checks = append(checks, limited{b, l.conf.PerIP, ReasonIP})
+	}
+	if l.conf.PerSubnet.PerMinute > 0 {
+		subnet := subnetOf(addr)
+		b, ok := l.subnets[subnet]
+		if !ok {
+			b = newBucket(burstOf(l.conf.PerSubnet, factor), now)
+			l.subnets[subnet] = b
+		}
+		checks = append(checks, limited{b, l.conf.PerSubnet, ReasonSubnet})
+	}
+	if l.conf.Global.PerMinute > 0 {
+		if l.global == nil {
+			l.global = newBucket(burstOf(l.conf.Global, factor), now)
+		}
+		checks = append(checks, limited{l.global, l.conf.Global, ReasonGlobal})
+	}
+
+	for _, c := range checks {
+		if !c.b.allow(rateOf(c.limit, factor), burstOf(c.limit, factor), now) {
+			return c.reason
+		}
+	}
+	for _, c := range checks {
+		c.b.take()
+	}
+
+	return ReasonNone
+}
+
+// accountsLocked returns the accounts online or pending from addr, dropping
+// pending accounts whose slot expired. Caller must hold l.mu.
+func (l *Limiter) accountsLocked(addr netip.Addr, now time.Time) map[string]struct{} {
+	accounts := maps.Clone(l.online[addr])
+	if accounts == nil {
+		accounts = make(map[string]struct{})
+	}
+	for xuid, expiry := range l.pending[addr] {
+		if now.After(expiry) {
+			l.releaseLocked(addr, xuid)
+
+			continue
+		}
+		accounts[xuid] = struct{}{}
+	}
+
+	return accounts
+}
+
+// Release frees the slot held by the allowed login of xuid from addr, such
+// as when a later login check denies it.
+func (l *Limiter) Release(addr netip.Addr, xuid string) {
+	if !addr.IsValid() {
+		return
+	}
+	addr = addr.Unmap()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releaseLocked(addr, xuid)
+}
+
+// releaseLocked removes xuid from the accounts pending from addr. Caller
+// must hold l.mu.
+func (l *Limiter) releaseLocked(addr netip.Addr, xuid string) {
+	delete(l.pending[addr], xuid)
+	if len(l.pending[addr]) == 0 {
+		delete(l.pending, addr)
+	}
+}
+
+// Joined records xuid as online from addr, counting towards the account cap.
+func (l *Limiter) Joined(addr netip.Addr, xuid string) {
+	if !addr.IsValid() {
+		return
+	}
+	addr = addr.Unmap()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releaseLocked(addr, xuid)
+	accounts, ok := l.online[addr]
+	if !ok {
+		accounts = make(map[string]struct{})
+		l.online[addr] = accounts
+	}
+	accounts[xuid] = struct{}{}
+}
+
+// Left removes xuid from the accounts online from addr.
+func (l *Limiter) Left(addr netip.Addr, xuid string) {
+	addr = addr.Unmap()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.releaseLocked(addr, xuid)
+	delete(l.online[addr], xuid)
+	if len(l.online[addr]) == 0 {
+		delete(l.online, addr)
+	}
+}
+
+// UnderAttack reports whether the limiter is in attack mode.
+func (l *Limiter) UnderAttack() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.attack != nil
+}
+
+// Close stops the background sweeps.
+func (l *Limiter) Close() {
+	if l.closed.Swap(true) {
+		return
+	}
+	close(l.stop)
+}
+
+// updateAttack enters or leaves attack mode based on the join rate of the
+// last minute.
+func (l *Limiter) updateAttack(now time.Time) {
+	if l.conf.AttackThreshold <= 0 {
+		return
+	}
+
+	rate := l.window.total(now)
+	if rate >= l.conf.AttackThreshold {
+		if l.attack == nil {
+			l.attack = &attack{
+				since:   now,
+				denied:  make(map[Reason]int),
+				subnets: make(map[netip.Prefix]int),
+			}
+			l.log.Warn("join flood detected, tightening join limits",
+				"joins_per_minute", rate, "threshold", l.conf.AttackThreshold, "factor", l.conf.AttackFactor)
+		}
+		l.attack.calm = now
+		l.attack.peak = max(l.attack.peak, rate)
+
+		return
+	}
+
+	if l.attack != nil && now.Sub(l.attack.calm) >= l.conf.AttackCooldown {
+		l.logSummary(now)
+		l.attack = nil
+	}
+}
+
+// topSubnets is the number of subnets listed in an attack summary.
+const topSubnets = 5
+
+// logSummary logs the summary of the attack that just ended.
+func (l *Limiter) logSummary(now time.Time) {
+	a := l.attack
+
+	subnets := slices.SortedFunc(maps.Keys(a.subnets), func(x, y netip.Prefix) int {
+		return cmp.Compare(a.subnets[y], a.subnets[x])
+	})
+	top := make([]string, 0, topSubnets)
+	for _, s := range subnets[:min(len(subnets), topSubnets)] {
+		top = append(top, fmt.Sprintf("%s (%d)", s, a.subnets[s]))
+	}
+
+	var denied int
+	for _, n := range a.denied {
+		denied += n
+	}
+
+	l.log.Warn("join flood ended, restoring join limits",
+		"duration", now.Sub(a.since).Round(time.Second),
+		"attempts", a.attempts,
+		"denied", denied,
+		"denied_ip", a.denied[ReasonIP],
+		"denied_subnet", a.denied[ReasonSubnet],
+		"denied_global", a.denied[ReasonGlobal],
+		"denied_accounts", a.denied[ReasonAccounts],
+		"peak_joins_per_minute", a.peak,
+		"subnets", len(a.subnets),
+		"top_subnets", strings.Join(top, ", "),
+	)
+}
+
+// sweepLoop periodically drops buckets that refilled completely and ends
+// attacks once no more joins arrive.
+func (l *Limiter) sweepLoop() {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			l.sweep(now)
+		}
+	}
+}
+
+// sweep drops idle buckets and checks whether an attack has ended.
+func (l *Limiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.updateAttack(now)
+
+	factor := 1.0
+	if l.attack != nil {
+		factor = l.conf.AttackFactor
+	}
+
+	ipRate, ipBurst := rateOf(l.conf.PerIP, factor), burstOf(l.conf.PerIP, factor)
+	maps.DeleteFunc(l.ips, func(_ netip.Addr, b *bucket) bool {
+		return b.full(ipRate, ipBurst, now)
+	})
+
+	subnetRate, subnetBurst := rateOf(l.conf.PerSubnet, factor), burstOf(l.conf.PerSubnet, factor)
+	maps.DeleteFunc(l.subnets, func(_ netip.Prefix, b *bucket) bool {
+		return b.full(subnetRate, subnetBurst, now)
+	})
+
+	for addr := range l.pending {
+		l.accountsLocked(addr, now)
+	}
+}
+
+// rateOf returns the refill rate per second of limit, scaled by factor.
+func rateOf(limit Limit, factor float64) float64 {
+	return limit.PerMinute / 60 * factor
+}
+
+// burstOf returns the burst of limit scaled by factor. Every enabled limit
+// allows at least one join.
+func burstOf(limit Limit, factor float64) float64 {
+	return max(1, float64(limit.Burst)*factor)
+}
+
+// AddrOf returns the IP address of a connection, or an invalid address if
+// it has none.
+func AddrOf(a net.Addr) netip.Addr {
+	addr, err := netip.ParseAddrPort(a.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+
+	return addr.Addr().Unmap()
+}
+
+// subnetOf returns the /24 of IPv4 addresses and the /64 of IPv6 addresses,
+// the smallest range usually handed to a single customer.
+func subnetOf(addr netip.Addr) netip.Prefix {
+	bits := 64
+	if addr.Is4() {
+		bits = 24
+	}
+	p, _ := addr.Prefix(bits)
+
+	return p
+}
+
+// rateWindow counts join attempts over the last minute in one-second slots.
+type rateWindow struct {
+	slots [60]int
+	sec   int64
+}
+
+// add counts an attempt at now.
+func (w *rateWindow) add(now time.Time) {
+	w.advance(now)
+	w.slots[now.Unix()%int64(len(w.slots))]++
+}
+
+// total returns the attempts within the minute before now.
+func (w *rateWindow) total(now time.Time) int {
+	w.advance(now)
+
+	var n int
+	for _, c := range w.slots {
+		n += c
+	}
+
+	return n
+}
+
+// advance clears the slots of the seconds elapsed since the last call.
+func (w *rateWindow) advance(now time.Time) {
+	sec := now.Unix()
+	size := int64(len(w.slots))
+
+	switch {
+	case sec <= w.sec:
+		return
+	case sec-w.sec >= size:
+		clear(w.slots[:])
+	default:
+		for s := w.sec + 1; s <= sec; s++ {
+			w.slots[s%size] = 0
+		}
+	}
+	w.sec = sec
+}
@@ -0,0 +1,190 @@
+package flood
+
+import (
+	"io"
+	"log/slog"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, conf Config) *Limiter {
+	t.Helper()
+
+	l := NewLimiter(slog.New(slog.NewTextHandler(io.Discard, nil)), conf)
+	t.Cleanup(l.Close)
+
+	return l
+}
+
+func TestPerIPLimit(t *testing.T) {
+	l := newTestLimiter(t, Config{PerIP: Limit{PerMinute: 6, Burst: 2}})
+
+	now := time.Unix(1_700_000_000, 0)
+	addr := netip.MustParseAddr("203.0.113.7")
+
+	for i, want := range []Reason{ReasonNone, ReasonNone, ReasonIP} {
+		if got := l.Allow(addr, "1", now); got != want {
+			t.Fatalf("join %d: got %q, want %q", i, got, want)
+		}
+	}
+
+	// Another address is unaffected.
+	if got := l.Allow(netip.MustParseAddr("203.0.113.8"), "2", now); got != ReasonNone {
+		t.Fatalf("other address: got %q", got)
+	}
+
+	// Six joins per minute refill one token every ten seconds.
+	if got := l.Allow(addr, "1", now.Add(10*time.Second)); got != ReasonNone {
+		t.Fatalf("after refill: got %q", got)
+	}
+}
+
+func TestSubnetAndGlobalLimits(t *testing.T) {
+	l := newTestLimiter(t, Config{
+		PerSubnet: Limit{PerMinute: 1, Burst: 2},
+		Global:    Limit{PerMinute: 1, Burst: 3},
+	})
+
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		ip   string
+		want Reason
+	}{
+		{"203.0.113.1", ReasonNone},
+		{"203.0.113.2", ReasonNone},
+		{"203.0.113.3", ReasonSubnet},
+		{"198.51.100.1", ReasonNone},
+		{"192.0.2.1", ReasonGlobal},
+	}
+	for _, tt := range tests {
+		if got := l.Allow(netip.MustParseAddr(tt.ip), tt.ip, now); got != tt.want {
+			t.Errorf("Allow(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestMaxAccountsPerIP(t *testing.T) {
+	l := newTestLimiter(t, Config{MaxAccountsPerIP: 2})
+
+	now := time.Unix(1_700_000_000, 0)
+	addr := netip.MustParseAddr("203.0.113.7")
+
+	l.Joined(addr, "1")
+	l.Joined(addr, "2")
+
+	if got := l.Allow(addr, "3", now); got != ReasonAccounts {
+		t.Fatalf("third account: got %q, want %q", got, ReasonAccounts)
+	}
+	if got := l.Allow(addr, "2", now); got != ReasonNone {
+		t.Fatalf("reconnecting account: got %q", got)
+	}
+
+	l.Left(addr, "1")
+	if got := l.Allow(addr, "3", now); got != ReasonNone {
+		t.Fatalf("after leave: got %q", got)
+	}
+}
+
+func TestExempt(t *testing.T) {
+	l := newTestLimiter(t, Config{
+		PerIP:            Limit{PerMinute: 1, Burst: 1},
+		MaxAccountsPerIP: 1,
+		Whitelist:        []string{"203.0.113.0/24"},
+	})
+
+	now := time.Unix(1_700_000_000, 0)
+	addr := netip.MustParseAddr("203.0.113.7")
+	l.Joined(addr, "1")
+
+	for i := range 5 {
+		if got := l.Allow(addr, "2", now); got != ReasonNone {
+			t.Fatalf("join %d: got %q", i, got)
+		}
+	}
+}
+
+func TestAttackMode(t *testing.T) {
+	l := newTestLimiter(t, Config{
+		PerIP:           Limit{PerMinute: 60, Burst: 4},
+		AttackThreshold: 10,
+		AttackCooldown:  time.Minute,
+		AttackFactor:    0.25,
+	})
+
+	now := time.Unix(1_700_000_000, 0)
+	for i := range 10 {
+		l.Allow(netip.AddrFrom4([4]byte{198, 51, 100, byte(i)}), "x", now)
+	}
+	if !l.UnderAttack() {
+		t.Fatal("expected attack mode after threshold")
+	}
+
+	// A fresh address only gets a burst of one while under attack.
+	addr := netip.MustParseAddr("203.0.113.7")
+	if got := l.Allow(addr, "1", now); got != ReasonNone {
+		t.Fatalf("first join: got %q", got)
+	}
+	if got := l.Allow(addr, "1", now); got != ReasonIP {
+		t.Fatalf("second join: got %q, want %q", got, ReasonIP)
+	}
+
+	// The rate window empties after a minute and the cooldown ends attack
+	// mode a minute later.
+	l.sweep(now.Add(2*time.Minute + time.Second))
+	if l.UnderAttack() {
+		t.Fatal("expected attack mode to end after cooldown")
+	}
+}
+
+func TestRateWindow(t *testing.T) {
+	var w rateWindow
+
+	now := time.Unix(1_700_000_000, 0)
+	for i := range 5 {
+		w.add(now.Add(time.Duration(i) * 10 * time.Second))
+	}
+
+	if n := w.total(now.Add(40 * time.Second)); n != 5 {
+		t.Fatalf("total = %d, want 5", n)
+	}
+	if n := w.total(now.Add(65 * time.Second)); n != 4 {
+		t.Fatalf("after a slot expired: total = %d, want 4", n)
+	}
+	if n := w.total(now.Add(10 * time.Minute)); n != 0 {
+		t.Fatalf("after the window: total = %d, want 0", n)
+	}
+}
+
+func TestPendingAccounts(t *testing.T) {
+	l := newTestLimiter(t, Config{MaxAccountsPerIP: 1})
+
+	now := time.Unix(1_700_000_000, 0)
+	addr := netip.MustParseAddr("203.0.113.7")
+
+	if got := l.Allow(addr, "1", now); got != ReasonNone {
+		t.Fatalf("first login: got %q", got)
+	}
+	if got := l.Allow(addr, "2", now); got != ReasonAccounts {
+		t.Fatalf("login while another is pending: got %q, want %q", got, ReasonAccounts)
+	}
+
+	l.Release(addr, "1")
+	if got := l.Allow(addr, "2", now); got != ReasonNone {
+		t.Fatalf("login after release: got %q", got)
+	}
+
+	l.Joined(addr, "2")
+	if got := l.Allow(addr, "3", now); got != ReasonAccounts {
+		t.Fatalf("login after join: got %q, want %q", got, ReasonAccounts)
+	}
+	l.Left(addr, "2")
+
+	if got := l.Allow(addr, "3", now); got != ReasonNone {
+		t.Fatalf("login after leave: got %q", got)
+	}
+	if got := l.Allow(addr, "4", now.Add(pendingTimeout+time.Second)); got != ReasonNone {
+		t.Fatalf("login after the pending slot expired: got %q", got)
+	}
+}
@@ -0,0 +1,326 @@
+package form
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/form"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// historyPageSize is the number of inflictions listed on a single page of the
+// history form.
+const historyPageSize = 6
+
+// SendInflictionHistory fetches the full infliction history of target off the
+// world owner and sends the first page of the history form to p once it has
+// loaded.
+func SendInflictionHistory(p *player.Player, target string) {
+	p.Message(text.Colourf("<green>Loading infliction history for %s...</green>", target))
+	h := p.H()
+
+	go func() {
+		resp, err := moderation.GlobalService().InflictionOfName(context.Background(), target)
+
+		player.Do(h, func(_ *world.Tx, p *player.Player) {
+			if err != nil {
+				p.Message(text.Colourf("<red>Error while fetching inflictions of '%s'.</red>", target))
+
+				return
+			}
+
+			session.SendForm(p, NewInflictionHistory(target, resp.History(), 0))
+		})
+	}()
+}
+
+// InflictionHistory represents a paginated list of the current and past
+// inflictions of a player, sorted newest first.
+type InflictionHistory struct {
+	target  string
+	entries []moderation.HistoryEntry
+	page    int
+
+	// buttons maps button labels to the index of the entry in entries.
+	buttons map[string]int
+}
+
+// NewInflictionHistory creates a history menu showing the given page of
+// entries. Entries are expected to be sorted already.
+func NewInflictionHistory(target string, entries []moderation.HistoryEntry, page int) form.Menu {
+	pages := max(1, (len(entries)+historyPageSize-1)/historyPageSize)
+	page = min(max(page, 0), pages-1)
+
+	buttons := make(map[string]int)
+
+	var btns []form.Button
+
+	start := page * historyPageSize
+	end := min(start+historyPageSize, len(entries))
+
+	for i := start; i < end; i++ {
+		e := entries[i]
+
+		status := "<grey>Past</grey>"
+		if e.Current() {
+			status = "<red>Current</red>"
+		}
+
+		label := text.Colourf("#%d [%s] %s\n%s - %s", i+1, string(e.Type), status,
+			time.UnixMilli(e.DateInflicted).UTC().Format(time.DateOnly), e.Reason)
+		buttons[label] = i
+		btns = append(btns, form.NewButton(label, ""))
+	}
+
+	if len(entries) == 0 {
+		btns = append(btns, form.NewButton("No inflictions found", ""))
+	}
+	if page > 0 {
+		btns = append(btns, form.NewButton("Previous Page", ""))
+	}
+	if page < pages-1 {
+		btns = append(btns, form.NewButton("Next Page", ""))
+	}
+
+	f := form.NewMenu(InflictionHistory{
+		target:  target,
+		entries: entries,
+		page:    page,
+		buttons: buttons,
+	}, text.Colourf("<yellow>History of '%s'</yellow>", target))
+
+	return f.WithBody(fmt.Sprintf("Page %d/%d - %d infliction(s)", page+1, pages, len(entries))).
+		WithButtons(btns...)
+}
+
+// Submit handles the submission of the history form, either moving between
+// pages or opening the detail view of the selected infliction.
+func (h InflictionHistory) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
+	p := sub.(*player.Player)
+
+	switch b.Text {
+	case "No inflictions found":
+		return
+	case "Previous Page":
+		session.SendForm(p, NewInflictionHistory(h.target, h.entries, h.page-1))
+
+		return
+	case "Next Page":
+		session.SendForm(p, NewInflictionHistory(h.target, h.entries, h.page+1))
+
+		return
+	}
+
+	i, ok := h.buttons[b.Text]
+	if !ok {
+		p.Message(text.Colourf("<red>Infliction not found within history.</red>"))
+
+		return
+	}
+
+	session.SendForm(p, NewInflictionDetail(h.target, h.entries, h.page, i))
+}
+
+// InflictionDetail represents the detail view of a single infliction, with
+// actions to extend, shorten or revoke it while it is current. Extending and
+// shortening are only offered if the moderation API supports updates.
+type InflictionDetail struct {
+	target  string
+	entries []moderation.HistoryEntry
+	page    int
+	index   int
+}
+
+// NewInflictionDetail creates the detail view for the entry at index.
+func NewInflictionDetail(target string, entries []moderation.HistoryEntry, page, index int) form.Menu {
+	e := entries[index]
+
+	status := "Past"
+	if e.Current() {
+		status = "Current"
+	}
+
+	body := text.Colourf("<grey>Type:</grey> %s\n<grey>Status:</grey> %s\n<grey>Reason:</grey> %s\n"+
+		"<grey>Prosecutor:</grey> %s\n<grey>Inflicted:</grey> %s\n<grey>Expires:</grey> %s",
+		string(e.Type), status, e.Reason, e.Prosecutor,
+		time.UnixMilli(e.DateInflicted).UTC().Format(time.RFC3339), formatExpiry(e.ExpiryDate))
+
+	// Expired and revoked inflictions have nothing left to serve.
+	if e.Current() {
+		switch d, ok := e.Remaining(time.Now()); {
+		case !ok:
+			body += text.Colourf("\n<grey>Remaining:</grey> never expires")
+		case d > 0:
+			body += text.Colourf("\n<grey>Remaining:</grey> %s", util.FormatDuration(d))
+		}
+	}
+
+	var btns []form.Button
+	if e.Current() {
+		if moderation.UpdatesEnabled() {
+			if !e.Permanent() {
+				btns = append(btns, form.NewButton("Extend", ""))
+			}
+			btns = append(btns, form.NewButton("Shorten", ""))
+		}
+		btns = append(btns, form.NewButton("Revoke", ""))
+	}
+	btns = append(btns, form.NewButton("Back", ""))
+
+	f := form.NewMenu(InflictionDetail{
+		target:  target,
+		entries: entries,
+		page:    page,
+		index:   index,
+	}, text.Colourf("<yellow>Infliction #%d of '%s'</yellow>", index+1, target))
+
+	return f.WithBody(body).WithButtons(btns...)
+}
+
+// Submit handles the submission of the detail view.
+func (d InflictionDetail) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
+	p := sub.(*player.Player)
+	e := d.entries[d.index]
+
+	switch b.Text {
+	case "Back":
+		session.SendForm(p, NewInflictionHistory(d.target, d.entries, d.page))
+	case "Extend":
+		session.SendForm(p, NewAdjustInfliction(d.target, e.Infliction, true))
+	case "Shorten":
+		session.SendForm(p, NewAdjustInfliction(d.target, e.Infliction, false))
+	case "Revoke":
+		revokeInfliction(p, d.target, e.Infliction)
+	}
+}
+
+// AdjustInfliction represents a form for extending or shortening the expiry
+// of a current infliction by a number of minutes.
+type AdjustInfliction struct {
+	Minutes form.Input
+
+	target     string
+	infliction moderation.Infliction
+	extend     bool
+}
+
+// NewAdjustInfliction creates a form that extends (or shortens, if extend is
+// false) the given infliction. Shortening a permanent infliction gives it an
+// expiry of the entered number of minutes from now.
+func NewAdjustInfliction(target string, infliction moderation.Infliction, extend bool) form.Custom {
+	title := "Shorten"
+	if extend {
+		title = "Extend"
+	}
+
+	return form.New(AdjustInfliction{
+		Minutes: form.NewInput("Minutes:", "", "60"),
+
+		target:     target,
+		infliction: infliction,
+		extend:     extend,
+	}, text.Colourf("<yellow>%s infliction on '%s'</yellow>", title, target))
+}
+
+// Submit handles the submission of the adjust form, showing the refetched
+// history once the infliction was updated.
+func (a AdjustInfliction) Submit(sub form.Submitter, _ *world.Tx) {
+	prosecutor := sub.(*player.Player)
+
+	minutes, err := strconv.Atoi(strings.TrimSpace(a.Minutes.Value()))
+	if err != nil || minutes <= 0 {
+		prosecutor.Message(text.Colourf("<red>Invalid number of minutes provided.</red>"))
+
+		return
+	}
+
+	delta := time.Minute.Milliseconds() * int64(minutes)
+
+	var expiry int64
+
+	switch {
+	case a.extend:
+		expiry = *a.infliction.ExpiryDate + delta
+	case a.infliction.Permanent():
+		expiry = time.Now().UnixMilli() + delta
+	default:
+		expiry = *a.infliction.ExpiryDate - delta
+	}
+
+	if expiry <= time.Now().UnixMilli() {
+		prosecutor.Message(text.Colourf("<red>That would expire the infliction, revoke it instead.</red>"))
+
+		return
+	}
+
+	h := prosecutor.H()
+
+	go func() {
+		err := moderation.GlobalService().UpdateInfliction(moderation.UpdateRequest{
+			ModelRequest: moderation.ModelRequest{
+				Name:             a.target,
+				InflictionStatus: moderation.InflictionStatusCurrent,
+				Infliction:       a.infliction,
+			},
+			ExpiryDate: &expiry,
+		})
+
+		player.Do(h, func(tx *world.Tx, prosecutor *player.Player) {
+			if err != nil {
+				prosecutor.Message(text.Colourf("<red>Error while updating infliction on '%s' %s.</red>", a.target, err.Error()))
+
+				return
+			}
+
+			prosecutor.Message(text.Colourf("<green>Infliction on '%s' now expires %s.</green>", a.target, formatExpiry(&expiry)))
+			SendInflictionHistory(prosecutor, a.target)
+
+			if a.infliction.Type != moderation.InflictionMuted {
+				return
+			}
+
+			forEachOnline(tx, a.target, func(_ *player.Player, handler inflictionHandler) {
+				handler.Inflictions().SetMuteDuration(expiry)
+			})
+		})
+	}()
+}
+
+// revokeInfliction removes the infliction through the moderation API off the
+// world owner, lifts its effects if the target is online and shows the
+// refetched history.
+func revokeInfliction(prosecutor *player.Player, target string, infliction moderation.Infliction) {
+	h := prosecutor.H()
+
+	go func() {
+		err := moderation.GlobalService().RemoveInfliction(moderation.ModelRequest{
+			Name:             target,
+			InflictionStatus: moderation.InflictionStatusCurrent,
+			Infliction:       infliction,
+		})
+
+		player.Do(h, func(tx *world.Tx, prosecutor *player.Player) {
+			if err != nil {
+				prosecutor.Message(text.Colourf("<red>Error while revoking infliction on '%s'.</red>", target))
+
+				return
+			}
+
+			prosecutor.Message(text.Colourf("<green>Revoked infliction on '%s'.</green>", target))
+			SendInflictionHistory(prosecutor, target)
+
+			forEachOnline(tx, target, func(victim *player.Player, handler inflictionHandler) {
+				liftInfliction(victim, handler, infliction)
+			})
+		})
+	}()
+}
@@ -0,0 +1,28 @@
+package form
+
+import (
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player/form"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/link"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// Link shows a player their Discord link code and how to redeem it.
+type Link struct{}
+
+// NewLink creates a menu with the instructions for redeeming code, in the
+// language of p.
+func NewLink(p locale.Player, code link.Code) form.Menu {
+	expiresIn := util.FormatDuration(time.Until(code.Expires).Round(time.Second))
+
+	return form.NewMenu(Link{}, locale.TranslateFor(p, "link.form.title")).
+		WithBody(locale.TranslateFor(p, "link.form.body", code.Code, expiresIn)).
+		WithButtons(form.NewButton(locale.TranslateFor(p, "link.form.done"), ""))
+}
+
+// Submit ...
+func (Link) Submit(form.Submitter, form.Button, *world.Tx) {}
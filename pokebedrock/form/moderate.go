@@ -2,7 +2,9 @@
 package form
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,10 @@ func NewModerate(target string) form.Menu {
 	btns := []form.Button{
 		form.NewButton("Create an Infliction", ""),
 		form.NewButton("Remove an Infliction", ""),
+		form.NewButton("View Infliction History", ""),
+	}
+	if len(moderation.Ladders()) > 0 {
+		btns = append(btns, form.NewButton("Punish by Category", ""))
 	}
 
 	return f.WithButtons(btns...)
@@ -41,7 +47,7 @@ func (m Moderate) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
 
 	switch strings.ToLower(text.Clean(b.Text)) {
 	case "create an infliction":
-		p.SendForm(NewCreateInfliction(m.target))
+		session.SendForm(p, NewCreateInfliction(m.target))
 	case "remove an infliction":
 		p.Messagef("%s", text.Colourf("<green>Processing inflictions for %s...</green>", m.target))
 		h := p.H()
@@ -50,9 +56,13 @@ func (m Moderate) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
 			f := NewRemoveInfliction(m.target)
 
 			player.Do(h, func(_ *world.Tx, p *player.Player) {
-				p.SendForm(f)
+				session.SendForm(p, f)
 			})
 		}()
+	case "view infliction history":
+		SendInflictionHistory(p, m.target)
+	case "punish by category":
+		session.SendForm(p, NewPunishCategory(m.target))
 	}
 }
 
@@ -64,19 +74,24 @@ type CreateInfliction struct {
 	Reason         form.Input
 
 	target string
+	// ladder is set when the infliction is issued through an escalation
+	// ladder, so its category is recorded in the reason.
+	ladder *moderation.Ladder
+}
+
+// inflictionTypes lists the infliction types in the order they are shown in
+// the create infliction dropdown.
+var inflictionTypes = []string{
+	string(moderation.InflictionBanned),
+	string(moderation.InflictionMuted),
+	string(moderation.InflictionFrozen),
+	string(moderation.InflictionWarned),
+	string(moderation.InflictionKicked),
 }
 
 // NewCreateInfliction creates a new form for adding an infliction to the specified target player.
 // It initializes the form with dropdown options for infliction types and default input values.
 func NewCreateInfliction(target string) form.Custom {
-	inflictionTypes := []string{
-		string(moderation.InflictionBanned),
-		string(moderation.InflictionMuted),
-		string(moderation.InflictionFrozen),
-		string(moderation.InflictionWarned),
-		string(moderation.InflictionKicked),
-	}
-
 	f := form.New(CreateInfliction{
 		InflictionType: form.NewDropdown("Infliction Type:", inflictionTypes, 0),
 		Expiry:         form.NewInput("Expiry (in minutes, blank = forever):", "", "30"),
@@ -88,6 +103,30 @@ func NewCreateInfliction(target string) form.Custom {
 	return f
 }
 
+// NewCreateLadderInfliction creates a create infliction form pre-filled with
+// the next step of the given escalation ladder. The staff member may still
+// change the type, expiry and reason before submitting.
+func NewCreateLadderInfliction(target string, l moderation.Ladder, step moderation.Step, offences int) form.Custom {
+	typeIndex := slices.Index(inflictionTypes, string(step.Type))
+
+	var expiry string
+	if step.Duration > 0 {
+		expiry = strconv.Itoa(int(step.Duration.Minutes()))
+	}
+
+	f := form.New(CreateInfliction{
+		InflictionType: form.NewDropdown(fmt.Sprintf("Infliction Type (offence #%d, suggested %s):", offences+1, step),
+			inflictionTypes, max(typeIndex, 0)),
+		Expiry: form.NewInput("Expiry (in minutes, blank = forever):", expiry, "30"),
+		Reason: form.NewInput("Reason", l.Tag()+" ", "Guy was being bad"),
+
+		target: target,
+		ladder: &l,
+	}, text.Colourf("<yellow>Creating %s infliction on '%s'</yellow>", l.Category, target))
+
+	return f
+}
+
 // Submit handles the submission of the create infliction form.
 // It processes the form data, creates the infliction, and applies it to the target player if they're online.
 func (c CreateInfliction) Submit(sub form.Submitter, _ *world.Tx) {
@@ -115,10 +154,16 @@ func (c CreateInfliction) Submit(sub form.Submitter, _ *world.Tx) {
 		expiry = time.Now().UnixMilli() + time.Minute.Milliseconds()*int64(exp)
 	}
 
-	reason := c.Reason.Value()
+	reason := strings.TrimSpace(c.Reason.Value())
+	if c.ladder != nil {
+		reason = strings.TrimSpace(strings.TrimPrefix(reason, c.ladder.Tag()))
+	}
 	if reason == "" {
 		reason = "None provided"
 	}
+	if c.ladder != nil {
+		reason = c.ladder.TagReason(reason)
+	}
 
 	infliction := moderation.Infliction{
 		Type:          infType,
@@ -193,7 +238,7 @@ type RemoveInfliction struct {
 // NewRemoveInfliction creates a new form for removing inflictions from the specified target player.
 // It fetches current inflictions and displays them as buttons.
 func NewRemoveInfliction(target string) form.Menu {
-	resp, err := moderation.GlobalService().InflictionOfName(target)
+	resp, err := moderation.GlobalService().InflictionOfName(context.Background(), target)
 	if err != nil {
 		return form.NewMenu(RemoveInfliction{target: target}, text.Colourf("<yellow>Moderating '%s'</yellow>", target)).
 			WithButtons(form.NewButton("Error fetching inflictions", ""))
@@ -256,29 +301,43 @@ func (r RemoveInfliction) Submit(sub form.Submitter, b form.Button, _ *world.Tx)
 
 			prosecutor.Message(text.Colourf("<green>Removed infliction on '%s'.</green>", r.target))
 
-			for ent := range tx.Players() {
-				victim := ent.(*player.Player)
-				if !strings.EqualFold(victim.Name(), r.target) {
-					continue
-				}
-
-				handler, ok := victim.Handler().(inflictionHandler)
-				if !ok {
-					continue
-				}
-
-				switch infliction.Type {
-				case moderation.InflictionMuted:
-					handler.Inflictions().SetMuted(false)
-				case moderation.InflictionFrozen:
-					handler.Inflictions().SetFrozen(false)
-					victim.SetMobile()
-				}
-			}
+			forEachOnline(tx, r.target, func(victim *player.Player, handler inflictionHandler) {
+				liftInfliction(victim, handler, infliction)
+			})
 		})
 	}()
 }
 
+// forEachOnline calls f for every online player named target whose handler
+// tracks inflictions. Must be called on the world owner.
+func forEachOnline(tx *world.Tx, target string, f func(victim *player.Player, handler inflictionHandler)) {
+	for ent := range tx.Players() {
+		victim := ent.(*player.Player)
+		if !strings.EqualFold(victim.Name(), target) {
+			continue
+		}
+
+		handler, ok := victim.Handler().(inflictionHandler)
+		if !ok {
+			continue
+		}
+
+		f(victim, handler)
+	}
+}
+
+// liftInfliction undoes the in-hub effects of a removed infliction on an
+// online player.
+func liftInfliction(victim *player.Player, handler inflictionHandler, infliction moderation.Infliction) {
+	switch infliction.Type {
+	case moderation.InflictionMuted:
+		handler.Inflictions().SetMuted(false)
+	case moderation.InflictionFrozen:
+		handler.Inflictions().SetFrozen(false)
+		victim.SetMobile()
+	}
+}
+
 // inflictionHandler defines the interface for handlers that can manage player inflictions.
 // It requires an Inflictions method that returns the player's infliction state container.
 type inflictionHandler interface {
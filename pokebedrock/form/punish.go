@@ -0,0 +1,79 @@
+package form
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/form"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// PunishCategory represents a menu for picking the offence category of an
+// infliction. The next step of the category's escalation ladder is worked
+// out from the target's history and pre-filled in the create form.
+type PunishCategory struct {
+	target string
+}
+
+// NewPunishCategory creates the offence category menu for the target player.
+func NewPunishCategory(target string) form.Menu {
+	f := form.NewMenu(PunishCategory{target: target}, text.Colourf("<yellow>Punishing '%s'</yellow>", target))
+
+	ladders := moderation.Ladders()
+	btns := make([]form.Button, 0, len(ladders))
+
+	for _, l := range ladders {
+		steps := make([]string, 0, len(l.Steps))
+		for _, s := range l.Steps {
+			steps = append(steps, s.String())
+		}
+
+		btns = append(btns, form.NewButton(fmt.Sprintf("%s\n%s", l.Category, strings.Join(steps, " > ")), ""))
+	}
+
+	return f.WithBody("Select the offence category.").WithButtons(btns...)
+}
+
+// Submit handles the submission of the category menu. The target's history
+// is fetched off the world owner before the create form is sent.
+func (c PunishCategory) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
+	p := sub.(*player.Player)
+
+	category, _, _ := strings.Cut(b.Text, "\n")
+
+	l, ok := moderation.LadderOf(category)
+	if !ok {
+		p.Message(text.Colourf("<red>Unknown offence category '%s'.</red>", category))
+
+		return
+	}
+
+	p.Message(text.Colourf("<green>Checking previous %s offences of %s...</green>", l.Category, c.target))
+	h := p.H()
+
+	go func() {
+		resp, err := moderation.GlobalService().InflictionOfName(context.Background(), c.target)
+
+		player.Do(h, func(_ *world.Tx, p *player.Player) {
+			if err != nil {
+				p.Message(text.Colourf("<red>Error while fetching inflictions of '%s'.</red>", c.target))
+
+				return
+			}
+
+			history := make([]moderation.Infliction, 0, len(resp.CurrentInflictions)+len(resp.PastInflictions))
+			history = append(history, resp.CurrentInflictions...)
+			history = append(history, resp.PastInflictions...)
+
+			now := time.Now()
+			session.SendForm(p, NewCreateLadderInfliction(c.target, l, l.Next(history, now), l.Offences(history, now)))
+		})
+	}()
+}
@@ -0,0 +1,161 @@
+package form
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/form"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/report"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// maxReportReasonLength caps the length of a report reason, in characters.
+const maxReportReasonLength = 200
+
+// Report represents the form a player fills out to report another player.
+type Report struct {
+	Category form.Dropdown
+	Reason   form.Input
+
+	targetName string
+	targetXUID string
+}
+
+// NewReport creates a report form against the target in the language of p,
+// pre-filling the reason passed to the /report command.
+func NewReport(p locale.Player, targetName, targetXUID, reason string) form.Custom {
+	return form.New(Report{
+		Category: form.NewDropdown(locale.TranslateFor(p, "report.form.category"), report.Global().Categories(), 0),
+		Reason: form.NewInput(locale.TranslateFor(p, "report.form.reason"), reason,
+			locale.TranslateFor(p, "report.form.reason.placeholder")),
+
+		targetName: targetName,
+		targetXUID: targetXUID,
+	}, locale.TranslateFor(p, "report.form.title", targetName))
+}
+
+// Submit handles the submission of the report form, storing the report and
+// notifying online staff.
+func (r Report) Submit(sub form.Submitter, tx *world.Tx) {
+	p := sub.(*player.Player)
+	m := report.Global()
+
+	if remaining := m.Cooldown(p.XUID()); remaining > 0 {
+		p.Message(locale.TranslateFor(p, "report.cooldown", util.FormatDuration(remaining)))
+
+		return
+	}
+
+	reason := strings.TrimSpace(r.Reason.Value())
+	if reason == "" {
+		p.Message(locale.TranslateFor(p, "report.reason.empty"))
+
+		return
+	}
+	if runes := []rune(reason); len(runes) > maxReportReasonLength {
+		reason = string(runes[:maxReportReasonLength])
+	}
+
+	rep, err := m.Submit(report.Report{
+		ReporterName: p.Name(),
+		ReporterXUID: p.XUID(),
+		TargetName:   r.targetName,
+		TargetXUID:   r.targetXUID,
+		Category:     r.Category.Options[r.Category.Value()],
+		Reason:       reason,
+	})
+	if err != nil {
+		p.Message(text.Colourf("<red>%s</red>", err.Error()))
+
+		return
+	}
+
+	p.Message(locale.TranslateFor(p, "report.submitted", rep.TargetName))
+	report.NotifyStaff(tx, rep)
+}
+
+// Reports represents the staff menu listing open reports, newest first.
+type Reports struct {
+	reports map[string]report.Report
+}
+
+// NewReports creates the open reports menu.
+func NewReports() form.Menu {
+	reports := make(map[string]report.Report)
+
+	var btns []form.Button
+
+	for _, r := range report.Global().OpenReports() {
+		label := fmt.Sprintf("#%d %s [%s]\nby %s, %s ago", r.ID, r.TargetName, r.Category, r.ReporterName,
+			util.FormatDuration(time.Since(time.UnixMilli(r.Created))))
+		reports[label] = r
+		btns = append(btns, form.NewButton(label, ""))
+	}
+	if len(btns) == 0 {
+		btns = append(btns, form.NewButton("No open reports", ""))
+	}
+
+	return form.NewMenu(Reports{reports: reports}, text.Colourf("<red>Open Reports</red>")).WithButtons(btns...)
+}
+
+// Submit opens the detail view of the selected report.
+func (r Reports) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
+	p := sub.(*player.Player)
+
+	rep, ok := r.reports[b.Text]
+	if !ok {
+		return
+	}
+
+	session.SendForm(p, NewReportDetail(rep))
+}
+
+// ReportDetail represents the detail view of a single report, with a
+// shortcut to moderate the reported player.
+type ReportDetail struct {
+	report report.Report
+
+	TakeAction form.Button
+	Resolve    form.Button
+}
+
+// NewReportDetail creates the detail view of the given report.
+func NewReportDetail(r report.Report) form.Modal {
+	body := text.Colourf("<grey>Reported:</grey> %s\n<grey>Reporter:</grey> %s\n<grey>Category:</grey> %s\n"+
+		"<grey>Reason:</grey> %s\n<grey>Filed:</grey> %s",
+		r.TargetName, r.ReporterName, r.Category, r.Reason,
+		time.UnixMilli(r.Created).UTC().Format(time.RFC3339))
+
+	return form.NewModal(ReportDetail{
+		report:     r,
+		TakeAction: form.NewButton("Take Action", ""),
+		Resolve:    form.NewButton("Resolve", ""),
+	}, text.Colourf("<red>Report #%d</red>", r.ID)).WithBody(body)
+}
+
+// Submit opens the moderate form for the reported player when taking
+// action, leaving the report open, or resolves the report.
+func (d ReportDetail) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
+	p := sub.(*player.Player)
+
+	if b == d.TakeAction {
+		session.SendForm(p, NewModerate(d.report.TargetName))
+
+		return
+	}
+
+	if !report.Global().Resolve(d.report.ID, p.Name()) {
+		p.Message(text.Colourf("<yellow>Report #%d was already handled.</yellow>", d.report.ID))
+
+		return
+	}
+
+	p.Message(text.Colourf("<green>Resolved report #%d.</green>", d.report.ID))
+}
@@ -9,8 +9,8 @@ import (
 	"github.com/sandertv/gophertunnel/minecraft/text"
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/queue"
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/settings"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/srv"
@@ -49,15 +49,15 @@ func (f ServerConfirm) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
 	cfg := f.srv.Config()
 	highestRank := h.Ranks().HighestRank()
 
-	if settings.DowntimeLock() && !h.Ranks().HasRankOrHigher(rank.SeniorModerator) {
-		p.Message(locale.Translate("downtime.lock.denied"))
+	if settings.DowntimeLock() && !h.Ranks().HasPermission(p.XUID(), permission.QueueBypassDowntime) {
+		p.Message(locale.TranslateFor(p, "downtime.lock.denied"))
 
 		return
 	}
 
-	// Check if beta lock is enabled, if so, only Supporters and staff can join
-	if cfg.BetaLock && !(h.Ranks().HasRank(rank.Supporter) || highestRank >= rank.Moderator) {
-		p.Message(locale.Translate("queue.beta.lock"))
+	// Check if beta lock is enabled, if so, only players allowed to bypass it can join
+	if cfg.BetaLock && !h.Ranks().HasPermission(p.XUID(), permission.QueueBypassBeta) {
+		p.Message(locale.TranslateFor(p, "queue.beta.lock"))
 
 		return
 	}
@@ -8,6 +8,7 @@ import (
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/sandertv/gophertunnel/minecraft/text"
 
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/srv"
 )
 
@@ -51,5 +52,5 @@ func (serverNavigator) Submit(sub form.Submitter, b form.Button, _ *world.Tx) {
 		return
 	}
 
-	p.SendForm(NewServerConfirm(server))
+	session.SendForm(p, NewServerConfirm(server))
 }
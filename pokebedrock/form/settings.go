@@ -0,0 +1,60 @@
+package form
+
+import (
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/form"
+	"github.com/df-mc/dragonfly/server/world"
+	"golang.org/x/text/language"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/scoreboard"
+)
+
+// Settings represents the form a player changes their personal settings in.
+type Settings struct {
+	Language   form.Dropdown
+	Scoreboard form.Toggle
+
+	languages []language.Tag
+}
+
+// NewSettings creates a settings form for p, with their current choices
+// selected.
+func NewSettings(p *player.Player) form.Custom {
+	languages := locale.Languages()
+	options := make([]string, 0, len(languages)+1)
+	options = append(options, locale.TranslateFor(p, "settings.language.automatic"))
+
+	selected := 0
+	current, _ := profile.Global().Preference(p.XUID(), locale.PreferenceKey)
+	for i, lang := range languages {
+		options = append(options, locale.Name(lang))
+		if lang.String() == current {
+			selected = i + 1
+		}
+	}
+
+	return form.New(Settings{
+		Language:   form.NewDropdown(locale.TranslateFor(p, "settings.language"), options, selected),
+		Scoreboard: form.NewToggle(locale.TranslateFor(p, "settings.scoreboard"), scoreboard.Global().Enabled(p)),
+		languages:  languages,
+	}, locale.TranslateFor(p, "settings.title"))
+}
+
+// Submit stores the choices made in the settings form.
+func (s Settings) Submit(sub form.Submitter, _ *world.Tx) {
+	p := sub.(*player.Player)
+
+	lang := language.Und
+	if i := s.Language.Value(); i > 0 && i <= len(s.languages) {
+		lang = s.languages[i-1]
+	}
+	locale.SetLanguage(p.XUID(), lang)
+
+	if sb := scoreboard.Global(); sb.Available() && s.Scoreboard.Value() != sb.Enabled(p) {
+		sb.SetEnabled(p, s.Scoreboard.Value())
+	}
+
+	p.Message(locale.TranslateL(locale.LanguageOf(p.XUID(), p.Locale()), "settings.saved"))
+}
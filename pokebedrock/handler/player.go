@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"slices"
 	"strings"
 	"time"
 
@@ -11,19 +14,36 @@ import (
 	"github.com/df-mc/dragonfly/server/player"
 	"github.com/df-mc/dragonfly/server/player/chat"
 	"github.com/df-mc/dragonfly/server/world"
+	"github.com/df-mc/dragonfly/server/world/sound"
 	"github.com/go-gl/mathgl/mgl64"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/chatfilter"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/chatformat"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/flood"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/form"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/hider"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/kit"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/parkour"
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/privatemsg"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/scoreboard"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/settings"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/slapper"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/staffchat"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
 )
 
+// profileLoadTimeout bounds the wait for the profile of a joining player,
+// which is normally already loaded during login, before they are welcomed
+// with the default preferences.
+const profileLoadTimeout = time.Second
+
 // PlayerHandler ...
 type PlayerHandler struct {
 	ranks       *session.Ranks
@@ -64,38 +84,68 @@ func NewPlayerHandler(p *player.Player) *PlayerHandler {
 func (h *PlayerHandler) HandleJoin(p *player.Player, w *world.World) {
 	p.Inventory().Handle(InventoryHandler{})
 	p.Teleport(w.Spawn().Vec3Middle())
-	p.SetNameTag(h.Ranks().HighestRank().NameTag(p.Name()))
+	p.SetNameTag(session.NameTag(p, h.Ranks().HighestRank()))
+
+	for _, s := range slapper.All() {
+		s.SendAnimation(p)
+	}
+
+	hider.Global().HandleJoin(p)
+	flood.Global().Joined(flood.AddrOf(p.Addr()), p.XUID())
+	profile.Global().Joined(p.XUID(), p.Name())
+	scoreboard.Global().HandleJoin(p)
+
+	// The kit and messages use the language of the profile, which is
+	// normally loaded during login. Otherwise wait for it off the world
+	// owner.
+	if profile.Global().Loaded(p.XUID()) {
+		welcome(p)
+
+		return
+	}
 
+	xuid, handle := p.XUID(), p.H()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), profileLoadTimeout)
+		defer cancel()
+
+		if _, err := profile.Global().Load(ctx, xuid); err != nil {
+			slog.Default().Warn("profile not loaded in time", "xuid", xuid, "error", err)
+		}
+		player.Do(handle, func(_ *world.Tx, p *player.Player) {
+			welcome(p)
+		})
+	}()
+}
+
+// welcome gives a joined player the lobby kit and sends the welcome
+// messages in their language.
+func welcome(p *player.Player) {
 	kit.Apply(kit.Lobby, p)
 
-	msg := locale.Translate("welcome.hub")
+	msg := locale.TranslateFor(p, "welcome.hub")
 	for l := range strings.SplitSeq(msg, "<new-line>") {
 		p.Message(l)
 	}
 
 	if settings.DowntimeLock() {
-		for l := range strings.SplitSeq(locale.Translate("downtime.lock.notice"), "<new-line>") {
+		for l := range strings.SplitSeq(locale.TranslateFor(p, "downtime.lock.notice"), "<new-line>") {
 			p.Message(l)
 		}
 	}
-
-	for _, s := range slapper.All() {
-		s.SendAnimation(p)
-	}
-
-	hider.Global().HandleJoin(p)
 }
 
 // HandleItemUse ...
 func (h *PlayerHandler) HandleItemUse(ctx *player.Context) {
 	p := ctx.Player()
+	session.Active(p)
 	it, _ := p.HeldItems()
 
 	if id, exists := it.Value("lobby"); exists {
 		action, _ := id.(string)
 		switch action {
 		case "navigator":
-			p.SendForm(form.NewServerNavigator())
+			session.SendForm(p, form.NewServerNavigator())
 		case "spawn":
 			w := p.Tx().World()
 			p.Teleport(w.Spawn().Vec3Middle())
@@ -103,18 +153,20 @@ func (h *PlayerHandler) HandleItemUse(ctx *player.Context) {
 			lastFetch := h.Ranks().LastRankFetch()
 			if time.Since(lastFetch) < time.Second*5 {
 				remaining := time.Second*5 - time.Since(lastFetch)
-				p.SendJukeboxPopup(locale.Translate("rank.refetch.wait", fmt.Sprintf("%.1f", remaining.Seconds())))
+				p.SendJukeboxPopup(locale.TranslateFor(p, "rank.refetch.wait", fmt.Sprintf("%.1f", remaining.Seconds())))
 				return
 			}
 
 			h.ranks.SetLastRankFetch(time.Now())
-			p.SendJukeboxPopup(locale.Translate("rank.fetching"))
+			p.SendJukeboxPopup(locale.TranslateFor(p, "rank.fetching"))
 
 			xuid := p.XUID()
 			handle := p.H()
 			go func() {
 				h.Ranks().Load(xuid, handle)
 			}()
+		case "settings":
+			session.SendForm(p, form.NewSettings(p))
 		case "toggle-visibility":
 			hider.Global().Toggle(p)
 		}
@@ -131,25 +183,95 @@ func (h *PlayerHandler) HandleItemUse(ctx *player.Context) {
 func (h *PlayerHandler) HandleChat(ctx *player.Context, message *string) {
 	p := ctx.Player()
 	ctx.Cancel()
+	session.Active(p)
 
-	if h.inflictions.Muted() {
-		dur := h.inflictions.MuteDuration()
-		if dur == 0 || dur >= time.Now().UnixMilli() {
-			p.Message(locale.Translate("mute.message"))
+	if h.inflictions.MutedNow() {
+		p.Message(locale.TranslateFor(p, "mute.message"))
+
+		return
+	}
+
+	// Only allow ranks that are permitted to use public chat
+	if !h.Ranks().HighestRank().CanChat() {
+		p.Message(locale.TranslateFor(p, "chat.discord.linked"))
+
+		return
+	}
+
+	if h.Ranks().HasPermission(p.XUID(), permission.StaffChat) {
+		if msg, ok := staffchat.Global().Route(p.XUID(), *message); ok {
+			staffchat.Global().Send(p.Tx(), p, h.Ranks().HighestRank(), msg)
 
 			return
 		}
 	}
 
-	// Only allow users with ranks other than Trainer to chat
-	if h.Ranks().HighestRank() == rank.UnLinked {
-		p.Message(locale.Translate("chat.discord.linked"))
+	v := chatfilter.Global().Check(chatfilter.Message{XUID: p.XUID(), Text: *message, Time: time.Now()})
+	switch v.Action {
+	case chatfilter.ActionDrop, chatfilter.ActionWarn:
+		p.Message(locale.TranslateFor(p, v.Reason))
+
+		return
+	case chatfilter.ActionMute:
+		h.AutoMute(p, v)
 
 		return
 	}
 
-	msg := h.Ranks().HighestRank().Chat(p.Name(), *message)
-	_, _ = chat.Global.WriteString(msg)
+	body := chatformat.Colours(v.Text, h.Ranks().HasPermission(p.XUID(), permission.ChatColour))
+	var mentioned []*player.Player
+	if chatformat.Global().MentionsEnabled() {
+		body, mentioned = chatformat.Mentions(p.Tx(), body, text.Grey)
+	}
+
+	msg := h.Ranks().HighestRank().Chat(p.Name(), body)
+	// Players are messaged one by one so ignore lists are respected, which
+	// skips the console subscribed to the global chat.
+	if chat.Global.Subscribed(chat.StdoutSubscriber{}) {
+		chat.StdoutSubscriber{}.Message(msg)
+	}
+	for ent := range p.Tx().Players() {
+		recipient := ent.(*player.Player)
+		if privatemsg.Global().Ignoring(recipient.XUID(), p.XUID()) {
+			continue
+		}
+		recipient.Message(msg)
+		if recipient != p && slices.Contains(mentioned, recipient) {
+			recipient.PlaySound(sound.Experience{})
+		}
+	}
+}
+
+// AutoMute mutes a player who collected too many chat filter strikes and
+// records the mute through the moderation service off the world owner.
+func (h *PlayerHandler) AutoMute(p *player.Player, v chatfilter.Verdict) {
+	now := time.Now()
+	expiry := now.Add(v.MuteDuration).UnixMilli()
+
+	h.inflictions.SetMuteDuration(expiry)
+	h.inflictions.SetMuted(true)
+	p.Message(locale.TranslateFor(p, "chat.filter.muted", util.FormatDuration(v.MuteDuration)))
+
+	reason := text.Clean(locale.Translate(v.Reason)) // Recorded in English for staff.
+	infliction := moderation.Infliction{
+		Type:          moderation.InflictionMuted,
+		DateInflicted: now.UnixMilli(),
+		ExpiryDate:    &expiry,
+		Reason:        moderation.Ladder{Category: v.Category}.TagReason("Automatic mute: " + reason),
+		Prosecutor:    "System",
+	}
+	name, xuid := p.Name(), p.XUID()
+
+	go func() {
+		if err := moderation.GlobalService().AddInfliction(moderation.ModelRequest{
+			XUID:             xuid,
+			Name:             name,
+			InflictionStatus: moderation.InflictionStatusCurrent,
+			Infliction:       infliction,
+		}); err != nil {
+			slog.Default().Error("error while recording automatic mute", "name", name, "error", err)
+		}
+	}()
 }
 
 // HandleFoodLoss ...
@@ -170,10 +292,12 @@ func (h *PlayerHandler) HandleBlockBreak(ctx *player.Context, _ cube.Pos, _ *[]i
 // HandleItemUseOnBlock ...
 func (h *PlayerHandler) HandleItemUseOnBlock(ctx *player.Context, _ cube.Pos, _ cube.Face, _ mgl64.Vec3) {
 	ctx.Cancel()
+	session.Active(ctx.Player())
 }
 
 // HandleItemUseOnEntity ...
 func (h *PlayerHandler) HandleItemUseOnEntity(ctx *player.Context, e world.Entity) {
+	session.Active(ctx.Player())
 	if h.interactHubNPC(ctx.Player(), e) {
 		ctx.Cancel()
 	}
@@ -181,6 +305,7 @@ func (h *PlayerHandler) HandleItemUseOnEntity(ctx *player.Context, e world.Entit
 
 // HandleAttackEntity ...
 func (h *PlayerHandler) HandleAttackEntity(ctx *player.Context, e world.Entity, _ *float64, _ *float64, _ *bool) {
+	session.Active(ctx.Player())
 	if h.interactHubNPC(ctx.Player(), e) {
 		ctx.Cancel()
 	}
@@ -219,23 +344,36 @@ func (h *PlayerHandler) HandleMove(ctx *player.Context, pos mgl64.Vec3, rot cube
 	p := ctx.Player()
 
 	delta := pos.Sub(p.Position())
-	if mgl64.FloatEqual(delta.X(), 0) && mgl64.FloatEqual(delta.Z(), 0) {
-		// No horizontal movement, just return.
+	moved := !mgl64.FloatEqual(delta.X(), 0) || !mgl64.FloatEqual(delta.Z(), 0)
+	prev := p.Rotation()
+	rotated := !mgl64.FloatEqual(rot.Yaw(), prev.Yaw()) || !mgl64.FloatEqual(rot.Pitch(), prev.Pitch())
+	if !moved && !rotated {
+		// Only vertical movement, such as falling, which isn't activity.
 		return
 	}
 
-	parkour.Global().HandleMove(p, pos)
+	if moved {
+		parkour.Global().HandleMove(p, pos)
+	}
 
+	session.Active(p)
 	movement := h.movement
-	movement.SetLastMoveTime(time.Now())
 	movement.SetLastPosition(pos)
 	movement.SetLastRotation(rot)
 }
 
 // HandleQuit ...
 func (h *PlayerHandler) HandleQuit(p *player.Player) {
+	chatfilter.Global().Forget(p.XUID())
+	staffchat.Global().Forget(p.XUID())
+	privatemsg.Global().Forget(p.XUID())
+	chatformat.Global().HandleQuit(p, h.Ranks().HighestRank())
+	session.ForgetRanks(p.XUID())
 	parkour.Global().HandleQuit(p)
 	hider.Global().HandleQuit(p)
+	flood.Global().Left(flood.AddrOf(p.Addr()), p.XUID())
+	profile.Global().Quit(p.XUID())
+	scoreboard.Global().HandleQuit(p)
 }
 
 // Ranks ...
@@ -6,7 +6,8 @@ import (
 	"github.com/df-mc/dragonfly/server/entity/effect"
 	"github.com/df-mc/dragonfly/server/item"
 	"github.com/df-mc/dragonfly/server/player"
-	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
 )
 
 const (
@@ -26,19 +27,22 @@ var Lobby lobby
 type lobby struct{}
 
 // Items ...
-func (lobby) Items(*player.Player) (items [inventorySlots]item.Stack) {
+func (lobby) Items(p *player.Player) (items [inventorySlots]item.Stack) {
 	return [inventorySlots]item.Stack{
 		0: item.NewStack(item.Spyglass{}, 1).
-			WithCustomName(text.Colourf("<yellow>Toggle Players</yellow>")).
+			WithCustomName(locale.TranslateFor(p, "kit.players.toggle")).
 			WithValue("lobby", "toggle-visibility"),
+		5: item.NewStack(item.Book{}, 1).
+			WithCustomName(locale.TranslateFor(p, "kit.settings")).
+			WithValue("lobby", "settings"),
 		6: item.NewStack(item.Clock{}, 1).
-			WithCustomName(text.Colourf("<green>Re-Fetch Synced Rank</green>")).
+			WithCustomName(locale.TranslateFor(p, "kit.rank.sync")).
 			WithValue("lobby", "sync-rank"),
 		7: item.NewStack(item.NetherStar{}, 1).
-			WithCustomName(text.Colourf("<yellow>Back to Spawn</yellow>")).
+			WithCustomName(locale.TranslateFor(p, "kit.spawn")).
 			WithValue("lobby", "spawn"),
 		8: item.NewStack(item.Compass{}, 1).
-			WithCustomName(text.Colourf("<purple>Server Navigator</purple>")).
+			WithCustomName(locale.TranslateFor(p, "kit.navigator")).
 			WithValue("lobby", "navigator"),
 	}
 }
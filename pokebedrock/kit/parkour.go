@@ -3,7 +3,8 @@ package kit
 import (
 	"github.com/df-mc/dragonfly/server/item"
 	"github.com/df-mc/dragonfly/server/player"
-	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
 )
 
 // Parkour ...
@@ -13,16 +14,16 @@ var Parkour parkour
 type parkour struct{}
 
 // Items ...
-func (parkour) Items(*player.Player) (items [inventorySlots]item.Stack) {
+func (parkour) Items(p *player.Player) (items [inventorySlots]item.Stack) {
 	return [inventorySlots]item.Stack{
 		0: item.NewStack(item.Spyglass{}, 1).
-			WithCustomName(text.Colourf("<yellow>Toggle Players</yellow>")).
+			WithCustomName(locale.TranslateFor(p, "kit.players.toggle")).
 			WithValue("lobby", "toggle-visibility"),
 		7: item.NewStack(item.FireCharge{}, 1).
-			WithCustomName(text.Colourf("<red>Quit Parkour</red>")).
+			WithCustomName(locale.TranslateFor(p, "kit.parkour.quit")).
 			WithValue("parkour", "quit"),
 		8: item.NewStack(item.Feather{}, 1).
-			WithCustomName(text.Colourf("<aqua>Restart</aqua>")).
+			WithCustomName(locale.TranslateFor(p, "kit.parkour.restart")).
 			WithValue("parkour", "restart"),
 	}
 }
@@ -0,0 +1,272 @@
+// Package link issues the one-time codes players use to link their Discord
+// account to their Xbox account from inside the hub.
+package link
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+const (
+	// codeAlphabet holds the characters codes are made of. Characters that
+	// are easily confused, such as 0 and O, are left out.
+	codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+	// defaultCodeLength is the code length used when none is configured.
+	defaultCodeLength = 6
+	// defaultExpiry is the code lifetime used when none is configured.
+	defaultExpiry = 10 * time.Minute
+
+	// dirPerms is the permission used for the directory of the codes file.
+	dirPerms = 0o755
+)
+
+// Config holds the settings of link codes.
+type Config struct {
+	// CodeLength is the number of characters in a code.
+	CodeLength int
+	// Expiry is how long a code can be redeemed after it was issued.
+	Expiry time.Duration
+	// Path is the file outstanding codes are stored in, so they survive a
+	// restart. Empty keeps codes in memory only.
+	Path string
+}
+
+// Code is a one-time link code issued to a player.
+type Code struct {
+	Code    string    `json:"code"`
+	XUID    string    `json:"xuid"`
+	Name    string    `json:"name"`
+	Expires time.Time `json:"expires"`
+}
+
+// Manager stores the outstanding link codes. Changes are written to disk on
+// a background goroutine.
+type Manager struct {
+	log  *slog.Logger
+	conf Config
+
+	mu     sync.Mutex
+	codes  map[string]Code
+	byXUID map[string]string
+	closed bool
+
+	saveCh   chan []byte
+	saveDone chan struct{}
+}
+
+// global holds the singleton link code manager. It starts out with the
+// default settings so /link works before NewManager is called.
+var global = newManager(slog.Default(), Config{})
+
+// Global returns the singleton link code manager.
+func Global() *Manager {
+	return global
+}
+
+// NewManager initialises the singleton link code manager.
+func NewManager(log *slog.Logger, conf Config) *Manager {
+	global = newManager(log, conf)
+
+	return global
+}
+
+// newManager creates a manager, filling in defaults for unset settings and
+// loading the codes stored at conf.Path.
+func newManager(log *slog.Logger, conf Config) *Manager {
+	if conf.CodeLength <= 0 {
+		conf.CodeLength = defaultCodeLength
+	}
+	if conf.Expiry <= 0 {
+		conf.Expiry = defaultExpiry
+	}
+
+	m := &Manager{
+		log:      log,
+		conf:     conf,
+		codes:    make(map[string]Code),
+		byXUID:   make(map[string]string),
+		saveCh:   make(chan []byte, 1),
+		saveDone: make(chan struct{}),
+	}
+
+	if conf.Path != "" {
+		if err := m.load(); err != nil {
+			log.Error("failed to load link codes", "path", conf.Path, "error", err)
+		}
+	}
+
+	go m.saveLoop()
+
+	return m
+}
+
+// load reads the codes file, dropping codes that expired while the hub was
+// offline.
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.conf.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var codes []Code
+	if err := json.Unmarshal(data, &codes); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, c := range codes {
+		if now.After(c.Expires) {
+			continue
+		}
+		m.codes[c.Code] = c
+		m.byXUID[c.XUID] = c.Code
+	}
+
+	return nil
+}
+
+// Issue returns a link code for the player with the given XUID. A code that
+// is still valid is returned again rather than issuing a new one.
+func (m *Manager) Issue(xuid, name string) Code {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweepLocked(now)
+
+	if code, ok := m.byXUID[xuid]; ok {
+		return m.codes[code]
+	}
+
+	c := Code{XUID: xuid, Name: name, Expires: now.Add(m.conf.Expiry)}
+	for {
+		c.Code = m.generate()
+		if _, ok := m.codes[c.Code]; !ok {
+			break
+		}
+	}
+	m.codes[c.Code] = c
+	m.byXUID[xuid] = c.Code
+	m.queueSaveLocked()
+
+	m.log.Debug("issued link code", "xuid", xuid, "expires", c.Expires)
+
+	return c
+}
+
+// Redeem consumes the given code, returning the player it was issued to.
+// Codes are case-insensitive and can only be redeemed once.
+func (m *Manager) Redeem(code string) (Code, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sweepLocked(time.Now())
+
+	c, ok := m.codes[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Code{}, false
+	}
+	delete(m.codes, c.Code)
+	delete(m.byXUID, c.XUID)
+	m.queueSaveLocked()
+
+	return c, true
+}
+
+// sweepLocked removes expired codes. Caller must hold m.mu.
+func (m *Manager) sweepLocked(now time.Time) {
+	for code, c := range m.codes {
+		if now.After(c.Expires) {
+			delete(m.codes, code)
+			delete(m.byXUID, c.XUID)
+		}
+	}
+}
+
+// queueSaveLocked snapshots the codes while m.mu is held and coalesces disk
+// writes on the background save loop.
+func (m *Manager) queueSaveLocked() {
+	if m.closed || m.conf.Path == "" {
+		return
+	}
+
+	codes := make([]Code, 0, len(m.codes))
+	for _, c := range m.codes {
+		codes = append(codes, c)
+	}
+
+	data, err := json.MarshalIndent(codes, "", "  ")
+	if err != nil {
+		return
+	}
+
+	select {
+	case m.saveCh <- data:
+	default:
+		select {
+		case <-m.saveCh:
+		default:
+		}
+		select {
+		case m.saveCh <- data:
+		default:
+		}
+	}
+}
+
+// saveLoop writes queued snapshots without blocking the world owner.
+func (m *Manager) saveLoop() {
+	defer close(m.saveDone)
+
+	for data := range m.saveCh {
+		if err := os.MkdirAll(filepath.Dir(m.conf.Path), dirPerms); err != nil {
+			m.log.Error("failed to create link codes directory", "path", filepath.Dir(m.conf.Path), "error", err)
+
+			continue
+		}
+		if err := util.WriteFileAtomic(m.conf.Path, data); err != nil {
+			m.log.Error("failed to write link codes", "path", m.conf.Path, "error", err)
+		}
+	}
+}
+
+// Close flushes queued snapshots to disk. Codes issued or redeemed after
+// Close are kept in memory only.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+
+		return
+	}
+	m.closed = true
+	close(m.saveCh)
+	m.mu.Unlock()
+
+	<-m.saveDone
+}
+
+// generate returns a random code of the configured length.
+func (m *Manager) generate() string {
+	b := make([]byte, m.conf.CodeLength)
+	_, _ = rand.Read(b)
+
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,79 @@
+package link
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssueAndRedeem(t *testing.T) {
+	m := newManager(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{CodeLength: 8, Expiry: time.Minute})
+
+	c := m.Issue("1", "Steve")
+	if len(c.Code) != 8 || strings.Trim(c.Code, codeAlphabet) != "" {
+		t.Fatalf("unexpected code %q", c.Code)
+	}
+	if again := m.Issue("1", "Steve"); again.Code != c.Code {
+		t.Fatalf("Issue() issued a second code %q while %q is valid", again.Code, c.Code)
+	}
+
+	if _, ok := m.Redeem("nope"); ok {
+		t.Fatal("Redeem() accepted an unknown code")
+	}
+	got, ok := m.Redeem(" " + strings.ToLower(c.Code) + " ")
+	if !ok || got.XUID != "1" || got.Name != "Steve" {
+		t.Fatalf("Redeem() = %+v, %v", got, ok)
+	}
+	if _, ok := m.Redeem(c.Code); ok {
+		t.Fatal("code was redeemed twice")
+	}
+	if next := m.Issue("1", "Steve"); next.Code == "" {
+		t.Fatal("no code issued after redeeming")
+	}
+}
+
+func TestExpiredCode(t *testing.T) {
+	m := newManager(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{})
+
+	c := m.Issue("1", "Steve")
+	m.mu.Lock()
+	c.Expires = time.Now().Add(-time.Second)
+	m.codes[c.Code] = c
+	m.mu.Unlock()
+
+	if _, ok := m.Redeem(c.Code); ok {
+		t.Fatal("expired code was redeemed")
+	}
+	if next := m.Issue("1", "Steve"); next.Code == c.Code {
+		t.Fatal("expired code was issued again")
+	}
+}
+
+func TestCodesPersist(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	conf := Config{Path: filepath.Join(t.TempDir(), "codes.json")}
+
+	m := newManager(log, conf)
+	c := m.Issue("1", "Steve")
+	redeemed := m.Issue("2", "Alex")
+	if _, ok := m.Redeem(redeemed.Code); !ok {
+		t.Fatal("Redeem() rejected a fresh code")
+	}
+	m.Close()
+
+	m = newManager(log, conf)
+	defer m.Close()
+
+	if again := m.Issue("1", "Steve"); again.Code != c.Code {
+		t.Fatalf("Issue() after reload = %q, want the stored %q", again.Code, c.Code)
+	}
+	if _, ok := m.Redeem(redeemed.Code); ok {
+		t.Fatal("a redeemed code could be redeemed again after reload")
+	}
+	if got, ok := m.Redeem(c.Code); !ok || got.Name != "Steve" {
+		t.Fatalf("Redeem() after reload = %+v, %v", got, ok)
+	}
+}
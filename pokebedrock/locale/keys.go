@@ -0,0 +1,170 @@
+package locale
+
+import (
+	"slices"
+
+	"golang.org/x/text/language"
+)
+
+// keys lists every translation key used by the hub, including keys that
+// are chosen at runtime, such as chat filter reasons. Keep it sorted and
+// in sync when adding messages; TestKeysUsed checks it against the code.
+var keys = []string{
+	"afk.back",
+	"afk.enabled",
+	"afk.kicked",
+	"afk.marked",
+	"afk.warn.approaching",
+	"afk.warn.final",
+	"broadcast.actionbar",
+	"broadcast.chat",
+	"broadcast.title",
+	"capacity.displaced",
+	"capacity.full",
+	"chat.discord.linked",
+	"chat.filter.blocked",
+	"chat.filter.caps",
+	"chat.filter.duplicate",
+	"chat.filter.flood",
+	"chat.filter.link",
+	"chat.filter.muted",
+	"chat.filter.rate",
+	"chat.join",
+	"chat.quit",
+	"command.list.header",
+	"connection.connecting",
+	"connection.failed",
+	"downtime.lock.denied",
+	"downtime.lock.notice",
+	"error.account_not_linked",
+	"error.ban.message",
+	"error.flood.accounts",
+	"error.flood.busy",
+	"error.flood.ip",
+	"error.inflictions.load",
+	"error.login.timeout",
+	"error.server_error_fetching_roles",
+	"error.timeout_fetching_roles",
+	"error.vpn.blocked",
+	"error.vpn.failed",
+	"ignore.added",
+	"ignore.already",
+	"ignore.full",
+	"ignore.list",
+	"ignore.list.empty",
+	"ignore.notfound",
+	"ignore.removed",
+	"ignore.self",
+	"kit.navigator",
+	"kit.parkour.quit",
+	"kit.parkour.restart",
+	"kit.players.toggle",
+	"kit.rank.sync",
+	"kit.settings",
+	"kit.spawn",
+	"language.name",
+	"link.already",
+	"link.code",
+	"link.form.body",
+	"link.form.done",
+	"link.form.title",
+	"msg.blocked",
+	"msg.empty",
+	"msg.ignoring",
+	"msg.offline",
+	"msg.received",
+	"msg.reply.none",
+	"msg.self",
+	"msg.sent",
+	"msg.spy",
+	"mute.message",
+	"queue.added.full",
+	"queue.added.offline",
+	"queue.added.success",
+	"queue.beta.lock",
+	"queue.destination.invalid",
+	"queue.nonexistent.server",
+	"queue.position",
+	"queue.priority.note",
+	"queue.removed",
+	"queue.wait.almost",
+	"queue.wait.long",
+	"queue.wait.next",
+	"queue.wait.short",
+	"rank.cached",
+	"rank.fetching",
+	"rank.refetch.wait",
+	"rank.synced",
+	"rank.update.queue.full",
+	"report.cooldown",
+	"report.form.category",
+	"report.form.reason",
+	"report.form.reason.placeholder",
+	"report.form.title",
+	"report.reason.empty",
+	"report.self",
+	"report.staff.notify",
+	"report.staff.popup",
+	"report.submitted",
+	"scoreboard.disabled",
+	"scoreboard.enabled",
+	"scoreboard.event",
+	"scoreboard.event.live",
+	"scoreboard.event.none",
+	"scoreboard.parkour",
+	"scoreboard.parkour.none",
+	"scoreboard.players",
+	"scoreboard.queue",
+	"scoreboard.queue.none",
+	"scoreboard.rank",
+	"scoreboard.title",
+	"scoreboard.unavailable",
+	"settings.language",
+	"settings.language.automatic",
+	"settings.saved",
+	"settings.scoreboard",
+	"settings.title",
+	"socialspy.disabled",
+	"socialspy.enabled",
+	"staffchat.disabled",
+	"staffchat.enabled",
+	"welcome.hub",
+}
+
+// Coverage compares the keys of a language against the keys used by the hub.
+type Coverage struct {
+	// Language is the language compared.
+	Language language.Tag
+	// Missing holds the keys used by the hub that the language lacks. They
+	// are sent in English instead.
+	Missing []string
+	// Unused holds the keys of the language the hub never uses.
+	Unused []string
+}
+
+// Check compares every registered language against the keys used by the
+// hub, English first.
+func Check() []Coverage {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	coverage := make([]Coverage, 0, len(tags))
+	for _, lang := range tags {
+		c := Coverage{Language: lang}
+		for _, key := range keys {
+			if _, ok := locales[lang][key]; !ok {
+				c.Missing = append(c.Missing, key)
+			}
+		}
+		for key := range locales[lang] {
+			if _, ok := slices.BinarySearch(keys, key); !ok {
+				c.Unused = append(c.Unused, key)
+			}
+		}
+		slices.Sort(c.Unused)
+
+		coverage = append(coverage, c)
+	}
+
+	return coverage
+}
@@ -3,35 +3,124 @@ package locale
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/sandertv/gophertunnel/minecraft/text"
 	"golang.org/x/text/language"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
 )
 
+// PreferenceKey is the profile preference holding the language a player
+// picked, overriding the language of their client.
+const PreferenceKey = "language"
+
+// nameKey is the translation key holding the name of a language in that
+// language.
+const nameKey = "language.name"
+
 // localeData represents a mapping of translation keys to their respective values for a specific language.
-type localeData map[string]string
+type localeData map[string]template
+
+var (
+	// locales is a map of registered locales keyed by language tags.
+	// It holds all the locale data for supported languages.
+	locales = make(map[language.Tag]localeData)
+	// tags holds the registered languages, English first, and matcher
+	// matches client languages against them.
+	tags    []language.Tag
+	matcher = language.NewMatcher([]language.Tag{language.English})
+	// mu guards locales, tags and matcher.
+	mu sync.RWMutex
+
+	// reported holds the missing keys that have been logged.
+	reported sync.Map
+)
 
-// locales is a map of registered locales keyed by language tags.
-// It holds all the locale data for supported languages.
-var locales = make(map[language.Tag]localeData)
+// Player is a player whose messages are translated, such as a
+// *player.Player.
+type Player interface {
+	XUID() string
+	Locale() language.Tag
+}
 
 // Register registers a new locale from the specified language file path.
 // It reads the language file and populates the locale data for the provided language tag.
 // The language file should be in the format "key=value" where each key corresponds to a translation key.
 func Register(lang language.Tag, filePath string) error {
-	file, err := os.Open(fmt.Sprintf("%s/%s.lang", filePath, lang.String()))
+	data, err := parseFile(fmt.Sprintf("%s/%s.lang", filePath, lang.String()))
 	if err != nil {
-		return fmt.Errorf("could not open lang file: %w", err)
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	locales[lang] = data
+	rebuildLocked()
+
+	return nil
+}
+
+// RegisterAll registers every .lang file in dir, named after the language
+// it holds, such as "en.lang" or "pt_BR.lang". The English file must be
+// present. It returns the registered languages.
+func RegisterAll(dir string) ([]language.Tag, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.lang"))
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[language.Tag]localeData, len(files))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".lang")
+		lang, err := language.Parse(strings.ReplaceAll(name, "_", "-"))
+		if err != nil {
+			return nil, fmt.Errorf("lang file %s is not named after a language: %w", file, err)
+		}
+
+		data, err := parseFile(file)
+		if err != nil {
+			return nil, err
+		}
+		loaded[lang] = data
+	}
+	if _, ok := loaded[language.English]; !ok {
+		return nil, fmt.Errorf("no %s.lang file found in %s", language.English, dir)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	locales = loaded
+	rebuildLocked()
+
+	return slices.Clone(tags), nil
+}
+
+// parseFile reads a language file.
+func parseFile(path string) (localeData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open lang file: %w", err)
 	}
 	defer file.Close()
 
-	data := make(localeData)
-	scanner := bufio.NewScanner(file)
+	var (
+		data    = make(localeData)
+		scanner = bufio.NewScanner(file)
+		errs    []error
+	)
 
-	for scanner.Scan() {
+	for n := 1; scanner.Scan(); n++ {
 		line := scanner.Text()
 		if len(line) == 0 || line[0] == '#' {
 			continue
@@ -43,43 +132,161 @@ func Register(lang language.Tag, filePath string) error {
 		}
 
 		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
-		data[key] = value
+		t, err := parseTemplate(strings.TrimSpace(parts[1]))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %s: %w", path, n, key, err))
+
+			continue
+		}
+		data[key] = t
 	}
 
 	if err = scanner.Err(); err != nil {
-		return fmt.Errorf("error reading lang file: %w", err)
+		return nil, fmt.Errorf("error reading lang file: %w", err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
-	locales[lang] = data
+	return data, nil
+}
 
-	return nil
+// rebuildLocked rebuilds the language list and matcher after the locales
+// changed. Caller must hold mu.
+func rebuildLocked() {
+	tags = tags[:0]
+	for lang := range locales {
+		if lang != language.English {
+			tags = append(tags, lang)
+		}
+	}
+	slices.SortFunc(tags, func(a, b language.Tag) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	tags = slices.Insert(tags, 0, language.English)
+
+	matcher = language.NewMatcher(tags)
+}
+
+// Languages returns the registered languages, English first.
+func Languages() []language.Tag {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return slices.Clone(tags)
+}
+
+// Name returns the name of a language in that language.
+func Name(lang language.Tag) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if name, ok := locales[lang][nameKey]; ok {
+		return name.render(lang, nil, nil)
+	}
+
+	return lang.String()
+}
+
+// Match returns the registered language closest to lang, or English if
+// none is close.
+func Match(lang language.Tag) language.Tag {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	_, i, confidence := matcher.Match(lang)
+	if confidence == language.No {
+		return language.English
+	}
+
+	return tags[i]
+}
+
+// Language returns the language messages are sent to p in: the language
+// they picked if it is registered, otherwise the language of their client.
+func Language(p Player) language.Tag {
+	return LanguageOf(p.XUID(), p.Locale())
+}
+
+// LanguageOf returns the language for the account with the given XUID and
+// client language, such as during login before a player exists.
+func LanguageOf(xuid string, client language.Tag) language.Tag {
+	if v, ok := profile.Global().Preference(xuid, PreferenceKey); ok {
+		if lang, err := language.Parse(v); err == nil {
+			mu.RLock()
+			_, registered := locales[lang]
+			mu.RUnlock()
+
+			if registered {
+				return lang
+			}
+		}
+	}
+
+	return Match(client)
+}
+
+// SetLanguage stores the language picked by the player with the given
+// XUID. language.Und clears it, so the client language is used again.
+func SetLanguage(xuid string, lang language.Tag) {
+	if lang == language.Und {
+		profile.Global().SetPreference(xuid, PreferenceKey, "")
+
+		return
+	}
+
+	profile.Global().SetPreference(xuid, PreferenceKey, lang.String())
 }
 
 // Translate translates a key to the default language (English) and formats it with the provided arguments.
 // It uses the TranslateL function internally for the English translation.
 func Translate(key string, args ...any) string {
-	return text.Colourf("%s", TranslateL(language.English, key, args...))
+	return TranslateL(language.English, key, args...)
+}
+
+// TranslateFor translates a key to the language of p and formats it with
+// the provided arguments.
+func TranslateFor(p Player, key string, args ...any) string {
+	return TranslateL(Language(p), key, args...)
 }
 
 // TranslateL translates a key to a specified language and formats it with the provided arguments.
-// If the language data is unavailable, it falls back to the English translation.
-// It supports placeholders in the translation string, which are replaced by the arguments.
+// Keys missing from the language, or languages that aren't registered, fall back to English.
+// Arguments fill positional placeholders in order, except for Args, which fill named placeholders.
+// Keys missing from English too are logged once and translate to the key itself.
 func TranslateL(lang language.Tag, key string, args ...any) string {
-	locale, ok := locales[lang]
+	mu.RLock()
+	translation, ok := locales[lang][key]
 	if !ok {
-		locale = locales[language.English]
+		lang = language.English
+		translation, ok = locales[language.English][key]
 	}
+	mu.RUnlock()
 
-	translation, ok := locale[key]
 	if !ok {
-		return fmt.Sprintf("missing translation for '%s'", key)
+		if _, logged := reported.LoadOrStore(key, struct{}{}); !logged {
+			slog.Default().Warn("missing translation", "key", key)
+		}
+
+		return key
 	}
 
-	for i, arg := range args {
-		placeholder := fmt.Sprintf("%%%d", i+1)
-		translation = strings.ReplaceAll(translation, placeholder, fmt.Sprintf("%v", arg))
+	var (
+		positional []any
+		named      Args
+	)
+	for _, arg := range args {
+		a, ok := arg.(Args)
+		if !ok {
+			positional = append(positional, arg)
+
+			continue
+		}
+		if named == nil {
+			named = make(Args, len(a))
+		}
+		maps.Copy(named, a)
 	}
 
-	return translation
+	return text.Colourf("%s", translation.render(lang, positional, named))
 }
@@ -0,0 +1,210 @@
+package locale
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sandertv/gophertunnel/minecraft/text"
+	"golang.org/x/text/language"
+)
+
+func writeLang(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterAll(t *testing.T) {
+	dir := t.TempDir()
+	writeLang(t, dir, "en.lang", "language.name=English\ngreeting=Hello %1\nfarewell=Bye\n")
+	writeLang(t, dir, "pt_BR.lang", "language.name=Português\ngreeting=Olá %1\n")
+
+	got, err := RegisterAll(dir)
+	if err != nil {
+		t.Fatalf("RegisterAll() error = %v", err)
+	}
+	ptBR := language.MustParse("pt-BR")
+	if len(got) != 2 || got[0] != language.English || got[1] != ptBR {
+		t.Fatalf("RegisterAll() = %v, want [en pt-BR]", got)
+	}
+
+	tests := []struct {
+		name string
+		lang language.Tag
+		key  string
+		want string
+	}{
+		{"translated", ptBR, "greeting", "Olá Steve"},
+		{"fallback per key", ptBR, "farewell", "Bye"},
+		{"unregistered language", language.German, "greeting", "Hello Steve"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := text.Clean(TranslateL(tt.lang, tt.key, "Steve")); got != tt.want {
+				t.Fatalf("TranslateL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := Match(language.Portuguese); got != ptBR {
+		t.Fatalf("Match(pt) = %v, want pt-BR", got)
+	}
+	if got := Match(language.Japanese); got != language.English {
+		t.Fatalf("Match(ja) = %v, want en", got)
+	}
+	if got := Name(ptBR); got != "Português" {
+		t.Fatalf("Name(pt-BR) = %q", got)
+	}
+}
+
+func TestRegisterAllRequiresEnglish(t *testing.T) {
+	dir := t.TempDir()
+	writeLang(t, dir, "de.lang", "greeting=Hallo\n")
+
+	if _, err := RegisterAll(dir); err == nil {
+		t.Fatal("RegisterAll() without en.lang succeeded")
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	players := "{n, plural, =0 {no players} one {# player} other {# players}}"
+
+	tests := []struct {
+		name     string
+		template string
+		args     []any
+		want     string
+	}{
+		{"positional", "%1 and {2}", []any{"a", "b"}, "a and b"},
+		{"named", "{who} joined {server}", []any{Args{"who": "Steve", "server": "Survival"}}, "Steve joined Survival"},
+		{"missing argument", "hi {who}", nil, "hi {who}"},
+		{"plural exact", players, []any{Args{"n": 0}}, "no players"},
+		{"plural one", players, []any{Args{"n": 1}}, "1 player"},
+		{"plural other", players, []any{Args{"n": 12}}, "12 players"},
+		{"plural nested", "{n, plural, one {{who} waits} other {# wait}}", []any{Args{"n": 1, "who": "Alex"}}, "Alex waits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := parseTemplate(tt.template)
+			if err != nil {
+				t.Fatalf("parseTemplate() error = %v", err)
+			}
+
+			var (
+				positional []any
+				named      Args
+			)
+			for _, arg := range tt.args {
+				if a, ok := arg.(Args); ok {
+					named = a
+				} else {
+					positional = append(positional, arg)
+				}
+			}
+			if got := tmpl.render(language.English, positional, named); got != tt.want {
+				t.Fatalf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateInvalid(t *testing.T) {
+	for _, s := range []string{
+		"<red>unclosed",
+		"<rde>typo</rde>",
+		"</red>",
+		"{unterminated",
+		"stray }",
+		"{n, plural, one {# player}}",
+		"{n, plural, lots {# players} other {x}}",
+		"{n, select, a {b}}",
+	} {
+		if _, err := parseTemplate(s); err == nil {
+			t.Errorf("parseTemplate(%q) succeeded", s)
+		}
+	}
+	if _, err := parseTemplate("<red>a<new-line><bold>b</bold></red>"); err != nil {
+		t.Errorf("parseTemplate() error = %v", err)
+	}
+}
+
+// TestKeysUsed checks the key list against the keys passed to the translate
+// functions and against the English language file.
+func TestKeysUsed(t *testing.T) {
+	if !slices.IsSorted(keys) {
+		t.Fatal("keys is not sorted")
+	}
+
+	literals := make(map[string]bool)
+	fset := token.NewFileSet()
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") ||
+			strings.HasSuffix(path, "_test.go") || path == filepath.Join("..", "locale", "keys.go") {
+			return err
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BasicLit:
+				if n.Kind == token.STRING {
+					s, _ := strconv.Unquote(n.Value)
+					literals[s] = true
+				}
+			case *ast.CallExpr:
+				sel, ok := n.Fun.(*ast.SelectorExpr)
+				if !ok || len(n.Args) < 2 {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "locale" {
+					return true
+				}
+				arg := n.Args[1]
+				if sel.Sel.Name == "Translate" {
+					arg = n.Args[0]
+				}
+				if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					key, _ := strconv.Unquote(lit.Value)
+					if _, found := slices.BinarySearch(keys, key); !found {
+						t.Errorf("%s: key %q is not in keys", fset.Position(lit.Pos()), key)
+					}
+				}
+			}
+
+			return true
+		})
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range keys {
+		if !literals[key] {
+			t.Errorf("key %q is not used by the code", key)
+		}
+	}
+
+	if _, err := RegisterAll("../../resources/locales"); err != nil {
+		t.Fatalf("RegisterAll() error = %v", err)
+	}
+	for _, c := range Check() {
+		if c.Language == language.English && len(c.Missing) > 0 {
+			t.Errorf("en.lang is missing %v", c.Missing)
+		}
+	}
+}
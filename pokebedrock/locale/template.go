@@ -0,0 +1,352 @@
+package locale
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// Args holds the values of named placeholders. It is passed to the
+// translate functions alongside or instead of positional arguments.
+type Args map[string]any
+
+// template is a parsed translation. Translations support the following
+// placeholders:
+//
+//	%1, {1}                                   the first positional argument
+//	{name}                                    the named argument "name"
+//	{n, plural, one {# player} other {# players}}
+//
+// Plural placeholders pick a form by the CLDR plural rules of the language.
+// Forms are zero, one, two, few, many, other and exact matches such as =0.
+// Other is required, and # in a form is replaced by the number.
+type template []segment
+
+// segment is a literal text, a placeholder or a plural placeholder.
+type segment struct {
+	text string
+	arg  string
+
+	plural map[string]template
+}
+
+// pluralForms maps the plural forms to their names in translations.
+var pluralForms = map[plural.Form]string{
+	plural.Other: "other",
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+}
+
+// parseTemplate parses a translation.
+func parseTemplate(s string) (template, error) {
+	t, rest, err := parseUntil(s, false)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected %q", rest)
+	}
+	if err = validateTags(s); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// parseUntil parses s until its end, or until an unmatched '}' if nested is
+// set. It returns the parsed template and the unparsed remainder, starting
+// at that '}'.
+func parseUntil(s string, nested bool) (template, string, error) {
+	var (
+		t    template
+		text strings.Builder
+	)
+	flush := func() {
+		if text.Len() > 0 {
+			t = append(t, segment{text: text.String()})
+			text.Reset()
+		}
+	}
+
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == '%' && len(s) > 1 && s[1] >= '1' && s[1] <= '9':
+			n := 1
+			for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+				n++
+			}
+			flush()
+			t = append(t, segment{arg: s[1:n]})
+			s = s[n:]
+		case c == '{':
+			flush()
+			seg, rest, err := parsePlaceholder(s[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			t = append(t, seg)
+			s = rest
+		case c == '}':
+			if !nested {
+				return nil, "", errors.New("unmatched '}'")
+			}
+			flush()
+
+			return t, s, nil
+		default:
+			text.WriteByte(c)
+			s = s[1:]
+		}
+	}
+	if nested {
+		return nil, "", errors.New("unterminated plural form")
+	}
+	flush()
+
+	return t, "", nil
+}
+
+// parsePlaceholder parses a placeholder following its opening '{', and
+// returns the remainder after its closing '}'.
+func parsePlaceholder(s string) (segment, string, error) {
+	end := strings.IndexAny(s, ",{}")
+	if end < 0 || s[end] == '{' {
+		return segment{}, "", errors.New("unterminated placeholder")
+	}
+	name := strings.TrimSpace(s[:end])
+	if !validName(name) {
+		return segment{}, "", fmt.Errorf("invalid placeholder name %q", name)
+	}
+	if s[end] == '}' {
+		return segment{arg: name}, s[end+1:], nil
+	}
+
+	kind, rest, ok := strings.Cut(s[end+1:], ",")
+	if !ok || strings.TrimSpace(kind) != "plural" {
+		return segment{}, "", fmt.Errorf("placeholder %q: expected \", plural,\"", name)
+	}
+
+	forms := make(map[string]template)
+	for {
+		rest = strings.TrimLeft(rest, " ")
+		if strings.HasPrefix(rest, "}") {
+			break
+		}
+
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			return segment{}, "", fmt.Errorf("placeholder %q: unterminated plural", name)
+		}
+		form := strings.TrimSpace(rest[:open])
+		if !validForm(form) {
+			return segment{}, "", fmt.Errorf("placeholder %q: unknown plural form %q", name, form)
+		}
+
+		t, after, err := parseUntil(rest[open+1:], true)
+		if err != nil {
+			return segment{}, "", fmt.Errorf("placeholder %q: %w", name, err)
+		}
+		forms[form] = t
+		rest = after[1:]
+	}
+	if _, ok := forms["other"]; !ok {
+		return segment{}, "", fmt.Errorf("placeholder %q: plural has no \"other\" form", name)
+	}
+
+	return segment{arg: name, plural: forms}, rest[1:], nil
+}
+
+// validName reports whether name may be used as a placeholder name.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validForm reports whether form is a plural form or an exact match.
+func validForm(form string) bool {
+	if n, ok := strings.CutPrefix(form, "="); ok {
+		_, err := strconv.Atoi(n)
+
+		return err == nil
+	}
+	for _, name := range pluralForms {
+		if form == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tagPattern matches opening and closing colour tags.
+var tagPattern = regexp.MustCompile(`<(/?)([a-zA-Z-]*)>`)
+
+// colourTags holds the tags understood by text.Colourf.
+var colourTags = []string{
+	"black", "dark-blue", "dark-green", "dark-aqua", "dark-red", "dark-purple", "orange", "grey", "dark-grey",
+	"blue", "green", "aqua", "red", "purple", "yellow", "white", "dark-yellow", "quartz", "iron", "netherite",
+	"obfuscated", "bold", "b", "redstone", "copper", "gold", "emerald", "italic", "i", "diamond", "lapis",
+	"amethyst", "resin",
+}
+
+// lineBreak is the tag messages are split into separate lines on.
+const lineBreak = "new-line"
+
+// validateTags checks that every tag in s is a colour tag or a line break,
+// and that colour tags are closed.
+func validateTags(s string) error {
+	var open []string
+	for _, m := range tagPattern.FindAllStringSubmatch(s, -1) {
+		closing, name := m[1] == "/", m[2]
+		if name == lineBreak && !closing {
+			continue
+		}
+		if !slices.Contains(colourTags, name) {
+			return fmt.Errorf("unknown tag %q", m[0])
+		}
+		if !closing {
+			open = append(open, name)
+
+			continue
+		}
+
+		i := slices.Index(open, name)
+		if i < 0 {
+			return fmt.Errorf("%q closes a tag that isn't open", m[0])
+		}
+		open = slices.Delete(open, i, i+1)
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("tag <%s> is never closed", open[0])
+	}
+
+	return nil
+}
+
+// render renders t in lang with the given arguments. Placeholders without
+// an argument are left as they are.
+func (t template) render(lang language.Tag, positional []any, named Args) string {
+	var b strings.Builder
+	t.renderTo(&b, lang, positional, named, "")
+
+	return b.String()
+}
+
+// renderTo writes t to b. number replaces # within plural forms.
+func (t template) renderTo(b *strings.Builder, lang language.Tag, positional []any, named Args, number string) {
+	for _, seg := range t {
+		if seg.arg == "" {
+			if number != "" {
+				b.WriteString(strings.ReplaceAll(seg.text, "#", number))
+			} else {
+				b.WriteString(seg.text)
+			}
+
+			continue
+		}
+
+		v, ok := argument(seg.arg, positional, named)
+		if !ok {
+			b.WriteString("{" + seg.arg + "}")
+
+			continue
+		}
+		if seg.plural == nil {
+			fmt.Fprintf(b, "%v", v)
+
+			continue
+		}
+
+		n, ok := count(v)
+		if !ok {
+			fmt.Fprintf(b, "%v", v)
+
+			continue
+		}
+		form, ok := seg.plural["="+strconv.Itoa(n)]
+		if !ok {
+			abs := n
+			if abs < 0 {
+				abs = -abs
+			}
+			if form, ok = seg.plural[pluralForms[plural.Cardinal.MatchPlural(lang, abs, 0, 0, 0, 0)]]; !ok {
+				form = seg.plural["other"]
+			}
+		}
+		form.renderTo(b, lang, positional, named, strconv.Itoa(n))
+	}
+}
+
+// argument returns the value of the placeholder called name. Numeric names
+// refer to positional arguments, starting at 1.
+func argument(name string, positional []any, named Args) (any, bool) {
+	if i, err := strconv.Atoi(name); err == nil {
+		if i < 1 || i > len(positional) {
+			return nil, false
+		}
+
+		return positional[i-1], true
+	}
+
+	v, ok := named[name]
+
+	return v, ok
+}
+
+// count converts a plural argument to an integer.
+func count(v any) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int8:
+		return int(v), true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case uint:
+		return int(v), true
+	case uint8:
+		return int(v), true
+	case uint16:
+		return int(v), true
+	case uint32:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float32:
+		return count(float64(v))
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, false
+		}
+
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+
+		return n, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,61 @@
+package moderation
+
+import (
+	"sort"
+	"time"
+)
+
+// HistoryEntry pairs an infliction with the list it was found in, so current
+// and past inflictions can be shown together in a single timeline.
+type HistoryEntry struct {
+	Infliction
+	Status InflictionStatus
+}
+
+// Current reports whether the entry is still an active infliction.
+func (e HistoryEntry) Current() bool {
+	return e.Status == InflictionStatusCurrent
+}
+
+// History merges the current and past inflictions of the response into one
+// slice, sorted newest first by the date they were inflicted.
+func (r *ModelResponse) History() []HistoryEntry {
+	if r == nil {
+		return nil
+	}
+
+	entries := make([]HistoryEntry, 0, len(r.CurrentInflictions)+len(r.PastInflictions))
+	for _, i := range r.CurrentInflictions {
+		entries = append(entries, HistoryEntry{Infliction: i, Status: InflictionStatusCurrent})
+	}
+	for _, i := range r.PastInflictions {
+		entries = append(entries, HistoryEntry{Infliction: i, Status: InflictionStatusPast})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DateInflicted > entries[j].DateInflicted
+	})
+
+	return entries
+}
+
+// Permanent reports whether the infliction has no expiry date.
+func (i Infliction) Permanent() bool {
+	return i.ExpiryDate == nil || *i.ExpiryDate == 0
+}
+
+// Remaining returns how long the infliction has left at the given time. The
+// second return value is false for permanent inflictions. Expired
+// inflictions report a zero duration.
+func (i Infliction) Remaining(now time.Time) (time.Duration, bool) {
+	if i.Permanent() {
+		return 0, false
+	}
+
+	remaining := time.UnixMilli(*i.ExpiryDate).Sub(now)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return remaining, true
+}
@@ -0,0 +1,49 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistorySortsNewestFirst(t *testing.T) {
+	resp := &ModelResponse{
+		CurrentInflictions: []Infliction{{Type: InflictionMuted, DateInflicted: 200}},
+		PastInflictions: []Infliction{
+			{Type: InflictionWarned, DateInflicted: 100},
+			{Type: InflictionBanned, DateInflicted: 300},
+		},
+	}
+
+	history := resp.History()
+	if len(history) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(history))
+	}
+
+	want := []InflictionType{InflictionBanned, InflictionMuted, InflictionWarned}
+	for i, typ := range want {
+		if history[i].Type != typ {
+			t.Errorf("entry %d = %s, want %s", i, history[i].Type, typ)
+		}
+	}
+	if !history[1].Current() || history[0].Current() {
+		t.Error("expected only the muted entry to be current")
+	}
+}
+
+func TestInflictionRemaining(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+
+	if _, ok := (Infliction{}).Remaining(now); ok {
+		t.Error("permanent infliction reported a remaining duration")
+	}
+
+	expiry := now.Add(time.Hour).UnixMilli()
+	if d, ok := (Infliction{ExpiryDate: &expiry}).Remaining(now); !ok || d != time.Hour {
+		t.Errorf("Remaining = %v, %v, want 1h, true", d, ok)
+	}
+
+	expired := now.Add(-time.Hour).UnixMilli()
+	if d, _ := (Infliction{ExpiryDate: &expired}).Remaining(now); d != 0 {
+		t.Errorf("expired infliction reported %v remaining", d)
+	}
+}
@@ -0,0 +1,194 @@
+package moderation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// Step is a single rung of an escalation ladder: the infliction type to
+// issue and how long it lasts. A zero Duration means permanent.
+type Step struct {
+	Type     InflictionType
+	Duration time.Duration
+}
+
+// String returns the step in a form similar to how it is configured, e.g.
+// "MUTED:1d".
+func (s Step) String() string {
+	if s.Duration == 0 {
+		return string(s.Type)
+	}
+
+	return fmt.Sprintf("%s:%s", s.Type, util.FormatDuration(s.Duration))
+}
+
+// Ladder is an offence category with the ordered punishments issued for
+// repeat offences, for example warn -> 1h mute -> 1d mute -> 7d ban.
+type Ladder struct {
+	// Category is the short name of the offence, e.g. "spam".
+	Category string
+	// Steps are issued in order; offences beyond the last step repeat it.
+	Steps []Step
+	// Window is how far back previous offences count towards escalation.
+	// Zero counts every previous offence.
+	Window time.Duration
+}
+
+// LadderConfig is the config.toml representation of a Ladder.
+type LadderConfig struct {
+	Category string
+	// Steps are written as "TYPE" or "TYPE:duration", e.g. "MUTED:1h" or
+	// "BANNED:7d". A step without a duration is permanent.
+	Steps []string
+	// Window is a duration string such as "30d". Empty counts every
+	// previous offence.
+	Window string
+}
+
+// ParseLadder converts a LadderConfig into a Ladder, validating every step.
+func ParseLadder(conf LadderConfig) (Ladder, error) {
+	category := strings.ToLower(strings.TrimSpace(conf.Category))
+	if category == "" {
+		return Ladder{}, fmt.Errorf("ladder category must not be empty")
+	}
+	if len(conf.Steps) == 0 {
+		return Ladder{}, fmt.Errorf("ladder %q has no steps", category)
+	}
+
+	l := Ladder{Category: category}
+
+	for _, raw := range conf.Steps {
+		typ, dur, _ := strings.Cut(strings.TrimSpace(raw), ":")
+
+		s := Step{Type: InflictionType(strings.ToUpper(typ))}
+		switch s.Type {
+		case InflictionBanned, InflictionMuted, InflictionFrozen, InflictionWarned, InflictionKicked:
+		default:
+			return Ladder{}, fmt.Errorf("ladder %q: unknown infliction type %q", category, typ)
+		}
+
+		if dur != "" {
+			d, err := parseLadderDuration(dur)
+			if err != nil {
+				return Ladder{}, fmt.Errorf("ladder %q: %w", category, err)
+			}
+			s.Duration = d
+		}
+
+		l.Steps = append(l.Steps, s)
+	}
+
+	if conf.Window != "" {
+		w, err := parseLadderDuration(conf.Window)
+		if err != nil {
+			return Ladder{}, fmt.Errorf("ladder %q: %w", category, err)
+		}
+		l.Window = w
+	}
+
+	return l, nil
+}
+
+// parseLadderDuration parses a duration, additionally accepting a whole
+// number of days with a "d" suffix.
+func parseLadderDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	return d, nil
+}
+
+// Tag returns the prefix recorded in the reason of inflictions issued
+// through this ladder, used to recognise previous offences.
+func (l Ladder) Tag() string {
+	return "[" + l.Category + "]"
+}
+
+// TagReason prefixes reason with the ladder tag, unless it already has it.
+func (l Ladder) TagReason(reason string) string {
+	if strings.HasPrefix(strings.ToLower(reason), l.Tag()) {
+		return reason
+	}
+
+	return l.Tag() + " " + reason
+}
+
+// Offences counts the inflictions in history that were issued through this
+// ladder within its window, as seen at now.
+func (l Ladder) Offences(history []Infliction, now time.Time) int {
+	var n int
+
+	for _, i := range history {
+		if !strings.HasPrefix(strings.ToLower(i.Reason), l.Tag()) {
+			continue
+		}
+		if l.Window > 0 && now.Sub(time.UnixMilli(i.DateInflicted)) > l.Window {
+			continue
+		}
+		n++
+	}
+
+	return n
+}
+
+// Next returns the step to issue for the next offence given the target's
+// infliction history. Offences past the end of the ladder repeat the last
+// step.
+func (l Ladder) Next(history []Infliction, now time.Time) Step {
+	if len(l.Steps) == 0 {
+		return Step{Type: InflictionWarned}
+	}
+
+	return l.Steps[min(l.Offences(history, now), len(l.Steps)-1)]
+}
+
+var (
+	// laddersMu guards ladders.
+	laddersMu sync.RWMutex
+	// ladders holds the configured escalation ladders in config order.
+	ladders []Ladder
+)
+
+// SetLadders replaces the configured escalation ladders.
+func SetLadders(l []Ladder) {
+	laddersMu.Lock()
+	defer laddersMu.Unlock()
+
+	ladders = l
+}
+
+// Ladders returns the configured escalation ladders.
+func Ladders() []Ladder {
+	laddersMu.RLock()
+	defer laddersMu.RUnlock()
+
+	return ladders
+}
+
+// LadderOf returns the ladder for the given category.
+func LadderOf(category string) (Ladder, bool) {
+	for _, l := range Ladders() {
+		if strings.EqualFold(l.Category, category) {
+			return l, true
+		}
+	}
+
+	return Ladder{}, false
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLadder(t *testing.T) {
+	l, err := ParseLadder(LadderConfig{
+		Category: "Spam",
+		Steps:    []string{"warned", "MUTED:1h", "MUTED:1d", "BANNED:7d", "BANNED"},
+		Window:   "30d",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l.Category != "spam" {
+		t.Errorf("category = %q, want spam", l.Category)
+	}
+	want := []Step{
+		{Type: InflictionWarned},
+		{Type: InflictionMuted, Duration: time.Hour},
+		{Type: InflictionMuted, Duration: 24 * time.Hour},
+		{Type: InflictionBanned, Duration: 7 * 24 * time.Hour},
+		{Type: InflictionBanned},
+	}
+	if len(l.Steps) != len(want) {
+		t.Fatalf("got %d steps, want %d", len(l.Steps), len(want))
+	}
+	for i, s := range want {
+		if l.Steps[i] != s {
+			t.Errorf("step %d = %+v, want %+v", i, l.Steps[i], s)
+		}
+	}
+	if l.Window != 30*24*time.Hour {
+		t.Errorf("window = %v, want 720h", l.Window)
+	}
+
+	for _, bad := range []LadderConfig{
+		{Category: "", Steps: []string{"WARNED"}},
+		{Category: "spam"},
+		{Category: "spam", Steps: []string{"SLAPPED"}},
+		{Category: "spam", Steps: []string{"MUTED:soon"}},
+	} {
+		if _, err := ParseLadder(bad); err == nil {
+			t.Errorf("ParseLadder(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestLadderNext(t *testing.T) {
+	now := time.Now()
+	l := Ladder{
+		Category: "spam",
+		Steps: []Step{
+			{Type: InflictionWarned},
+			{Type: InflictionMuted, Duration: time.Hour},
+			{Type: InflictionBanned, Duration: 7 * 24 * time.Hour},
+		},
+		Window: 30 * 24 * time.Hour,
+	}
+
+	offence := func(reason string, age time.Duration) Infliction {
+		return Infliction{Reason: reason, DateInflicted: now.Add(-age).UnixMilli()}
+	}
+
+	cases := []struct {
+		name    string
+		history []Infliction
+		want    InflictionType
+	}{
+		{"first offence", nil, InflictionWarned},
+		{"other categories ignored", []Infliction{offence("[toxicity] rude", time.Hour)}, InflictionWarned},
+		{"second offence", []Infliction{offence("[spam] flooding", time.Hour)}, InflictionMuted},
+		{"tag is case-insensitive", []Infliction{offence("[SPAM] flooding", time.Hour)}, InflictionMuted},
+		{"outside window", []Infliction{offence("[spam] flooding", 60*24*time.Hour)}, InflictionWarned},
+		{
+			"past the end repeats last step",
+			[]Infliction{
+				offence("[spam] a", time.Hour), offence("[spam] b", time.Hour),
+				offence("[spam] c", time.Hour), offence("[spam] d", time.Hour),
+			},
+			InflictionBanned,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := l.Next(tc.history, now); got.Type != tc.want {
+				t.Errorf("Next = %s, want %s", got.Type, tc.want)
+			}
+		})
+	}
+
+	if got := l.TagReason("flooding"); got != "[spam] flooding" {
+		t.Errorf("TagReason = %q", got)
+	}
+	if got := l.TagReason("[spam] flooding"); got != "[spam] flooding" {
+		t.Errorf("TagReason re-tagged reason: %q", got)
+	}
+}
@@ -45,6 +45,13 @@ type ModelRequest struct {
 	Infliction       Infliction       `json:"infliction"`
 }
 
+// UpdateRequest identifies an existing current infliction and the expiry
+// date it should be changed to. A nil ExpiryDate makes it permanent.
+type UpdateRequest struct {
+	ModelRequest
+	ExpiryDate *int64 `json:"new_expiry_date"`
+}
+
 // ModelResponse represents the response containing infliction data for a player.
 // It separates the inflictions into current and past inflictions.
 type ModelResponse struct {
@@ -59,3 +66,25 @@ type PlayerDetails struct {
 	XUID string `json:"xuid"`
 	IP   string `json:"ip"`
 }
+
+// ReportRequest represents a player report forwarded to the moderation API.
+// Dates are Unix timestamps in milliseconds.
+type ReportRequest struct {
+	ReporterName string `json:"reporter_name"`
+	ReporterXUID string `json:"reporter_xuid"`
+	TargetName   string `json:"target_name"`
+	TargetXUID   string `json:"target_xuid,omitempty"`
+	Category     string `json:"category"`
+	Reason       string `json:"reason"`
+	DateReported int64  `json:"date_reported"`
+}
+
+// AuditRequest represents an entry recorded in the moderation audit log,
+// such as a staff chat message. Dates are Unix timestamps in milliseconds.
+type AuditRequest struct {
+	Actor     string `json:"actor"`
+	ActorXUID string `json:"actor_xuid"`
+	Action    string `json:"action"`
+	Message   string `json:"message"`
+	Date      int64  `json:"date"`
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/df-mc/dragonfly/server/player"
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
 )
 
 // globalService holds the singleton moderation service.
@@ -76,17 +77,18 @@ const (
 )
 
 // InflictionOfXUID fetches the inflictions for the given XUID.
-func (s *Service) InflictionOfXUID(xuid string) (*ModelResponse, error) {
-	return s.InflictionOf(ModelRequest{XUID: xuid})
+func (s *Service) InflictionOfXUID(ctx context.Context, xuid string) (*ModelResponse, error) {
+	return s.InflictionOf(ctx, ModelRequest{XUID: xuid})
 }
 
 // InflictionOfName fetches the inflictions for the given player name.
-func (s *Service) InflictionOfName(name string) (*ModelResponse, error) {
-	return s.InflictionOf(ModelRequest{Name: name})
+func (s *Service) InflictionOfName(ctx context.Context, name string) (*ModelResponse, error) {
+	return s.InflictionOf(ctx, ModelRequest{Name: name})
 }
 
-// InflictionOf retrieves the current and past inflictions matching the given request.
-func (s *Service) InflictionOf(req ModelRequest) (*ModelResponse, error) {
+// InflictionOf retrieves the current and past inflictions matching the
+// given request, retrying transient failures until ctx is done.
+func (s *Service) InflictionOf(ctx context.Context, req ModelRequest) (*ModelResponse, error) {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, err
@@ -99,10 +101,12 @@ func (s *Service) InflictionOf(req ModelRequest) (*ModelResponse, error) {
 			break
 		}
 		if attempt > 0 {
-			time.Sleep(retryDelay * time.Duration(1<<attempt))
+			if err := util.Sleep(ctx, retryDelay*time.Duration(1<<attempt)); err != nil {
+				return nil, err
+			}
 		}
 
-		resp, err := s.attempt(http.MethodPost, "/getInflictions", body)
+		resp, err := s.attempt(ctx, http.MethodPost, "/getInflictions", body)
 		if err != nil {
 			lastErr = err
 			if isTemporaryError(err) {
@@ -115,7 +119,9 @@ func (s *Service) InflictionOf(req ModelRequest) (*ModelResponse, error) {
 		out, retry, err := decodeInflictionsResponse(resp, req)
 		if retry {
 			lastErr = err
-			time.Sleep(time.Duration(attempt+1) * retryDelay)
+			if err := util.Sleep(ctx, time.Duration(attempt+1)*retryDelay); err != nil {
+				return nil, err
+			}
 
 			continue
 		}
@@ -140,47 +146,103 @@ func (s *Service) AddInfliction(req ModelRequest) error {
 
 	s.log.Debug("adding infliction", "url", s.url+"/addInfliction", "xuid", req.XUID, "name", req.Name)
 
-	var lastErr error
+	if err := s.sendNoContent(http.MethodPost, "/addInfliction", body, "add infliction"); err != nil {
+		return err
+	}
 
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if s.closed.Load() {
-			break
-		}
-		if attempt > 0 {
-			time.Sleep(retryDelay * time.Duration(1<<attempt))
-		}
+	s.log.Debug("added infliction", "xuid", req.XUID, "name", req.Name)
 
-		resp, err := s.attempt(http.MethodPost, "/addInfliction", body)
-		if err != nil {
-			lastErr = err
-			if isTemporaryError(err) {
-				continue
-			}
+	return nil
+}
 
-			return err
-		}
+// RemoveInfliction removes an existing infliction (un-ban, un-mute, etc.).
+func (s *Service) RemoveInfliction(req ModelRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
 
-		err = decodeNoContentResponse(resp, "add infliction")
-		closeBody(resp)
-		if err == nil {
-			s.log.Debug("added infliction", "xuid", req.XUID, "name", req.Name)
+	if err := s.sendNoContent(http.MethodDelete, "/removeInfliction", body, "remove infliction"); err != nil {
+		return err
+	}
 
-			return nil
-		}
+	s.log.Debug("removed infliction", "xuid", req.XUID, "name", req.Name)
+
+	return nil
+}
+
+// updatesEnabled is set if the moderation API supports updating
+// inflictions, which is newer than adding and removing them.
+var updatesEnabled atomic.Bool
 
+// EnableUpdates sets whether the moderation API supports updating
+// inflictions through UpdateInfliction.
+func EnableUpdates(enabled bool) {
+	updatesEnabled.Store(enabled)
+}
+
+// UpdatesEnabled reports whether inflictions may be updated in place.
+func UpdatesEnabled() bool {
+	return updatesEnabled.Load()
+}
+
+// UpdateInfliction changes the expiry date of an existing current
+// infliction, used to extend or shorten a punishment in place. It fails
+// unless updates were enabled with EnableUpdates.
+func (s *Service) UpdateInfliction(req UpdateRequest) error {
+	if !UpdatesEnabled() {
+		return errors.New("updating inflictions is disabled")
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if err := s.sendNoContent(http.MethodPatch, "/updateInfliction", body, "update infliction"); err != nil {
 		return err
 	}
 
-	return lastErr
+	s.log.Debug("updated infliction", "xuid", req.XUID, "name", req.Name)
+
+	return nil
 }
 
-// RemoveInfliction removes an existing infliction (un-ban, un-mute, etc.).
-func (s *Service) RemoveInfliction(req ModelRequest) error {
+// AddReport forwards a player report to the moderation API.
+func (s *Service) AddReport(req ReportRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	if err := s.sendNoContent(http.MethodPost, "/addReport", body, "add report"); err != nil {
+		return err
+	}
+
+	s.log.Debug("added report", "reporter", req.ReporterName, "target", req.TargetName, "category", req.Category)
+
+	return nil
+}
+
+// AddAuditLog records an entry in the moderation audit log.
+func (s *Service) AddAuditLog(req AuditRequest) error {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	if err := s.sendNoContent(http.MethodPost, "/addAuditLog", body, "add audit log"); err != nil {
+		return err
+	}
+
+	s.log.Debug("added audit log entry", "actor", req.Actor, "action", req.Action)
+
+	return nil
+}
+
+// sendNoContent issues a request that is expected to answer with 204 No
+// Content, retrying temporary network failures.
+func (s *Service) sendNoContent(method, path string, body []byte, what string) error {
 	var lastErr error
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
@@ -191,7 +253,7 @@ func (s *Service) RemoveInfliction(req ModelRequest) error {
 			time.Sleep(retryDelay * time.Duration(1<<attempt))
 		}
 
-		resp, err := s.attempt(http.MethodDelete, "/removeInfliction", body)
+		resp, err := s.attempt(context.Background(), method, path, body)
 		if err != nil {
 			lastErr = err
 			if isTemporaryError(err) {
@@ -201,13 +263,8 @@ func (s *Service) RemoveInfliction(req ModelRequest) error {
 			return err
 		}
 
-		err = decodeNoContentResponse(resp, "remove infliction")
+		err = decodeNoContentResponse(resp, what)
 		closeBody(resp)
-		if err == nil {
-			s.log.Debug("removed infliction", "xuid", req.XUID, "name", req.Name)
-
-			return nil
-		}
 
 		return err
 	}
@@ -215,12 +272,12 @@ func (s *Service) RemoveInfliction(req ModelRequest) error {
 	return lastErr
 }
 
-// attempt issues a single HTTP request against the moderation API. The
-// caller is responsible for closing the response body once it has been
-// drained (use closeBody if no further processing is needed, otherwise
-// close it manually).
-func (s *Service) attempt(method, path string, body []byte) (*http.Response, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+// attempt issues a single HTTP request against the moderation API, given up
+// when ctx is done. The caller is responsible for closing the response body
+// once it has been drained (use closeBody if no further processing is
+// needed, otherwise close it manually).
+func (s *Service) attempt(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
 
 	httpReq, err := http.NewRequestWithContext(ctx, method, s.url+path, bytes.NewReader(body))
 	if err != nil {
@@ -375,7 +432,7 @@ func sendPlayerDetails(req PlayerDetails) {
 
 	s.log.Debug("sending player details", "url", s.url+"/playerDetails", "name", req.Name, "xuid", req.XUID)
 
-	resp, err := s.attempt(http.MethodPost, "/playerDetails", body)
+	resp, err := s.attempt(context.Background(), http.MethodPost, "/playerDetails", body)
 	if err != nil {
 		s.log.Error("player details request failed", "error", err)
 
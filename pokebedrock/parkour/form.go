@@ -13,7 +13,7 @@ import (
 // startForm ...
 func (m *Manager) startForm(p *player.Player, cfg CourseConfig) {
 	best := m.lb.best(cfg.Identifier, p.XUID())
-	p.SendForm(form.NewModal(startForm{
+	session.SendForm(p, form.NewModal(startForm{
 		Yes: form.YesButton(), No: form.NoButton(),
 		manager: m, course: cfg,
 	}, text.Colourf("<green>%s</green>", cfg.Name)).
@@ -252,6 +252,18 @@ func (m *Manager) Reset(tx *world.Tx, courseID, xuid string) {
 	})
 }
 
+// PersonalBest returns the fastest time of the player with the given XUID
+// across all courses, along with the name of that course.
+func (m *Manager) PersonalBest(xuid string) (course string, best time.Duration, ok bool) {
+	for id, c := range m.courses {
+		if b := m.lb.best(id, xuid); b > 0 && (!ok || b < best) {
+			course, best, ok = c.Name, b, true
+		}
+	}
+
+	return course, best, ok
+}
+
 // updateLeaderboardText ...
 func (m *Manager) updateLeaderboardText(tx *world.Tx, courseID string) {
 	course, ok := m.courses[courseID]
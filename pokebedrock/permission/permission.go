@@ -0,0 +1,272 @@
+// Package permission resolves permission nodes granted to players through
+// their ranks and per-account overrides.
+package permission
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+// Permission nodes checked by the hub.
+const (
+	// CommandList allows /list.
+	CommandList = "command.list"
+	// ModerationBan allows /moderate to create and remove inflictions,
+	// including bans and mutes.
+	ModerationBan = "moderation.ban"
+	// ModerationKick allows /kick.
+	ModerationKick = "moderation.kick"
+	// ParkourReset allows /parkour_reset.
+	ParkourReset = "parkour.reset"
+	// ReportManage allows /reports and receiving report notifications.
+	ReportManage = "report.manage"
+	// StaffChat allows reading and writing staff chat.
+	StaffChat = "staffchat.use"
+	// ChatColour allows formatting codes in public chat messages.
+	ChatColour = "chat.colour"
+	// SocialSpy allows /socialspy, reading private messages between other
+	// players.
+	SocialSpy = "chat.socialspy"
+	// Broadcast allows /broadcast.
+	Broadcast = "staffchat.broadcast"
+	// VPNManage allows /vpn.
+	VPNManage = "vpn.manage"
+	// LocaleReload allows /locale reload.
+	LocaleReload = "locale.reload"
+	// QueueBypassBeta allows queueing for servers under beta lock.
+	QueueBypassBeta = "queue.bypass.beta"
+	// QueueBypassDowntime allows queueing for servers under downtime lock.
+	QueueBypassDowntime = "queue.bypass.downtime"
+	// AFKExempt exempts the account from being kicked for being AFK.
+	AFKExempt = "afk.exempt"
+	// AuthBypass lets the account join downstream servers without hub
+	// authentication.
+	AuthBypass = "auth.bypass"
+)
+
+// Group configures the permissions of a rank. Nodes prefixed with "-" are
+// revoked. "*" and nodes ending in ".*" match every node below them.
+type Group struct {
+	// Inherits lists the IDs of the ranks whose permissions are included.
+	Inherits []string
+	// Nodes lists the nodes granted or revoked by the rank.
+	Nodes []string
+}
+
+// Config holds the permissions granted to ranks and accounts.
+type Config struct {
+	// Ranks maps rank IDs to their permissions.
+	Ranks map[string]Group
+	// Overrides maps XUIDs to nodes granted or revoked for that account
+	// only, taking precedence over every rank.
+	Overrides map[string][]string
+}
+
+// DefaultConfig returns permissions equivalent to the rank requirements
+// used before permission nodes, so every built-in rank inherits the rank
+// below it. Premium skips Supporter so beta access stays with Supporter and
+// staff.
+func DefaultConfig() Config {
+	chain := func(parent string, nodes ...string) Group {
+		return Group{Inherits: []string{parent}, Nodes: nodes}
+	}
+
+	return Config{
+		Ranks: map[string]Group{
+			"trainer":                {Nodes: []string{CommandList}},
+			"server-booster":         chain("trainer"),
+			"supporter":              chain("server-booster", ChatColour, QueueBypassBeta),
+			"premium":                chain("server-booster", ChatColour),
+			"content-creator":        chain("premium"),
+			"monthly-tournament-mvp": chain("content-creator"),
+			"retired-staff":          chain("monthly-tournament-mvp"),
+			"helper":                 chain("retired-staff", StaffChat, AFKExempt),
+			"team":                   chain("helper"),
+			"translator":             chain("team"),
+			"development-team":       chain("translator"),
+			"trail-modeler":          chain("development-team"),
+			"modeler":                chain("trail-modeler"),
+			"head-modeler":           chain("modeler"),
+			"moderator":              chain("head-modeler", ModerationBan, ModerationKick, ReportManage, SocialSpy, QueueBypassBeta),
+			"senior-moderator":       chain("moderator", QueueBypassDowntime),
+			"head-moderator":         chain("senior-moderator", ParkourReset, VPNManage, AuthBypass),
+			"admin":                  chain("head-moderator", Broadcast, LocaleReload),
+			"manager":                chain("admin"),
+			"owner":                  chain("manager", "*"),
+		},
+	}
+}
+
+// set is a resolved list of granted and revoked nodes.
+type set struct {
+	grant  []string
+	revoke []string
+}
+
+// add adds a configured node to the set, replacing an earlier grant or
+// revocation of the same node.
+func (s *set) add(node string) {
+	node = strings.ToLower(strings.TrimSpace(node))
+	revoked, revoke := strings.CutPrefix(node, "-")
+	if revoked == "" {
+		return
+	}
+
+	s.grant = slices.DeleteFunc(s.grant, func(n string) bool { return n == revoked })
+	s.revoke = slices.DeleteFunc(s.revoke, func(n string) bool { return n == revoked })
+	if revoke {
+		s.revoke = append(s.revoke, revoked)
+	} else {
+		s.grant = append(s.grant, revoked)
+	}
+}
+
+// match returns how specifically the set decides node, and whether it
+// grants it. A specificity of -1 means the set doesn't mention the node.
+// Revocations win over grants of equal specificity.
+func (s set) match(node string) (int, bool) {
+	best, granted := -1, false
+	for _, pattern := range s.grant {
+		if n := specificity(pattern, node); n > best {
+			best, granted = n, true
+		}
+	}
+	for _, pattern := range s.revoke {
+		if n := specificity(pattern, node); n >= best && n >= 0 {
+			best, granted = n, false
+		}
+	}
+
+	return best, granted
+}
+
+// specificity returns how closely pattern matches node, higher being more
+// specific, or -1 if it doesn't match.
+func specificity(pattern, node string) int {
+	switch {
+	case pattern == node:
+		return len(pattern) + 1
+	case pattern == "*":
+		return 0
+	case strings.HasSuffix(pattern, ".*") && strings.HasPrefix(node, pattern[:len(pattern)-1]):
+		return len(pattern) - 1
+	}
+
+	return -1
+}
+
+// resolved holds the loaded permissions.
+type resolved struct {
+	ranks     map[rank.Rank]set
+	overrides map[string]set
+}
+
+// loaded holds the permissions in use. It starts with the default config.
+var loaded atomic.Pointer[resolved]
+
+func init() {
+	if err := Load(DefaultConfig()); err != nil {
+		panic(err)
+	}
+}
+
+// Load resolves and replaces the permissions in use. Rank definitions must
+// be loaded first, as every rank ID must be defined. Inheritance cycles are
+// rejected.
+func Load(conf Config) error {
+	res := &resolved{
+		ranks:     make(map[rank.Rank]set, len(conf.Ranks)),
+		overrides: make(map[string]set, len(conf.Overrides)),
+	}
+
+	groups := make(map[string]Group, len(conf.Ranks))
+	for id, g := range conf.Ranks {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if _, ok := rank.ByID(id); !ok {
+			return fmt.Errorf("permissions configured for unknown rank %q", id)
+		}
+		groups[id] = g
+	}
+
+	var resolve func(id string, path []string) (set, error)
+	resolve = func(id string, path []string) (set, error) {
+		if slices.Contains(path, id) {
+			return set{}, fmt.Errorf("rank inheritance cycle: %s", strings.Join(append(path, id), " -> "))
+		}
+		r, ok := rank.ByID(id)
+		if !ok {
+			return set{}, fmt.Errorf("rank %q inherits unknown rank %q", path[len(path)-1], id)
+		}
+		if s, ok := res.ranks[r]; ok {
+			return s, nil
+		}
+
+		// Nodes of the rank itself are added last so they replace inherited
+		// ones.
+		var s set
+		g := groups[id]
+		for _, parent := range g.Inherits {
+			inherited, err := resolve(strings.ToLower(strings.TrimSpace(parent)), append(path, id))
+			if err != nil {
+				return set{}, err
+			}
+			for _, node := range inherited.grant {
+				s.add(node)
+			}
+			for _, node := range inherited.revoke {
+				s.add("-" + node)
+			}
+		}
+		for _, node := range g.Nodes {
+			s.add(node)
+		}
+
+		res.ranks[r] = s
+
+		return s, nil
+	}
+
+	for id := range groups {
+		if _, err := resolve(id, nil); err != nil {
+			return err
+		}
+	}
+
+	for xuid, nodes := range conf.Overrides {
+		var s set
+		for _, node := range nodes {
+			s.add(node)
+		}
+		res.overrides[strings.TrimSpace(xuid)] = s
+	}
+
+	loaded.Store(res)
+
+	return nil
+}
+
+// Has reports whether the account with the given XUID and ranks holds
+// node. Overrides of the account decide first; otherwise the node is held
+// if any of the ranks grants it without revoking it.
+func Has(xuid string, ranks []rank.Rank, node string) bool {
+	res := loaded.Load()
+	node = strings.ToLower(node)
+
+	if s, ok := res.overrides[xuid]; ok {
+		if n, granted := s.match(node); n >= 0 {
+			return granted
+		}
+	}
+
+	for _, r := range ranks {
+		if _, granted := res.ranks[r].match(node); granted {
+			return true
+		}
+	}
+
+	return false
+}
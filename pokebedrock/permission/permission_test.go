@@ -0,0 +1,93 @@
+package permission
+
+import (
+	"testing"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+func TestDefaultsMatchRankRequirements(t *testing.T) {
+	tests := []struct {
+		ranks []rank.Rank
+		node  string
+		want  bool
+	}{
+		{ranks: []rank.Rank{rank.UnLinked}, node: CommandList, want: false},
+		{ranks: []rank.Rank{rank.Trainer}, node: CommandList, want: true},
+		{ranks: []rank.Rank{rank.Supporter}, node: QueueBypassBeta, want: true},
+		{ranks: []rank.Rank{rank.Premium}, node: QueueBypassBeta, want: false},
+		{ranks: []rank.Rank{rank.Premium, rank.Supporter}, node: QueueBypassBeta, want: true},
+		{ranks: []rank.Rank{rank.Modeler}, node: StaffChat, want: true},
+		{ranks: []rank.Rank{rank.Moderator}, node: QueueBypassBeta, want: true},
+		{ranks: []rank.Rank{rank.Moderator}, node: QueueBypassDowntime, want: false},
+		{ranks: []rank.Rank{rank.HeadModerator}, node: AuthBypass, want: true},
+		{ranks: []rank.Rank{rank.Admin}, node: CommandList, want: true},
+		{ranks: []rank.Rank{rank.Owner}, node: "anything.at.all", want: true},
+	}
+	for _, tt := range tests {
+		if got := Has("", tt.ranks, tt.node); got != tt.want {
+			t.Errorf("Has(%v, %s) = %v, want %v", tt.ranks, tt.node, got, tt.want)
+		}
+	}
+}
+
+func TestInheritanceAndOverrides(t *testing.T) {
+	t.Cleanup(func() { _ = Load(DefaultConfig()) })
+
+	err := Load(Config{
+		Ranks: map[string]Group{
+			"helper":    {Nodes: []string{"moderation.*", "-moderation.ban"}},
+			"moderator": {Inherits: []string{"helper"}, Nodes: []string{"moderation.ban"}},
+		},
+		Overrides: map[string][]string{
+			"1": {"-moderation.kick", "parkour.reset"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		xuid string
+		r    rank.Rank
+		node string
+		want bool
+	}{
+		{r: rank.Helper, node: "moderation.kick", want: true},
+		{r: rank.Helper, node: "moderation.ban", want: false},
+		{r: rank.Moderator, node: "moderation.ban", want: true},
+		{r: rank.Moderator, node: "moderation.kick", want: true},
+		{xuid: "1", r: rank.Moderator, node: "moderation.kick", want: false},
+		{xuid: "1", r: rank.Trainer, node: "parkour.reset", want: true},
+		{xuid: "1", r: rank.Moderator, node: "moderation.ban", want: true},
+	}
+	for _, tt := range tests {
+		if got := Has(tt.xuid, []rank.Rank{tt.r}, tt.node); got != tt.want {
+			t.Errorf("Has(%q, %v, %s) = %v, want %v", tt.xuid, tt.r, tt.node, got, tt.want)
+		}
+	}
+}
+
+func TestLoadRejectsInvalidConfig(t *testing.T) {
+	t.Cleanup(func() { _ = Load(DefaultConfig()) })
+
+	tests := map[string]Config{
+		"unknown rank": {Ranks: map[string]Group{"nobody": {}}},
+		"unknown parent": {Ranks: map[string]Group{
+			"helper": {Inherits: []string{"nobody"}},
+		}},
+		"cycle": {Ranks: map[string]Group{
+			"helper":    {Inherits: []string{"moderator"}},
+			"moderator": {Inherits: []string{"helper"}},
+		}},
+	}
+	for name, conf := range tests {
+		if err := Load(conf); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if !Has("", []rank.Rank{rank.Trainer}, CommandList) {
+		t.Fatal("failed load replaced the loaded permissions")
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
-	"sort"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/df-mc/dragonfly/server"
@@ -16,27 +17,40 @@ import (
 	"github.com/df-mc/dragonfly/server/world"
 	"github.com/gin-gonic/gin"
 	"github.com/samber/lo"
-	"github.com/sandertv/gophertunnel/minecraft/text"
+	"golang.org/x/text/language"
 
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/afk"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/authentication"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/capacity"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/chatfilter"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/chatformat"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/command"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/flood"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/handler"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/hider"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/link"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/parkour"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/privatemsg"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/proxyproto"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/queue"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/report"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/resources"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/restart"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/scoreboard"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/settings"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/slapper"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/srv"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/staffchat"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/status"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/vpn"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/watchdog"
-	"golang.org/x/text/language"
 )
 
 const (
@@ -63,6 +77,8 @@ type PokeBedrock struct {
 	srv        *server.Server
 	resManager *resources.Manager
 	watchdog   *watchdog.Watchdog
+	afk        afk.Policy
+	capacity   *capacity.Manager
 
 	c chan struct{}
 }
@@ -84,29 +100,13 @@ func NewPokeBedrock(log *slog.Logger, conf Config) (*PokeBedrock, error) {
 
 	settings.SetDowntimeLock(conf.PokeBedrock.DowntimeLock)
 
-	// Initialize rank system with configuration
-	rank.InitializeRanks(rank.RankConfig{
-		TrainerRoleID:              conf.Ranks.TrainerRoleID,
-		ServerBoosterRoleID:        conf.Ranks.ServerBoosterRoleID,
-		SupporterRoleID:            conf.Ranks.SupporterRoleID,
-		PremiumRoleID:              conf.Ranks.PremiumRoleID,
-		ContentCreatorRoleID:       conf.Ranks.ContentCreatorRoleID,
-		MonthlyTournamentMVPRoleID: conf.Ranks.MonthlyTournamentMVPRoleID,
-		RetiredStaffRoleID:         conf.Ranks.RetiredStaffRoleID,
-		HelperRoleID:               conf.Ranks.HelperRoleID,
-		TeamRoleID:                 conf.Ranks.TeamRoleID,
-		TranslatorRoleID:           conf.Ranks.TranslatorRoleID,
-		DevelopmentTeamRoleID:      conf.Ranks.DevelopmentTeamRoleID,
-		TrailModelerRoleID:         conf.Ranks.TrailModelerRoleID,
-		ModelerRoleID:              conf.Ranks.ModelerRoleID,
-		HeadModelerRoleID:          conf.Ranks.HeadModelerRoleID,
-		ModeratorRoleID:            conf.Ranks.ModeratorRoleID,
-		SeniorModeratorRoleID:      conf.Ranks.SeniorModeratorRoleID,
-		HeadModeratorRoleID:        conf.Ranks.HeadModeratorRoleID,
-		AdminRoleID:                conf.Ranks.AdminRoleID,
-		ManagerRoleID:              conf.Ranks.ManagerRoleID,
-		OwnerRoleID:                conf.Ranks.OwnerRoleID,
-	})
+	// The default rank definitions stay loaded if the configured ones are invalid.
+	if err := rank.Load(conf.Ranks.Definitions); err != nil {
+		log.Error("invalid rank definitions, using defaults", "error", err)
+	}
+	if err := permission.Load(conf.Permissions); err != nil {
+		log.Error("invalid permissions, using defaults", "error", err)
+	}
 
 	poke := &PokeBedrock{
 		log:  log,
@@ -130,7 +130,24 @@ func NewPokeBedrock(log *slog.Logger, conf Config) (*PokeBedrock, error) {
 		return world.NopGenerator{}
 	}
 	c.StatusProvider = status.NewProvider(c.Name, c.Name) // ensures synchronized server count display.
-	c.Allower = &Allower{}
+	poke.afk = afk.NewPolicy(log, conf.PokeBedrock.AFKKickOrder, conf.PokeBedrock.AFKExemptQueued)
+	poke.capacity = poke.newCapacity()
+	if poke.capacity.Enabled() {
+		// Players are refused by the listener before reaching the
+		// allower, so let through everybody a reserved slot might fit.
+		c.MaxPlayers = poke.capacity.MaxPlayers()
+	}
+	c.Allower = poke.newAllower()
+	if conf.Proxy.ProxyProtocol {
+		// Replace the default listener so connections report the client
+		// address carried in PROXY protocol headers.
+		c.Listeners = []func(server.Config) (server.Listener, error){
+			proxyproto.ListenerFunc(conf.UserConfig.Network.Address, proxyproto.NewNetwork(log, proxyproto.Config{
+				TrustedProxies: conf.Proxy.TrustedProxies,
+				IdleTimeout:    time.Duration(conf.Proxy.IdleTimeout),
+			})),
+		}
+	}
 
 	poke.srv = c.New()
 	poke.srv.CloseOnProgramEnd()
@@ -200,7 +217,8 @@ func (poke *PokeBedrock) loadWatchdog(w *world.World) {
 	poke.watchdog.Start()
 }
 
-// setupGin sets up gin for the gobds proxy.
+// setupGin sets up gin for the gobds proxy. When gobds relays players to the
+// hub, enable [Proxy] ProxyProtocol so their real addresses are seen.
 func (poke *PokeBedrock) setupGin() error {
 	gin.SetMode(gin.ReleaseMode)
 
@@ -219,11 +237,11 @@ func (poke *PokeBedrock) setupGin() error {
 			return
 		}
 
-		// If player (xuid) has a rank of mod or above bypass authentication
-		roles, err := rank.GlobalService().RolesOfXUID(xuid)
+		// If player (xuid) holds the auth bypass permission skip authentication.
+		// Cached roles keep the bypass working during a rank API outage.
+		roles, _, err := rank.GlobalService().RolesOrCached(context.Background(), xuid)
 		if err == nil {
-			highestRank := rank.GetHighestRank(roles)
-			if highestRank >= rank.HeadModerator {
+			if permission.Has(xuid, rank.RolesToRanks(roles), permission.AuthBypass) {
 				c.JSON(http.StatusOK, gin.H{"allowed": true})
 
 				return
@@ -249,6 +267,51 @@ func (poke *PokeBedrock) setupGin() error {
 		c.JSON(http.StatusOK, gin.H{"allowed": true})
 	})
 
+	// Called by the Discord bot when a member's roles change, so online
+	// players get their new rank without re-fetching it themselves.
+	rankGroup := router.Group("/ranks", poke.requireAuthentication)
+	rankGroup.POST("/refresh/:xuid", func(c *gin.Context) {
+		xuid := c.Param("xuid")
+		if xuid == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing xuid"})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"online": session.RefreshRanks(xuid)})
+	})
+
+	// Link endpoints, called by the Discord bot when a player runs /link
+	// with the code issued in the hub.
+	linkGroup := router.Group("/link", poke.requireAuthentication)
+	{
+		// Redeem a link code for the XUID it was issued to
+		linkGroup.POST("/redeem", func(c *gin.Context) {
+			var req struct {
+				Code string `json:"code" binding:"required"`
+			}
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request format", "details": err.Error()})
+
+				return
+			}
+
+			code, ok := link.Global().Redeem(req.Code)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"reason": "invalid or expired code"})
+
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{"xuid": code.XUID, "name": code.Name})
+		})
+
+		// Confirm the account was linked, syncing the player's rank
+		linkGroup.POST("/confirm/:xuid", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"online": session.RefreshRanks(c.Param("xuid"))})
+		})
+	}
+
 	// Restart Manager endpoints
 	restartGroup := router.Group("/restart")
 	{
@@ -334,6 +397,8 @@ func (poke *PokeBedrock) setupGin() error {
 		})
 	}
 
+	poke.registerVpnRoutes(router)
+
 	err := router.Run(poke.conf.Service.GinAddress)
 	if err != nil {
 		return err
@@ -344,16 +409,77 @@ func (poke *PokeBedrock) setupGin() error {
 	return nil
 }
 
+// newAllower creates the login allower from the configuration. Invalid fail
+// policies are logged and fall back to their defaults.
+func (poke *PokeBedrock) newAllower() *Allower {
+	conf := AllowerConfig{Deadline: time.Duration(poke.conf.Login.Deadline)}
+
+	for _, p := range []struct {
+		name   string
+		value  string
+		def    FailPolicy
+		target *FailPolicy
+	}{
+		{"VpnFailPolicy", poke.conf.Login.VpnFailPolicy, FailClosed, &conf.VPN},
+		{"RankFailPolicy", poke.conf.Login.RankFailPolicy, FailClosed, &conf.Rank},
+		{"InflictionFailPolicy", poke.conf.Login.InflictionFailPolicy, FailOpen, &conf.Infliction},
+	} {
+		policy, err := ParseFailPolicy(p.value, p.def)
+		if err != nil {
+			poke.log.Warn("using default login fail policy", "setting", p.name, "default", p.def, "error", err)
+
+			policy = p.def
+		}
+		*p.target = policy
+	}
+
+	if poke.capacity.Enabled() {
+		conf.Admit = func(ctx context.Context, xuid string, r rank.Rank) bool {
+			return poke.capacity.Admit(ctx, poke.srv.World(), xuid, r)
+		}
+	}
+
+	return NewAllower(conf)
+}
+
+// newCapacity creates the hub capacity manager from the configuration.
+// Reservations for unknown ranks are logged and skipped.
+func (poke *PokeBedrock) newCapacity() *capacity.Manager {
+	conf := capacity.Config{
+		SoftCap:  poke.conf.UserConfig.Players.MaxCount,
+		Displace: poke.conf.Capacity.Displace,
+		Policy:   poke.afk,
+	}
+	for _, res := range poke.conf.Capacity.Reserved {
+		r, ok := rank.ByID(res.Rank)
+		if !ok {
+			poke.log.Warn("unknown rank in capacity reservation, skipped", "rank", res.Rank)
+
+			continue
+		}
+		conf.Reserved = append(conf.Reserved, capacity.Tier{Rank: r, Slots: res.Slots})
+	}
+
+	return capacity.NewManager(poke.log, conf)
+}
+
 // loadLocales registers all the locales active on the server.
 func (poke *PokeBedrock) loadLocales() error {
-	path := poke.conf.PokeBedrock.LocalePath
-
-	locales := []language.Tag{
-		language.English,
+	langs, err := locale.RegisterAll(poke.conf.PokeBedrock.LocalePath)
+	if err != nil {
+		return err
 	}
-	for _, l := range locales {
-		if err := locale.Register(l, path); err != nil {
-			return err
+
+	poke.log.Info("loaded locales", "languages", langs)
+	for _, c := range locale.Check() {
+		switch {
+		case len(c.Missing) > 0 && c.Language == language.English:
+			poke.log.Error("English locale is missing keys", "keys", c.Missing)
+		case len(c.Missing) > 0:
+			poke.log.Warn("locale is missing keys, English is used instead", "language", c.Language, "keys", c.Missing)
+		}
+		if len(c.Unused) > 0 {
+			poke.log.Info("locale has unused keys", "language", c.Language, "keys", c.Unused)
 		}
 	}
 
@@ -362,17 +488,102 @@ func (poke *PokeBedrock) loadLocales() error {
 
 // loadCommands registers all the commands on the server.
 func (poke *PokeBedrock) loadCommands() {
-	cmd.Register(command.NewModerate(rank.Moderator))
-	cmd.Register(command.NewKick(rank.Moderator))
-	cmd.Register(command.NewList(rank.Trainer))
-	cmd.Register(command.NewParkourReset(rank.HeadModerator))
+	cmd.Register(command.NewModerate(permission.ModerationBan))
+	cmd.Register(command.NewKick(permission.ModerationKick))
+	cmd.Register(command.NewList(permission.CommandList))
+	cmd.Register(command.NewParkourReset(permission.ParkourReset))
+	cmd.Register(command.NewReport())
+	cmd.Register(command.NewLink())
+	cmd.Register(command.NewSettings())
+	cmd.Register(command.NewScoreboard())
+	cmd.Register(command.NewMsg())
+	cmd.Register(command.NewReply())
+	cmd.Register(command.NewIgnore())
+	cmd.Register(command.NewAFK())
+	cmd.Register(command.NewReports(permission.ReportManage))
+	cmd.Register(command.NewStaffChat(permission.StaffChat))
+	cmd.Register(command.NewSocialSpy(permission.SocialSpy))
+	cmd.Register(command.NewBroadcast(permission.Broadcast))
+	cmd.Register(command.NewVpn(permission.VPNManage))
+	cmd.Register(command.NewLocale(permission.LocaleReload, poke.conf.PokeBedrock.LocalePath))
 }
 
 // loadServices loads all the services.
 func (poke *PokeBedrock) loadServices() {
-	rank.NewService(poke.log, poke.conf.Service.RolesURL)
+	rank.NewService(poke.log, rank.Config{
+		URL:             poke.conf.Service.RolesURL,
+		CachePath:       poke.conf.RankCache.Path,
+		StaleWindow:     time.Duration(poke.conf.RankCache.StaleWindow),
+		RefreshInterval: time.Duration(poke.conf.RankCache.RefreshInterval),
+	})
+	rank.GlobalService().SetRefreshHandler(session.ApplyRefreshedRoles)
 	moderation.NewService(poke.log, poke.conf.Service.ModerationURL, poke.conf.Service.ModerationKey)
-	vpn.NewService(poke.log, poke.conf.Service.VpnURL, poke.conf.Service.VpnCachePath, poke.conf.Service.VpnWhitelist)
+	moderation.EnableUpdates(poke.conf.Moderation.AdjustInflictions)
+	poke.loadLadders()
+	poke.loadChatFilter()
+	poke.loadChatFormat()
+	staffchat.NewChannel(poke.log, staffchat.Config{
+		Prefix:           poke.conf.StaffChat.Prefix,
+		MirrorToAuditLog: poke.conf.StaffChat.MirrorToAuditLog,
+	})
+	profile.NewStore(poke.log, profile.Config{Dir: poke.conf.Profiles.Dir})
+	link.NewManager(poke.log, link.Config{
+		CodeLength: poke.conf.Link.CodeLength,
+		Expiry:     time.Duration(poke.conf.Link.Expiry),
+		Path:       poke.conf.Link.Path,
+	})
+	scoreboard.NewManager(poke.log, scoreboard.Config{
+		Enabled:         poke.conf.Scoreboard.Enabled,
+		Lines:           poke.conf.Scoreboard.Lines,
+		RefreshInterval: time.Duration(poke.conf.Scoreboard.RefreshInterval),
+		Events:          poke.conf.Scoreboard.Events,
+		PersonalBest: func(xuid string) (string, time.Duration, bool) {
+			if m := parkour.Global(); m != nil {
+				return m.PersonalBest(xuid)
+			}
+			return "", 0, false
+		},
+	})
+	privatemsg.NewService(poke.log, privatemsg.Config{
+		RateLimitMessages: poke.conf.PrivateMessages.RateLimitMessages,
+		RateLimitWindow:   time.Duration(poke.conf.PrivateMessages.RateLimitWindow),
+		MaxIgnored:        poke.conf.PrivateMessages.MaxIgnored,
+	})
+	report.NewManager(poke.log, report.Config{
+		Path:       poke.conf.Reports.Path,
+		Cooldown:   time.Duration(poke.conf.Reports.Cooldown),
+		Categories: poke.conf.Reports.Categories,
+	})
+	flood.NewLimiter(poke.log, flood.Config{
+		PerIP:            poke.conf.JoinLimits.PerIP,
+		PerSubnet:        poke.conf.JoinLimits.PerSubnet,
+		Global:           poke.conf.JoinLimits.Global,
+		MaxAccountsPerIP: poke.conf.JoinLimits.MaxAccountsPerIP,
+		AttackThreshold:  poke.conf.JoinLimits.AttackThreshold,
+		AttackCooldown:   time.Duration(poke.conf.JoinLimits.AttackCooldown),
+		AttackFactor:     poke.conf.JoinLimits.AttackFactor,
+		Whitelist:        poke.conf.JoinLimits.Whitelist,
+	})
+	vpn.NewService(poke.log, vpn.Config{
+		CachePath: poke.conf.Service.VpnCachePath,
+		Whitelist: poke.conf.Service.VpnWhitelist,
+		Blocklist: poke.conf.Service.VpnBlocklist,
+		Policy:    poke.conf.Vpn.Policy,
+		Providers: poke.conf.Vpn.Providers,
+
+		PositiveTTL:       time.Duration(poke.conf.Vpn.PositiveTTL),
+		NegativeTTL:       time.Duration(poke.conf.Vpn.NegativeTTL),
+		RefreshInterval:   time.Duration(poke.conf.Vpn.RefreshInterval),
+		RefreshBatch:      poke.conf.Vpn.RefreshBatch,
+		RefreshSeenWithin: time.Duration(poke.conf.Vpn.RefreshSeenWithin),
+		EvictAfter:        time.Duration(poke.conf.Vpn.EvictAfter),
+
+		DatacenterPath: poke.conf.Vpn.DatacenterPath,
+		AsnPath:        poke.conf.Vpn.AsnPath,
+		BlocklistPath:  poke.conf.Vpn.BlocklistPath,
+		ReloadInterval: time.Duration(poke.conf.Vpn.ReloadInterval),
+		OverridesPath:  poke.conf.Vpn.OverridesPath,
+	})
 
 	// Initialize restart manager service
 	restartConfig := restart.Config{
@@ -385,6 +596,79 @@ func (poke *PokeBedrock) loadServices() {
 	restart.NewService(poke.log, restartConfig)
 }
 
+// loadLadders parses the configured punishment escalation ladders. Invalid
+// ladders are logged and skipped.
+func (poke *PokeBedrock) loadLadders() {
+	ladders := make([]moderation.Ladder, 0, len(poke.conf.Moderation.Ladders))
+	for _, c := range poke.conf.Moderation.Ladders {
+		l, err := moderation.ParseLadder(c)
+		if err != nil {
+			poke.log.Warn("ignoring invalid escalation ladder", "category", c.Category, "error", err)
+
+			continue
+		}
+		ladders = append(ladders, l)
+	}
+	moderation.SetLadders(ladders)
+}
+
+// loadChatFilter builds the public chat filter chain from the configuration.
+func (poke *PokeBedrock) loadChatFilter() {
+	cfg := poke.conf.Chat
+
+	var filters []chatfilter.Filter
+	if cfg.RateLimitMessages > 0 && cfg.RateLimitWindow > 0 {
+		filters = append(filters, chatfilter.NewRateLimit(cfg.RateLimitMessages, time.Duration(cfg.RateLimitWindow)))
+	}
+	if cfg.DuplicateWindow > 0 {
+		filters = append(filters, chatfilter.NewDuplicate(time.Duration(cfg.DuplicateWindow)))
+	}
+	if cfg.BlockLinks {
+		filters = append(filters, chatfilter.NewLinks(cfg.AllowedDomains))
+	}
+	if len(cfg.BlockedWords) > 0 || len(cfg.BlockedPatterns) > 0 {
+		b, err := chatfilter.NewBlocklist(cfg.BlockedWords, cfg.BlockedPatterns, cfg.DropBlocked)
+		if err != nil {
+			poke.log.Error("chat blocklist disabled", "error", err)
+		} else {
+			filters = append(filters, b)
+		}
+	}
+	if cfg.MaxRepeatedChars > 0 {
+		filters = append(filters, chatfilter.NewCharFlood(cfg.MaxRepeatedChars))
+	}
+	if cfg.CapsMinLength > 0 {
+		filters = append(filters, chatfilter.NewCaps(cfg.CapsMinLength, cfg.CapsMaxRatio))
+	}
+
+	chatfilter.SetGlobal(chatfilter.NewChain(
+		cfg.StrikesBeforeMute,
+		time.Duration(cfg.StrikeWindow),
+		time.Duration(cfg.AutoMuteDuration),
+		filters...,
+	))
+}
+
+// loadChatFormat sets up mentions and join/quit notices from the config.
+// An unknown notice rank is logged and announces nobody.
+func (poke *PokeBedrock) loadChatFormat() {
+	cfg := poke.conf.ChatFormat
+
+	conf := chatformat.Config{Mentions: cfg.Mentions}
+	if id := strings.TrimSpace(cfg.NoticeRank); id != "" && !strings.EqualFold(id, "off") {
+		if r, ok := rank.ByID(id); ok {
+			conf.NoticeRank = &r
+		} else {
+			poke.log.Warn("unknown join notice rank, notices disabled", "rank", id)
+		}
+	}
+
+	chatformat.NewFormatter(poke.log, conf)
+	session.SetRanksHandler(func(tx *world.Tx, p *player.Player, highest rank.Rank) {
+		chatformat.Global().HandleRanks(tx, p, highest)
+	})
+}
+
 // loadServers loads all the server configurations from the specified path
 // and registers them with the server manager. It panics if server configurations
 // cannot be read.
@@ -501,6 +785,7 @@ func (poke *PokeBedrock) startTicking() {
 				if f(slapperUpdateInterval) {
 					slapper.UpdateAll(tx)
 				}
+				scoreboard.Global().Update(tx)
 				poke.doAFKCheck(tx)
 			})
 		}
@@ -512,6 +797,7 @@ func (poke *PokeBedrock) startTicking() {
 func (poke *PokeBedrock) accept(p *player.Player) {
 	h := handler.NewPlayerHandler(p)
 	p.Handle(h)
+	poke.capacity.Joined(p.XUID())
 
 	moderation.GlobalService().SendDetailsOf(p)
 	h.HandleJoin(p, p.Tx().World())
@@ -522,49 +808,29 @@ func (poke *PokeBedrock) World() *world.World {
 	return poke.srv.World()
 }
 
-// afkCandidate pairs a player with their handler's movement tracker and their
-// current idle duration so the evaluator can sort and filter without
-// re-reading state more than once.
-type afkCandidate struct {
-	p   *player.Player
-	m   *session.Movement
-	dur time.Duration
-}
-
-// doAFKCheck sends progressive AFK warnings and, when the hub is near
-// capacity, kicks the longest-AFK players first until fullness drops below
-// the configured threshold. Soft warnings fire regardless of fullness; only
-// the final warning and the actual kicks are gated on fullness.
+// doAFKCheck marks idle players AFK and, when the hub is near capacity,
+// kicks AFK players in the order of the AFK policy until fullness drops
+// below the configured threshold. Soft warnings fire regardless of
+// fullness; only the final warning and the actual kicks are gated on
+// fullness.
 func (poke *PokeBedrock) doAFKCheck(tx *world.Tx) {
 	cfg := poke.conf.PokeBedrock
 
-	var cands []afkCandidate
-	for ent := range tx.Players() {
-		p, ok := ent.(*player.Player)
-		if !ok {
-			continue
-		}
-		h, ok := p.Handler().(*handler.PlayerHandler)
-		if !ok {
-			continue
-		}
-		m := h.Movement()
-		cands = append(cands, afkCandidate{p: p, m: m, dur: time.Since(m.LastMoveTime())})
-	}
+	cands := poke.afk.Candidates(tx, time.Now())
 	if len(cands) == 0 {
 		return
 	}
 
-	// Soft warnings always fire. Flags are reset on movement so they re-arm
+	// Soft warnings always fire. Flags are reset on activity so they re-arm
 	// once a player comes back and goes idle again.
 	for _, c := range cands {
-		if c.dur >= time.Duration(cfg.AFKWarnApproaching) && !c.m.WarnedApproaching() {
-			c.p.Message(text.Colourf("<yellow>You will be marked AFK in 1 minute. Move to reset your timer.</yellow>"))
-			c.m.SetWarnedApproaching(true)
+		if c.Idle >= time.Duration(cfg.AFKWarnApproaching) && !c.Movement.WarnedApproaching() && !c.Movement.AFK() {
+			in := util.FormatDuration(time.Duration(cfg.AFKMarkAFK - cfg.AFKWarnApproaching))
+			c.Player.Message(locale.TranslateFor(c.Player, "afk.warn.approaching", locale.Args{"in": in}))
+			c.Movement.SetWarnedApproaching(true)
 		}
-		if c.dur >= time.Duration(cfg.AFKMarkAFK) && !c.m.MarkedAFK() {
-			c.p.Message(text.Colourf("<gold>You are now AFK. Move to reset your timer.</gold>"))
-			c.m.SetMarkedAFK(true)
+		if c.Idle >= time.Duration(cfg.AFKMarkAFK) && session.SetAFK(c.Player, true) {
+			c.Player.Message(locale.TranslateFor(c.Player, "afk.marked"))
 		}
 	}
 
@@ -578,22 +844,19 @@ func (poke *PokeBedrock) doAFKCheck(tx *world.Tx) {
 	}
 
 	for _, c := range cands {
-		if c.dur >= time.Duration(cfg.AFKFinalWarning) && !c.m.WarnedFinal() {
-			c.p.Message(text.Colourf("<red>Server is near capacity. Move now or you will be kicked for being AFK.</red>"))
-			c.m.SetWarnedFinal(true)
+		if !c.Exempt && c.Idle >= time.Duration(cfg.AFKFinalWarning) && !c.Movement.WarnedFinal() {
+			c.Player.Message(locale.TranslateFor(c.Player, "afk.warn.final"))
+			c.Movement.SetWarnedFinal(true)
 		}
 	}
 
-	eligible := cands[:0:0]
-	for _, c := range cands {
-		if c.dur >= time.Duration(cfg.AFKTimeout) {
-			eligible = append(eligible, c)
-		}
-	}
+	eligible := slices.DeleteFunc(slices.Clone(cands), func(c afk.Candidate) bool {
+		return c.Exempt || c.Idle < time.Duration(cfg.AFKTimeout)
+	})
 	if len(eligible) == 0 {
 		return
 	}
-	sort.Slice(eligible, func(i, j int) bool { return eligible[i].dur > eligible[j].dur })
+	poke.afk.Sort(eligible)
 
 	thresholdCount := int(float64(maxPlayers) * cfg.AFKFullnessThreshold)
 	remaining := len(cands)
@@ -601,7 +864,7 @@ func (poke *PokeBedrock) doAFKCheck(tx *world.Tx) {
 		if remaining < thresholdCount {
 			return
 		}
-		c.p.Disconnect(text.Colourf("<red>You've been kicked for being AFK.</red>"))
+		c.Player.Disconnect(locale.TranslateFor(c.Player, "afk.kicked"))
 		remaining--
 	}
 }
@@ -622,6 +885,9 @@ func (poke *PokeBedrock) Close() {
 	poke.log.Debug("Closing Vpn Service...")
 	vpn.GlobalService().Stop()
 
+	poke.log.Debug("Stopping Join Limiter...")
+	flood.Global().Close()
+
 	poke.log.Debug("Closing Restart Manager Service...")
 	restart.GlobalService().Stop()
 
@@ -631,6 +897,14 @@ func (poke *PokeBedrock) Close() {
 	poke.log.Debug("Stopping Infliction Worker...")
 	session.StopInflictionWorker()
 
+	if manager := report.Global(); manager != nil {
+		poke.log.Debug("Flushing Reports...")
+		manager.Close()
+	}
+
+	poke.log.Debug("Flushing Link Codes...")
+	link.Global().Close()
+
 	close(poke.c)
 
 	poke.log.Debug("Stopping Server...")
@@ -641,5 +915,9 @@ func (poke *PokeBedrock) Close() {
 		manager.Close()
 	}
 
+	// Closed after the server so the quits of remaining players are saved.
+	poke.log.Debug("Flushing Player Profiles...")
+	profile.Global().Close()
+
 	poke.log.Debug("Server stopped")
 }
@@ -0,0 +1,137 @@
+package privatemsg
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
+)
+
+const (
+	// PreferenceKey is the profile preference holding a player's ignore
+	// list, stored as comma separated "xuid:name" pairs.
+	PreferenceKey = "ignored"
+
+	// ignoreLoadTimeout bounds the wait for the profile holding an ignore
+	// list. It is short since ignore lists are read on the world owner.
+	ignoreLoadTimeout = 250 * time.Millisecond
+)
+
+// Ignored is a player on an ignore list.
+type Ignored struct {
+	XUID string
+	Name string
+}
+
+// parseIgnored parses an ignore list preference.
+func parseIgnored(v string) []Ignored {
+	if v == "" {
+		return nil
+	}
+
+	var list []Ignored
+	for pair := range strings.SplitSeq(v, ",") {
+		xuid, name, ok := strings.Cut(pair, ":")
+		if !ok || xuid == "" {
+			continue
+		}
+		list = append(list, Ignored{XUID: xuid, Name: name})
+	}
+
+	return list
+}
+
+// formatIgnored formats an ignore list preference.
+func formatIgnored(list []Ignored) string {
+	pairs := make([]string, 0, len(list))
+	for _, i := range list {
+		pairs = append(pairs, i.XUID+":"+i.Name)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// IgnoreList returns the players ignored by the player with the given XUID.
+// Profiles of online players are loaded when they join, so this only waits
+// for ignoreLoadTimeout if a profile loaded late.
+func (s *Service) IgnoreList(xuid string) []Ignored {
+	ctx, cancel := context.WithTimeout(context.Background(), ignoreLoadTimeout)
+	defer cancel()
+
+	p, err := profile.Global().Load(ctx, xuid)
+	if err != nil {
+		s.log.Warn("ignore list not loaded in time", "xuid", xuid, "error", err)
+
+		return nil
+	}
+	v, _ := p.Preference(PreferenceKey)
+
+	return parseIgnored(v)
+}
+
+// Ignoring reports whether the player with the given XUID ignores other.
+func (s *Service) Ignoring(xuid, other string) bool {
+	return slices.ContainsFunc(s.IgnoreList(xuid), func(i Ignored) bool {
+		return i.XUID == other
+	})
+}
+
+// Ignore adds target to the ignore list of the player with the given XUID.
+// It returns the locale key explaining why it wasn't added, or "" if it
+// was.
+func (s *Service) Ignore(xuid string, target Ignored) string {
+	list := s.IgnoreList(xuid)
+	switch {
+	case target.XUID == xuid:
+		return "ignore.self"
+	case slices.ContainsFunc(list, func(i Ignored) bool { return i.XUID == target.XUID }):
+		return "ignore.already"
+	case s.conf.MaxIgnored > 0 && len(list) >= s.conf.MaxIgnored:
+		return "ignore.full"
+	}
+
+	// Names can't hold either separator, but make sure a rename never
+	// corrupts the list.
+	target.Name = strings.NewReplacer(",", "", ":", "").Replace(target.Name)
+	profile.Global().Update(xuid, func(p *profile.Profile) {
+		list := parseIgnored(p.Preferences[PreferenceKey])
+		if slices.ContainsFunc(list, func(i Ignored) bool { return i.XUID == target.XUID }) {
+			return
+		}
+		if p.Preferences == nil {
+			p.Preferences = make(map[string]string)
+		}
+		p.Preferences[PreferenceKey] = formatIgnored(append(list, target))
+	})
+
+	return ""
+}
+
+// Unignore removes the player with the given name from the ignore list of
+// the player with the given XUID, returning them if they were on it.
+func (s *Service) Unignore(xuid, name string) (Ignored, bool) {
+	list := s.IgnoreList(xuid)
+	i := slices.IndexFunc(list, func(i Ignored) bool {
+		return strings.EqualFold(i.Name, name)
+	})
+	if i < 0 {
+		return Ignored{}, false
+	}
+	removed := list[i]
+
+	profile.Global().Update(xuid, func(p *profile.Profile) {
+		list := slices.DeleteFunc(parseIgnored(p.Preferences[PreferenceKey]), func(i Ignored) bool {
+			return i.XUID == removed.XUID
+		})
+		if len(list) == 0 {
+			delete(p.Preferences, PreferenceKey)
+
+			return
+		}
+		p.Preferences[PreferenceKey] = formatIgnored(list)
+	})
+
+	return removed, true
+}
@@ -0,0 +1,31 @@
+package privatemsg
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIgnoredRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Ignored
+	}{
+		{name: "empty", in: "", want: nil},
+		{name: "single", in: "1:Ash", want: []Ignored{{XUID: "1", Name: "Ash"}}},
+		{name: "several", in: "1:Ash,2:Misty", want: []Ignored{{XUID: "1", Name: "Ash"}, {XUID: "2", Name: "Misty"}}},
+		{name: "malformed entries skipped", in: "1:Ash,,broken,:Brock", want: []Ignored{{XUID: "1", Name: "Ash"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseIgnored(tt.in)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("parseIgnored(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+			if again := parseIgnored(formatIgnored(got)); !slices.Equal(again, got) {
+				t.Fatalf("round trip of %v gave %v", got, again)
+			}
+		})
+	}
+}
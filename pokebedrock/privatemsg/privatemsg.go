@@ -0,0 +1,242 @@
+// Package privatemsg provides private messages between players, the social
+// spy staff use to read them, and the ignore lists that block private
+// messages and hide public chat.
+package privatemsg
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/chatfilter"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// Config holds the settings of the private message service.
+type Config struct {
+	// RateLimitMessages is the number of private messages a player may send
+	// within RateLimitWindow. 0 disables the rate limit.
+	RateLimitMessages int
+	RateLimitWindow   time.Duration
+	// MaxIgnored caps the number of players on an ignore list. 0 leaves it
+	// uncapped.
+	MaxIgnored int
+}
+
+// handler is implemented by player handlers that track ranks and
+// inflictions.
+type handler interface {
+	Ranks() *session.Ranks
+	Inflictions() *session.Inflictions
+	AutoMute(p *player.Player, v chatfilter.Verdict)
+}
+
+// partner is the player someone last exchanged a private message with.
+type partner struct {
+	xuid, name string
+}
+
+// Service delivers private messages and keeps track of who replies go to
+// and which staff members have social spy on.
+type Service struct {
+	log   *slog.Logger
+	conf  Config
+	limit *chatfilter.RateLimit
+
+	mu      sync.Mutex
+	replies map[string]partner
+	spies   map[string]struct{}
+}
+
+// global holds the singleton private message service. It starts out without
+// a rate limit so messaging works before NewService is called.
+var global = newService(slog.Default(), Config{})
+
+// Global returns the singleton private message service.
+func Global() *Service {
+	return global
+}
+
+// NewService initialises the singleton private message service.
+func NewService(log *slog.Logger, conf Config) *Service {
+	global = newService(log, conf)
+
+	return global
+}
+
+// newService creates a private message service.
+func newService(log *slog.Logger, conf Config) *Service {
+	s := &Service{
+		log:     log,
+		conf:    conf,
+		replies: make(map[string]partner),
+		spies:   make(map[string]struct{}),
+	}
+	if conf.RateLimitMessages > 0 && conf.RateLimitWindow > 0 {
+		s.limit = chatfilter.NewRateLimit(conf.RateLimitMessages, conf.RateLimitWindow)
+	}
+
+	return s
+}
+
+// Send delivers a private message from sender to target, copying it to
+// staff with social spy on. It returns the locale key explaining to the
+// sender why the message wasn't sent, or "" if it was. Must be called on
+// the world owner.
+func (s *Service) Send(tx *world.Tx, sender, target *player.Player, message string) string {
+	message = strings.TrimSpace(message)
+	switch {
+	case message == "":
+		return "msg.empty"
+	case sender.H() == target.H():
+		return "msg.self"
+	}
+
+	if h, ok := sender.Handler().(handler); ok {
+		if h.Inflictions().MutedNow() {
+			return "mute.message"
+		}
+		if !h.Ranks().HighestRank().CanChat() {
+			return "chat.discord.linked"
+		}
+	}
+
+	switch {
+	case s.Ignoring(sender.XUID(), target.XUID()):
+		return "msg.ignoring"
+	case s.Ignoring(target.XUID(), sender.XUID()):
+		return "msg.blocked"
+	}
+
+	if s.limit != nil {
+		if v := s.limit.Check(chatfilter.Message{XUID: sender.XUID(), Text: message, Time: time.Now()}); v.Action != chatfilter.ActionAllow {
+			return v.Reason
+		}
+	}
+
+	// Private messages follow the same chat rules as public chat.
+	v := chatfilter.Global().Check(chatfilter.Message{XUID: sender.XUID(), Text: message, Time: time.Now()})
+	switch v.Action {
+	case chatfilter.ActionDrop, chatfilter.ActionWarn:
+		return v.Reason
+	case chatfilter.ActionMute:
+		if h, ok := sender.Handler().(handler); ok {
+			h.AutoMute(sender, v)
+		}
+
+		return v.Reason
+	}
+	message = v.Text
+
+	sender.Message(locale.TranslateFor(sender, "msg.sent", locale.Args{"name": target.Name(), "message": message}))
+	target.Message(locale.TranslateFor(target, "msg.received", locale.Args{"name": sender.Name(), "message": message}))
+
+	s.mu.Lock()
+	s.replies[sender.XUID()] = partner{xuid: target.XUID(), name: target.Name()}
+	s.replies[target.XUID()] = partner{xuid: sender.XUID(), name: sender.Name()}
+	s.mu.Unlock()
+
+	for _, spy := range s.Spies(tx) {
+		if spy.H() == sender.H() || spy.H() == target.H() {
+			continue
+		}
+		spy.Message(locale.TranslateFor(spy, "msg.spy", locale.Args{"from": sender.Name(), "to": target.Name(), "message": message}))
+	}
+	s.log.Info("private message", "from", sender.Name(), "to", target.Name())
+
+	return ""
+}
+
+// ReplyTarget returns the XUID and name of the player the player with the
+// given XUID last exchanged a private message with.
+func (s *Service) ReplyTarget(xuid string) (targetXUID, name string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.replies[xuid]
+
+	return r.xuid, r.name, ok
+}
+
+// ToggleSpy flips social spy for the player with the given XUID and returns
+// whether it is now on.
+func (s *Service) ToggleSpy(xuid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.spies[xuid]; ok {
+		delete(s.spies, xuid)
+
+		return false
+	}
+	s.spies[xuid] = struct{}{}
+
+	return true
+}
+
+// Spies returns every online player with social spy on who may still use it.
+func (s *Service) Spies(tx *world.Tx) []*player.Player {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.spies) == 0 {
+		return nil
+	}
+
+	var spies []*player.Player
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+		if _, ok := s.spies[p.XUID()]; !ok {
+			continue
+		}
+		if h, ok := p.Handler().(handler); !ok || !h.Ranks().HasPermission(p.XUID(), permission.SocialSpy) {
+			continue
+		}
+		spies = append(spies, p)
+	}
+
+	return spies
+}
+
+// Forget releases the state kept for the player, e.g. when they leave.
+// Replies to them stay, so others get a clean "offline" message.
+func (s *Service) Forget(xuid string) {
+	s.mu.Lock()
+	delete(s.replies, xuid)
+	delete(s.spies, xuid)
+	s.mu.Unlock()
+
+	if s.limit != nil {
+		s.limit.Forget(xuid)
+	}
+}
+
+// Find returns the online player with the given name, ignoring case.
+func Find(tx *world.Tx, name string) (*player.Player, bool) {
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+		if strings.EqualFold(p.Name(), name) {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+// FindXUID returns the online player with the given XUID.
+func FindXUID(tx *world.Tx, xuid string) (*player.Player, bool) {
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+		if p.XUID() == xuid {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
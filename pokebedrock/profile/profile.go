@@ -0,0 +1,102 @@
+// Package profile persists what the hub knows about players between
+// sessions, such as when they first joined, their playtime and their
+// preferences.
+package profile
+
+import (
+	"maps"
+	"slices"
+	"time"
+)
+
+// nameHistoryLimit is the maximum number of names kept in a profile's name
+// history.
+const nameHistoryLimit = 20
+
+// NameChange records a name a player joined with and when it was first
+// seen. Since is a Unix timestamp in milliseconds.
+type NameChange struct {
+	Name  string `json:"name"`
+	Since int64  `json:"since"`
+}
+
+// Profile holds the stored data of a player. Timestamps are Unix
+// timestamps in milliseconds and Playtime is in milliseconds.
+type Profile struct {
+	XUID        string            `json:"xuid"`
+	Name        string            `json:"name"`
+	NameHistory []NameChange      `json:"nameHistory,omitempty"`
+	FirstJoin   int64             `json:"firstJoin"`
+	LastJoin    int64             `json:"lastJoin"`
+	LastQuit    int64             `json:"lastQuit"`
+	Playtime    int64             `json:"playtime"`
+	LastServer  string            `json:"lastServer,omitempty"`
+	Preferences map[string]string `json:"preferences,omitempty"`
+}
+
+// Online reports whether the player is in the hub, going by their last
+// join and quit.
+func (p Profile) Online() bool {
+	return p.LastJoin > p.LastQuit
+}
+
+// TotalPlaytime returns the accumulated playtime, including the current
+// session if the player is online.
+func (p Profile) TotalPlaytime(now time.Time) time.Duration {
+	total := time.Duration(p.Playtime) * time.Millisecond
+	if p.Online() {
+		total += now.Sub(time.UnixMilli(p.LastJoin))
+	}
+
+	return total
+}
+
+// Preference returns the value of a preference.
+func (p Profile) Preference(key string) (string, bool) {
+	v, ok := p.Preferences[key]
+
+	return v, ok
+}
+
+// clone returns a deep copy of the profile.
+func (p Profile) clone() Profile {
+	p.NameHistory = slices.Clone(p.NameHistory)
+	p.Preferences = maps.Clone(p.Preferences)
+
+	return p
+}
+
+// join records the player joining with the given name.
+func (p *Profile) join(xuid, name string, now time.Time) {
+	ms := now.UnixMilli()
+
+	p.XUID = xuid
+	if p.FirstJoin == 0 {
+		p.FirstJoin = ms
+	}
+	if p.Online() {
+		// The previous session never ended, e.g. because the hub crashed;
+		// it isn't counted.
+		p.LastQuit = p.LastJoin
+	}
+	p.LastJoin = ms
+
+	if p.Name != name {
+		p.Name = name
+		p.NameHistory = append(p.NameHistory, NameChange{Name: name, Since: ms})
+		if len(p.NameHistory) > nameHistoryLimit {
+			p.NameHistory = slices.Delete(p.NameHistory, 0, len(p.NameHistory)-nameHistoryLimit)
+		}
+	}
+}
+
+// quit records the player leaving, adding the session to their playtime.
+func (p *Profile) quit(now time.Time) {
+	if !p.Online() {
+		return
+	}
+
+	ms := now.UnixMilli()
+	p.Playtime += max(0, ms-p.LastJoin)
+	p.LastQuit = ms
+}
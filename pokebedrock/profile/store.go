@@ -0,0 +1,457 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+const (
+	// defaultDirPerms is the default permission for created directories.
+	defaultDirPerms = 0o755
+
+	// flushInterval is the maximum delay between a change and the resulting
+	// disk write, so bursts of changes to a profile are written once.
+	flushInterval = 5 * time.Second
+
+	// holdTimeout is how long a profile loaded during login is kept in
+	// memory for the player to join.
+	holdTimeout = time.Minute
+)
+
+// Config holds the settings of the profile store.
+type Config struct {
+	// Dir is the directory profiles are stored in, one file per XUID. An
+	// empty Dir keeps profiles in memory only.
+	Dir string
+}
+
+// entry is a profile held in memory.
+type entry struct {
+	profile Profile
+	// loaded is set once the stored profile was read. Until then, changes
+	// are kept in pending and applied after loading.
+	loaded  bool
+	pending []func(p *Profile)
+	// done is closed once loaded is set.
+	done chan struct{}
+	// online keeps the entry in memory while the player is in the hub.
+	online bool
+	// heldUntil keeps the entry in memory while the player is logging in.
+	heldUntil time.Time
+	dirty     bool
+	// broken is set if the stored profile couldn't be read, so it isn't
+	// overwritten.
+	broken bool
+}
+
+// Store holds the profiles of online players in memory and persists them
+// to disk. All disk access happens on a single background goroutine, so
+// the store may be used from the world owner: changes to a profile that is
+// still loading are applied once it has loaded.
+type Store struct {
+	log *slog.Logger
+	dir string
+
+	mu      sync.Mutex
+	entries map[string]*entry
+	loads   []string
+	closed  bool
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// global holds the singleton profile store. It starts out in memory only
+// so the store can be used before NewStore is called.
+var global = newStore(slog.Default(), Config{})
+
+// Global returns the singleton profile store.
+func Global() *Store {
+	return global
+}
+
+// NewStore initialises the singleton profile store.
+func NewStore(log *slog.Logger, conf Config) *Store {
+	global = newStore(log, conf)
+
+	return global
+}
+
+// newStore creates a store and starts its background goroutine.
+func newStore(log *slog.Logger, conf Config) *Store {
+	s := &Store{
+		log:     log,
+		dir:     conf.Dir,
+		entries: make(map[string]*entry),
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	if s.dir == "" {
+		close(s.stopped)
+	} else {
+		go s.run()
+	}
+
+	return s
+}
+
+// Profile returns the profile of the player with the given XUID if it is
+// loaded.
+func (s *Store) Profile(xuid string) (Profile, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[xuid]
+	if !ok || !e.loaded {
+		return Profile{}, false
+	}
+
+	return e.profile.clone(), true
+}
+
+// Loaded reports whether the profile of the player with the given XUID has
+// been read from disk.
+func (s *Store) Loaded(xuid string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[xuid]
+
+	return ok && e.loaded
+}
+
+// Load returns the profile of the player with the given XUID, waiting for
+// it to be read from disk if needed. Use it before reading preferences that
+// must not fall back to their defaults while the profile is loading, such
+// as during login. An error is returned if ctx is done first.
+func (s *Store) Load(ctx context.Context, xuid string) (Profile, error) {
+	s.mu.Lock()
+	e := s.entryLocked(xuid)
+	done := e.done
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return Profile{}, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return e.profile.clone(), nil
+}
+
+// Hold keeps the profile of the player with the given XUID in memory until
+// they join or holdTimeout passes, loading it if needed. Use it when the
+// player starts logging in, so the profile isn't released and read again
+// before they join.
+func (s *Store) Hold(xuid string) {
+	until := time.Now().Add(holdTimeout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entryLocked(xuid).heldUntil = until
+}
+
+// Update changes the profile of the player with the given XUID, loading it
+// first if needed. f may be called on another goroutine and must not block.
+func (s *Store) Update(xuid string, f func(p *Profile)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateLocked(s.entryLocked(xuid), f)
+}
+
+// Joined records the player joining the hub and keeps their profile in
+// memory until they quit.
+func (s *Store) Joined(xuid, name string) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entryLocked(xuid)
+	e.online = true
+	e.heldUntil = time.Time{}
+	s.updateLocked(e, func(p *Profile) {
+		p.join(xuid, name, now)
+	})
+}
+
+// Quit records the player leaving the hub, adding the session to their
+// playtime. The profile is released from memory once it was written.
+func (s *Store) Quit(xuid string) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entryLocked(xuid)
+	e.online = false
+	e.heldUntil = time.Time{}
+	s.updateLocked(e, func(p *Profile) {
+		p.quit(now)
+	})
+
+	if s.dir == "" {
+		delete(s.entries, xuid)
+	}
+}
+
+// SetLastServer records the downstream server the player was last
+// transferred to.
+func (s *Store) SetLastServer(xuid, server string) {
+	s.Update(xuid, func(p *Profile) {
+		p.LastServer = server
+	})
+}
+
+// Preference returns the value of a preference of the player with the
+// given XUID, if their profile is loaded and the preference is set.
+func (s *Store) Preference(xuid, key string) (string, bool) {
+	p, ok := s.Profile(xuid)
+	if !ok {
+		return "", false
+	}
+
+	return p.Preference(key)
+}
+
+// SetPreference sets a preference of the player with the given XUID. An
+// empty value removes the preference.
+func (s *Store) SetPreference(xuid, key, value string) {
+	s.Update(xuid, func(p *Profile) {
+		if value == "" {
+			delete(p.Preferences, key)
+
+			return
+		}
+		if p.Preferences == nil {
+			p.Preferences = make(map[string]string)
+		}
+		p.Preferences[key] = value
+	})
+}
+
+// entryLocked returns the entry of xuid, creating it and scheduling a load
+// if it isn't in memory. Caller must hold s.mu.
+func (s *Store) entryLocked(xuid string) *entry {
+	e, ok := s.entries[xuid]
+	if ok {
+		return e
+	}
+
+	e = &entry{profile: Profile{XUID: xuid}, done: make(chan struct{})}
+	s.entries[xuid] = e
+
+	if s.dir == "" || s.closed || !validXUID(xuid) {
+		e.loaded = true
+		close(e.done)
+	} else {
+		s.loads = append(s.loads, xuid)
+		s.signal()
+	}
+
+	return e
+}
+
+// updateLocked applies f to the entry, or queues it if the entry is still
+// loading. Caller must hold s.mu.
+func (s *Store) updateLocked(e *entry, f func(p *Profile)) {
+	if !e.loaded {
+		e.pending = append(e.pending, f)
+
+		return
+	}
+
+	f(&e.profile)
+	e.dirty = true
+	s.signal()
+}
+
+// signal wakes the background goroutine without blocking.
+func (s *Store) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Close writes every pending change and stops the background goroutine.
+// Safe to call multiple times.
+func (s *Store) Close() {
+	s.stopOnce.Do(func() {
+		if s.dir != "" {
+			close(s.stop)
+		}
+	})
+	<-s.stopped
+}
+
+// run loads requested profiles as soon as they are requested and writes
+// changed profiles at most once per flushInterval.
+func (s *Store) run() {
+	defer close(s.stopped)
+
+	timer := time.NewTimer(flushInterval)
+	timer.Stop()
+	pending := false
+
+	for {
+		select {
+		case <-s.stop:
+			s.mu.Lock()
+			s.closed = true
+			s.mu.Unlock()
+
+			s.loadRequested()
+			s.writeDirty()
+
+			return
+		case <-s.wake:
+			s.loadRequested()
+			if !pending {
+				timer.Reset(flushInterval)
+				pending = true
+			}
+		case <-timer.C:
+			pending = false
+			s.writeDirty()
+		}
+	}
+}
+
+// loadRequested reads the profiles requested since the last call and
+// applies the changes made while they were loading.
+func (s *Store) loadRequested() {
+	s.mu.Lock()
+	loads := s.loads
+	s.loads = nil
+	s.mu.Unlock()
+
+	for _, xuid := range loads {
+		stored, err := s.read(xuid)
+		if err != nil {
+			s.log.Error("failed to load profile, changes won't be saved", "xuid", xuid, "error", err)
+		}
+
+		s.mu.Lock()
+		if e, ok := s.entries[xuid]; ok && !e.loaded {
+			if stored != nil {
+				e.profile = *stored
+			}
+			e.loaded = true
+			close(e.done)
+			e.broken = err != nil
+			for _, f := range e.pending {
+				f(&e.profile)
+			}
+			e.dirty = e.dirty || len(e.pending) > 0
+			e.pending = nil
+		}
+		s.mu.Unlock()
+	}
+}
+
+// writeDirty writes every changed profile and releases the profiles of
+// players that are no longer online or logging in.
+func (s *Store) writeDirty() {
+	now := time.Now()
+
+	s.mu.Lock()
+	snapshots := make([]Profile, 0)
+	for xuid, e := range s.entries {
+		if !e.loaded {
+			continue
+		}
+		if e.dirty && !e.broken {
+			snapshots = append(snapshots, e.profile.clone())
+			e.dirty = false
+		}
+		if !e.online && now.After(e.heldUntil) {
+			delete(s.entries, xuid)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(snapshots) == 0 {
+		return
+	}
+	if err := os.MkdirAll(s.dir, defaultDirPerms); err != nil {
+		s.log.Error("failed to create profile directory", "path", s.dir, "error", err)
+
+		return
+	}
+
+	for _, p := range snapshots {
+		if !validXUID(p.XUID) {
+			continue
+		}
+		if err := s.write(p); err != nil {
+			s.log.Error("failed to write profile", "xuid", p.XUID, "error", err)
+		}
+	}
+}
+
+// path returns the file the profile of xuid is stored in.
+func (s *Store) path(xuid string) string {
+	return filepath.Join(s.dir, xuid+".json")
+}
+
+// read returns the stored profile of xuid, or nil if there is none.
+func (s *Store) read(xuid string) (*Profile, error) {
+	data, err := os.ReadFile(s.path(xuid))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	p.XUID = xuid
+
+	return &p, nil
+}
+
+// write stores the profile, replacing the file atomically.
+func (s *Store) write(p Profile) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFileAtomic(s.path(p.XUID), data)
+}
+
+// validXUID reports whether xuid can be used as a file name. XUIDs are
+// numeric.
+func validXUID(xuid string) bool {
+	if xuid == "" {
+		return false
+	}
+	for _, c := range xuid {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
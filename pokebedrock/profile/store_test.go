@@ -0,0 +1,159 @@
+package profile
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// waitLoaded waits until the profile of xuid has been loaded.
+func waitLoaded(t *testing.T, s *Store, xuid string) Profile {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if p, ok := s.Profile(xuid); ok {
+			return p
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("profile of %s was not loaded", xuid)
+
+	return Profile{}
+}
+
+func TestStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+
+	s := newStore(discard, Config{Dir: dir})
+	s.Joined("1", "Steve")
+	s.SetPreference("1", "language", "de_DE")
+	s.SetLastServer("1", "Survival")
+
+	p := waitLoaded(t, s, "1")
+	if !p.Online() || p.FirstJoin == 0 || p.Name != "Steve" {
+		t.Fatalf("unexpected profile after join: %+v", p)
+	}
+
+	s.Quit("1")
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "1.json")); err != nil {
+		t.Fatalf("profile was not written: %v", err)
+	}
+
+	s = newStore(discard, Config{Dir: dir})
+	defer s.Close()
+
+	s.Joined("1", "Alex")
+	p = waitLoaded(t, s, "1")
+	if v, _ := p.Preference("language"); v != "de_DE" {
+		t.Fatalf("preference = %q, want de_DE", v)
+	}
+	if p.LastServer != "Survival" || p.LastQuit == 0 {
+		t.Fatalf("unexpected profile after rejoin: %+v", p)
+	}
+	if len(p.NameHistory) != 2 || p.NameHistory[0].Name != "Steve" || p.NameHistory[1].Name != "Alex" {
+		t.Fatalf("name history = %+v", p.NameHistory)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	s := newStore(discard, Config{Dir: dir})
+	s.SetPreference("1", "language", "de_DE")
+	s.Close()
+
+	s = newStore(discard, Config{Dir: dir})
+	defer s.Close()
+
+	p, err := s.Load(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if v, _ := p.Preference("language"); v != "de_DE" {
+		t.Fatalf("preference = %q, want de_DE", v)
+	}
+	if v, ok := s.Preference("1", "language"); !ok || v != "de_DE" {
+		t.Fatalf("Preference() after Load() = %q, %v", v, ok)
+	}
+}
+
+func TestPlaytime(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+
+	var p Profile
+	p.join("1", "Steve", now)
+	if got := p.TotalPlaytime(now.Add(time.Minute)); got != time.Minute {
+		t.Fatalf("TotalPlaytime() while online = %v, want 1m", got)
+	}
+
+	p.quit(now.Add(time.Minute))
+	p.join("1", "Steve", now.Add(time.Hour))
+	p.quit(now.Add(time.Hour + 2*time.Minute))
+	if got := p.TotalPlaytime(now.Add(2 * time.Hour)); got != 3*time.Minute {
+		t.Fatalf("TotalPlaytime() = %v, want 3m", got)
+	}
+
+	// A session that never ended isn't counted.
+	p.join("1", "Steve", now.Add(3*time.Hour))
+	p.join("1", "Steve", now.Add(4*time.Hour))
+	p.quit(now.Add(4*time.Hour + time.Minute))
+	if got := p.TotalPlaytime(now.Add(5 * time.Hour)); got != 4*time.Minute {
+		t.Fatalf("TotalPlaytime() after crash = %v, want 4m", got)
+	}
+	if len(p.NameHistory) != 1 {
+		t.Fatalf("name history = %+v, want a single entry", p.NameHistory)
+	}
+}
+
+func TestInvalidXUIDNotWritten(t *testing.T) {
+	dir := t.TempDir()
+
+	s := newStore(discard, Config{Dir: dir})
+	s.Joined("../escape", "Steve")
+	s.Quit("../escape")
+	s.Close()
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Fatalf("expected no files, got %d", len(entries))
+	}
+}
+
+func TestHold(t *testing.T) {
+	s := newStore(discard, Config{Dir: t.TempDir()})
+	defer s.Close()
+
+	held := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		_, ok := s.entries["1"]
+
+		return ok
+	}
+
+	s.Hold("1")
+	waitLoaded(t, s, "1")
+	s.writeDirty()
+	if !held() {
+		t.Fatal("held profile was released before joining")
+	}
+
+	s.mu.Lock()
+	s.entries["1"].heldUntil = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+
+	s.writeDirty()
+	if held() {
+		t.Fatal("profile was kept after the hold expired")
+	}
+}
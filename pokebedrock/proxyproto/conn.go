@@ -0,0 +1,233 @@
+package proxyproto
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultIdleTimeout is used when no idle timeout is configured.
+const defaultIdleTimeout = time.Minute
+
+// Config holds the settings of a PacketConn.
+type Config struct {
+	// TrustedProxies holds the CIDR ranges of the proxies allowed to send
+	// PROXY protocol headers. Headers from other addresses are dropped, so
+	// clients can't spoof their address.
+	TrustedProxies []string
+	// IdleTimeout is how long the client address of a proxy flow is
+	// remembered without traffic, for proxies that only send a header with
+	// the first datagram of a flow.
+	IdleTimeout time.Duration
+}
+
+// flow maps the address a proxy relays a client from to the client's real
+// address.
+type flow struct {
+	proxy, client netip.AddrPort
+	seen          time.Time
+}
+
+// PacketConn wraps a UDP connection, stripping PROXY protocol headers sent
+// by trusted proxies. Datagrams read from a proxy flow report the client's
+// address, and datagrams written to that address are sent to the proxy.
+type PacketConn struct {
+	net.PacketConn
+
+	log     *slog.Logger
+	trusted []netip.Prefix
+	idle    time.Duration
+
+	mu      sync.Mutex
+	clients map[netip.AddrPort]*flow
+	proxies map[netip.AddrPort]*flow
+	lastGC  time.Time
+}
+
+// NewPacketConn wraps conn. Invalid trusted proxy ranges are logged and
+// skipped.
+func NewPacketConn(log *slog.Logger, conn net.PacketConn, conf Config) *PacketConn {
+	trusted := make([]netip.Prefix, 0, len(conf.TrustedProxies))
+	for _, c := range conf.TrustedProxies {
+		p, err := netip.ParsePrefix(strings.TrimSpace(c))
+		if err != nil {
+			log.Warn("ignoring invalid trusted proxy cidr", "cidr", c, "error", err)
+
+			continue
+		}
+		trusted = append(trusted, p.Masked())
+	}
+
+	idle := conf.IdleTimeout
+	if idle <= 0 {
+		idle = defaultIdleTimeout
+	}
+
+	return &PacketConn{
+		PacketConn: conn,
+		log:        log,
+		trusted:    trusted,
+		idle:       idle,
+		clients:    make(map[netip.AddrPort]*flow),
+		proxies:    make(map[netip.AddrPort]*flow),
+		lastGC:     time.Now(),
+	}
+}
+
+// ReadFrom reads the next datagram. Headers from trusted proxies are stripped
+// and the client's address is returned in place of the proxy's. Datagrams
+// with a malformed header, or with a header from an untrusted address, are
+// dropped.
+func (c *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil {
+			return n, addr, err
+		}
+
+		src, ok := addrPortOf(addr)
+		if !ok {
+			return n, addr, nil
+		}
+
+		h, hn, err := Parse(b[:n])
+		switch {
+		case errors.Is(err, ErrNoHeader):
+			// Proxies may only send a header with the first datagram of a
+			// flow, so later datagrams are matched to the flow.
+			if client, ok := c.clientOf(src); ok {
+				return n, net.UDPAddrFromAddrPort(client), nil
+			}
+
+			return n, addr, nil
+		case err != nil:
+			c.log.Debug("dropping datagram with malformed PROXY header", "addr", src, "error", err)
+
+			continue
+		case !c.isTrusted(src.Addr()):
+			c.log.Debug("dropping PROXY header from untrusted address", "addr", src)
+
+			continue
+		}
+
+		n = copy(b, b[hn:n])
+		if h.Local {
+			if n == 0 {
+				continue
+			}
+
+			return n, addr, nil
+		}
+
+		c.track(src, h.Source)
+		if n == 0 {
+			continue
+		}
+
+		return n, net.UDPAddrFromAddrPort(h.Source), nil
+	}
+}
+
+// WriteTo writes a datagram, sending it through the proxy when addr is the
+// address of a proxied client.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if dst, ok := addrPortOf(addr); ok {
+		if proxy, ok := c.proxyOf(dst); ok {
+			return c.PacketConn.WriteTo(b, net.UDPAddrFromAddrPort(proxy))
+		}
+	}
+
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy.
+func (c *PacketConn) isTrusted(addr netip.Addr) bool {
+	return slices.ContainsFunc(c.trusted, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// track records that proxy relays datagrams of client.
+func (c *PacketConn) track(proxy, client netip.AddrPort) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if f, ok := c.clients[proxy]; ok {
+		if f.client == client {
+			f.seen = now
+
+			return
+		}
+		// The proxy reused the port for another client.
+		delete(c.proxies, f.client)
+	}
+
+	f := &flow{proxy: proxy, client: client, seen: now}
+	c.clients[proxy] = f
+	c.proxies[client] = f
+
+	c.gcLocked(now)
+}
+
+// clientOf returns the client relayed from the proxy address, if any.
+func (c *PacketConn) clientOf(proxy netip.AddrPort) (netip.AddrPort, bool) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.clients[proxy]
+	if !ok || now.Sub(f.seen) > c.idle {
+		return netip.AddrPort{}, false
+	}
+	f.seen = now
+
+	return f.client, true
+}
+
+// proxyOf returns the proxy address relaying the client, if any.
+func (c *PacketConn) proxyOf(client netip.AddrPort) (netip.AddrPort, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.proxies[client]
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+
+	return f.proxy, true
+}
+
+// gcLocked drops flows idle for longer than the idle timeout, at most once
+// per timeout.
+func (c *PacketConn) gcLocked(now time.Time) {
+	if now.Sub(c.lastGC) < c.idle {
+		return
+	}
+	c.lastGC = now
+
+	for proxy, f := range c.clients {
+		if now.Sub(f.seen) > c.idle {
+			delete(c.clients, proxy)
+			delete(c.proxies, f.client)
+		}
+	}
+}
+
+// addrPortOf returns the IP and port of a UDP address.
+func addrPortOf(addr net.Addr) (netip.AddrPort, bool) {
+	if u, ok := addr.(*net.UDPAddr); ok {
+		ap := u.AddrPort()
+
+		return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()), ap.IsValid()
+	}
+
+	return netip.AddrPort{}, false
+}
@@ -0,0 +1,98 @@
+// Package proxyproto implements the receiving side of the PROXY protocol v2
+// for RakNet. A UDP proxy in front of the hub prepends a header holding the
+// real client address to its datagrams; the hub strips it before RakNet
+// handles the packet, so every layer above it sees the client's address
+// rather than the proxy's.
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// signature starts every PROXY protocol v2 header.
+var signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrNoHeader is returned by Parse for data that doesn't start with a PROXY
+// protocol v2 header.
+var ErrNoHeader = errors.New("no PROXY protocol v2 header")
+
+// headerLen is the length of the fixed part of a header: the signature, the
+// version and command, the address family and protocol, and the length of
+// the address block.
+const headerLen = 16
+
+// Commands, in the low nibble of the version and command byte.
+const (
+	commandLocal = 0x0
+	commandProxy = 0x1
+)
+
+// Address families, in the high nibble of the family and protocol byte.
+const (
+	familyInet  = 0x1
+	familyInet6 = 0x2
+)
+
+// Header is a decoded PROXY protocol v2 header.
+type Header struct {
+	// Local is set for connections made by the proxy itself, such as health
+	// checks. Source and Destination are unset for them.
+	Local bool
+	// Source is the address of the client and Destination the address the
+	// client connected to on the proxy.
+	Source      netip.AddrPort
+	Destination netip.AddrPort
+}
+
+// Parse decodes the header at the start of b and returns it together with
+// its length in bytes. Addresses of families other than IPv4 and IPv6 are
+// treated like a LOCAL command. TLVs following the addresses are skipped.
+func Parse(b []byte) (Header, int, error) {
+	if len(b) < headerLen || !bytes.Equal(b[:len(signature)], signature) {
+		return Header{}, 0, ErrNoHeader
+	}
+
+	verCmd, famProto := b[12], b[13]
+	if version := verCmd >> 4; version != 2 {
+		return Header{}, 0, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+
+	n := headerLen + int(binary.BigEndian.Uint16(b[14:16]))
+	if len(b) < n {
+		return Header{}, 0, fmt.Errorf("truncated PROXY protocol header: %d of %d bytes", len(b), n)
+	}
+	addrs := b[headerLen:n]
+
+	switch verCmd & 0x0f {
+	case commandLocal:
+		return Header{Local: true}, n, nil
+	case commandProxy:
+	default:
+		return Header{}, 0, fmt.Errorf("unknown PROXY protocol command %#x", verCmd&0x0f)
+	}
+
+	var h Header
+	switch famProto >> 4 {
+	case familyInet:
+		if len(addrs) < 12 {
+			return Header{}, 0, fmt.Errorf("short IPv4 address block of %d bytes", len(addrs))
+		}
+		h.Source = netip.AddrPortFrom(netip.AddrFrom4([4]byte(addrs[0:4])), binary.BigEndian.Uint16(addrs[8:10]))
+		h.Destination = netip.AddrPortFrom(netip.AddrFrom4([4]byte(addrs[4:8])), binary.BigEndian.Uint16(addrs[10:12]))
+	case familyInet6:
+		if len(addrs) < 36 {
+			return Header{}, 0, fmt.Errorf("short IPv6 address block of %d bytes", len(addrs))
+		}
+		h.Source = netip.AddrPortFrom(netip.AddrFrom16([16]byte(addrs[0:16])).Unmap(), binary.BigEndian.Uint16(addrs[32:34]))
+		h.Destination = netip.AddrPortFrom(netip.AddrFrom16([16]byte(addrs[16:32])).Unmap(), binary.BigEndian.Uint16(addrs[34:36]))
+	default:
+		// Unspecified and Unix socket families carry no address we could use.
+		return Header{Local: true}, n, nil
+	}
+
+	return h, n, nil
+}
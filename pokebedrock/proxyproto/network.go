@@ -0,0 +1,103 @@
+package proxyproto
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"github.com/df-mc/dragonfly/server"
+	"github.com/df-mc/dragonfly/server/session"
+	"github.com/sandertv/go-raknet"
+	"github.com/sandertv/gophertunnel/minecraft"
+)
+
+// Network is a RakNet network whose listeners accept PROXY protocol headers
+// from trusted proxies.
+type Network struct {
+	log  *slog.Logger
+	conf Config
+}
+
+// NewNetwork creates a network whose listeners wrap their UDP connection
+// with a PacketConn using conf.
+func NewNetwork(log *slog.Logger, conf Config) Network {
+	return Network{log: log, conf: conf}
+}
+
+// DialContext ...
+func (n Network) DialContext(ctx context.Context, address string) (net.Conn, error) {
+	return raknet.Dialer{ErrorLog: n.log.With("net origin", "raknet")}.DialContext(ctx, address)
+}
+
+// PingContext ...
+func (n Network) PingContext(ctx context.Context, address string) ([]byte, error) {
+	return raknet.Dialer{ErrorLog: n.log.With("net origin", "raknet")}.PingContext(ctx, address)
+}
+
+// Listen ...
+func (n Network) Listen(address string) (minecraft.NetworkListener, error) {
+	return raknet.ListenConfig{
+		ErrorLog:               n.log.With("net origin", "raknet"),
+		UpstreamPacketListener: n,
+	}.Listen(address)
+}
+
+// ListenPacket listens on a UDP address and wraps the connection with a
+// PacketConn. It implements raknet.UpstreamPacketListener.
+func (n Network) ListenPacket(network, address string) (net.PacketConn, error) {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPacketConn(n.log, conn, n.conf), nil
+}
+
+// ListenerFunc returns a function creating the server's listener on address
+// through n, for use in server.Config.Listeners. It is configured like the
+// listener dragonfly creates by default.
+func ListenerFunc(address string, n Network) func(server.Config) (server.Listener, error) {
+	return func(conf server.Config) (server.Listener, error) {
+		cfg := minecraft.ListenConfig{
+			MaximumPlayers:         conf.MaxPlayers,
+			StatusProvider:         conf.StatusProvider,
+			AuthenticationDisabled: conf.AuthDisabled,
+			ResourcePacks:          conf.Resources,
+			TexturePacksRequired:   conf.ResourcesRequired,
+			Compression:            conf.Compression,
+		}
+		if conf.Log.Enabled(context.Background(), slog.LevelDebug) {
+			cfg.ErrorLog = conf.Log.With("net origin", "gophertunnel")
+		}
+
+		l, err := cfg.ListenNetwork(n, address)
+		if err != nil {
+			return nil, fmt.Errorf("create minecraft listener: %w", err)
+		}
+		conf.Log.Info("Listener running.", "addr", l.Addr(), "proxy_protocol", true)
+
+		return listener{l}, nil
+	}
+}
+
+// listener wraps a minecraft.Listener so the server can accept connections
+// from it.
+type listener struct {
+	*minecraft.Listener
+}
+
+// Accept ...
+func (l listener) Accept() (session.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.(session.Conn), nil
+}
+
+// Disconnect ...
+func (l listener) Disconnect(conn session.Conn, reason string) error {
+	return l.Listener.Disconnect(conn.(*minecraft.Conn), reason)
+}
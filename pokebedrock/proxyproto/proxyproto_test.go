@@ -0,0 +1,159 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// header encodes a PROXY protocol v2 header for src and dst.
+func header(src, dst netip.AddrPort) []byte {
+	b := append([]byte(nil), signature...)
+
+	if src.Addr().Is4() {
+		b = append(b, 0x20|commandProxy, familyInet<<4|0x2, 0, 12)
+		b = append(b, src.Addr().AsSlice()...)
+		b = append(b, dst.Addr().AsSlice()...)
+	} else {
+		b = append(b, 0x20|commandProxy, familyInet6<<4|0x2, 0, 36)
+		s, d := src.Addr().As16(), dst.Addr().As16()
+		b = append(b, s[:]...)
+		b = append(b, d[:]...)
+	}
+
+	b = binary.BigEndian.AppendUint16(b, src.Port())
+
+	return binary.BigEndian.AppendUint16(b, dst.Port())
+}
+
+func TestParse(t *testing.T) {
+	src := netip.MustParseAddrPort("203.0.113.7:54321")
+	dst := netip.MustParseAddrPort("192.0.2.1:19132")
+
+	h, n, err := Parse(append(header(src, dst), "payload"...))
+	if err != nil || n != headerLen+12 || h.Source != src || h.Destination != dst {
+		t.Fatalf("Parse() = %+v, %d, %v", h, n, err)
+	}
+
+	src6 := netip.MustParseAddrPort("[2001:db8::1]:54321")
+	dst6 := netip.MustParseAddrPort("[2001:db8::2]:19132")
+	if h, n, err := Parse(header(src6, dst6)); err != nil || n != headerLen+36 || h.Source != src6 {
+		t.Fatalf("IPv6: Parse() = %+v, %d, %v", h, n, err)
+	}
+
+	local := append(append([]byte(nil), signature...), 0x20|commandLocal, 0, 0, 0)
+	if h, n, err := Parse(local); err != nil || !h.Local || n != headerLen {
+		t.Fatalf("LOCAL: Parse() = %+v, %d, %v", h, n, err)
+	}
+
+	if _, _, err := Parse([]byte{0x01, 0x00, 0x00}); !errors.Is(err, ErrNoHeader) {
+		t.Fatalf("raknet packet: err = %v, want ErrNoHeader", err)
+	}
+	if _, _, err := Parse(header(src, dst)[:20]); err == nil || errors.Is(err, ErrNoHeader) {
+		t.Fatalf("truncated: err = %v, want parse error", err)
+	}
+
+	v1 := header(src, dst)
+	v1[12] = 0x10 | commandProxy
+	if _, _, err := Parse(v1); err == nil {
+		t.Fatal("version 1: expected error")
+	}
+}
+
+// listen returns a wrapped UDP connection on loopback trusting trusted.
+func listen(t *testing.T, trusted ...string) *PacketConn {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return NewPacketConn(slog.New(slog.NewTextHandler(io.Discard, nil)), conn, Config{TrustedProxies: trusted})
+}
+
+// dial returns a UDP connection to c.
+func dial(t *testing.T, c *PacketConn) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.DialUDP("udp", nil, c.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return conn
+}
+
+// read reads a datagram from c with a timeout.
+func read(t *testing.T, c *PacketConn) ([]byte, net.Addr) {
+	t.Helper()
+
+	_ = c.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 1500)
+	n, addr, err := c.ReadFrom(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b[:n], addr
+}
+
+func TestPacketConnTrustedProxy(t *testing.T) {
+	c := listen(t, "127.0.0.0/8")
+	proxy := dial(t, c)
+
+	client := netip.MustParseAddrPort("203.0.113.7:54321")
+	if _, err := proxy.Write(append(header(client, netip.MustParseAddrPort("192.0.2.1:19132")), "hello"...)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, addr := read(t, c)
+	if string(b) != "hello" || addr.String() != client.String() {
+		t.Fatalf("ReadFrom() = %q from %v, want %q from %v", b, addr, "hello", client)
+	}
+
+	// Later datagrams of the flow may come without a header.
+	if _, err := proxy.Write([]byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if b, addr := read(t, c); string(b) != "again" || addr.String() != client.String() {
+		t.Fatalf("headerless ReadFrom() = %q from %v", b, addr)
+	}
+
+	// Replies to the client are sent through the proxy.
+	if _, err := c.WriteTo([]byte("reply"), addr); err != nil {
+		t.Fatal(err)
+	}
+	_ = proxy.SetReadDeadline(time.Now().Add(time.Second))
+	reply := make([]byte, 16)
+	n, err := proxy.Read(reply)
+	if err != nil || string(reply[:n]) != "reply" {
+		t.Fatalf("proxy read %q, %v", reply[:n], err)
+	}
+}
+
+func TestPacketConnUntrusted(t *testing.T) {
+	c := listen(t, "198.51.100.0/24")
+	conn := dial(t, c)
+
+	spoofed := netip.MustParseAddrPort("203.0.113.7:54321")
+	if _, err := conn.Write(append(header(spoofed, netip.MustParseAddrPort("192.0.2.1:19132")), "spoof"...)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("direct")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The spoofed datagram is dropped and the direct one keeps its address.
+	b, addr := read(t, c)
+	if string(b) != "direct" || addr.String() != conn.LocalAddr().String() {
+		t.Fatalf("ReadFrom() = %q from %v, want %q from %v", b, addr, "direct", conn.LocalAddr())
+	}
+}
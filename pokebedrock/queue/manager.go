@@ -12,6 +12,7 @@ import (
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/authentication"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/srv"
 )
@@ -60,7 +61,7 @@ func NewManager() *Manager {
 // player has waited.
 func (m *Manager) AddPlayer(p *player.Player, r rank.Rank, server *srv.Server) {
 	if server == nil {
-		p.Message(locale.Translate("queue.nonexistent.server"))
+		p.Message(locale.TranslateFor(p, "queue.nonexistent.server"))
 
 		return
 	}
@@ -82,15 +83,18 @@ func (m *Manager) AddPlayer(p *player.Player, r rank.Rank, server *srv.Server) {
 	status := server.Status()
 	switch {
 	case status.Online && status.PlayerCount < status.MaxPlayerCount:
-		p.Message(locale.Translate("queue.added.success", server.Name()))
+		p.Message(locale.TranslateFor(p, "queue.added.success", server.Name()))
 	case !status.Online:
-		p.Message(locale.Translate("queue.added.offline", server.Name()))
+		p.Message(locale.TranslateFor(p, "queue.added.offline", server.Name()))
 	default:
-		p.Message(locale.Translate("queue.added.full",
-			server.Name(), status.PlayerCount, status.MaxPlayerCount))
+		p.Message(locale.TranslateFor(p, "queue.added.full", locale.Args{
+			"server": server.Name(),
+			"online": status.PlayerCount,
+			"max":    status.MaxPlayerCount,
+		}))
 	}
 
-	p.Message(locale.Translate("queue.priority.note"))
+	p.Message(locale.TranslateFor(p, "queue.priority.note"))
 }
 
 // RemovePlayer removes a player from the queue if present and
@@ -105,13 +109,35 @@ func (m *Manager) RemovePlayer(p *player.Player) {
 	m.mu.Unlock()
 
 	if serverName != "" {
-		p.Messagef("%s", locale.Translate("queue.removed", serverName))
+		p.Messagef("%s", locale.TranslateFor(p, "queue.removed", serverName))
 	}
 
 	p.RemoveBossBar()
 	m.queueAllBossBars()
 }
 
+// UpdateRank changes the rank of the queued player with the given handle
+// and moves their entry to match it, keeping the time they joined the
+// queue. It reports whether the player is queued.
+func (m *Manager) UpdateRank(h *world.EntityHandle, r rank.Rank) bool {
+	m.mu.Lock()
+	old := m.removeByHandleLocked(h)
+	if old != nil {
+		// Entries are shared with snapshots read outside the lock, so the
+		// entry is replaced rather than modified.
+		heap.Push(&m.pq, &Entry{joinTime: old.joinTime, handle: old.handle, rank: r, srv: old.srv})
+	}
+	m.mu.Unlock()
+
+	if old == nil {
+		return false
+	}
+
+	m.queueAllBossBars()
+
+	return true
+}
+
 // removeByHandleLocked removes the entry with the given handle, returning it
 // if found. Caller must hold m.mu.
 func (m *Manager) removeByHandleLocked(h *world.EntityHandle) *Entry {
@@ -227,7 +253,7 @@ func (m *Manager) Update(tx *world.Tx) {
 		if st.PlayerCount >= st.MaxPlayerCount {
 			continue
 		}
-		if st.PlayerCount >= st.MaxPlayerCount-5 && entry.rank < rank.Admin {
+		if st.PlayerCount >= st.MaxPlayerCount-5 && !entry.rank.AtLeast(rank.Admin) {
 			continue
 		}
 
@@ -253,20 +279,21 @@ func (m *Manager) Update(tx *world.Tx) {
 			continue
 		}
 		if p, ok := ent.(*player.Player); ok {
-			p.Message(locale.Translate(invalidMessages[i]))
+			p.Message(locale.TranslateFor(p, invalidMessages[i]))
 		}
 	}
 
 	if toTransfer != nil {
-		transferPlayer.Message(locale.Translate("connection.connecting", transferServer.Name()))
+		transferPlayer.Message(locale.TranslateFor(transferPlayer, "connection.connecting", transferServer.Name()))
 
 		if err := transferPlayer.Transfer(transferServer.Address()); err != nil {
-			transferPlayer.Message(locale.Translate("connection.failed", err))
+			transferPlayer.Message(locale.TranslateFor(transferPlayer, "connection.failed", err))
 			m.mu.Lock()
 			heap.Push(&m.pq, toTransfer)
 			m.mu.Unlock()
 		} else {
 			authentication.GlobalFactory().Set(transferPlayer.Name(), transferPlayer.XUID(), authentication.DefaultAuthDuration)
+			profile.Global().SetLastServer(transferPlayer.XUID(), transferServer.Name())
 		}
 	}
 
@@ -331,19 +358,20 @@ func (m *Manager) processBossBarUpdates(tx *world.Tx, maxCount int) {
 			continue
 		}
 
-		var waitMsg string
+		var waitKey string
 		switch {
 		case position == 1:
-			waitMsg = "You're next in line!"
+			waitKey = "queue.wait.next"
 		case position <= highPriorityQueueThreshold:
-			waitMsg = "Almost your turn"
+			waitKey = "queue.wait.almost"
 		case position <= mediumPriorityQueueThreshold:
-			waitMsg = "Short wait"
+			waitKey = "queue.wait.short"
 		default:
-			waitMsg = "Longer wait"
+			waitKey = "queue.wait.long"
 		}
+		waitMsg := locale.TranslateFor(p, waitKey)
 
-		p.SendBossBar(bossbar.New(locale.Translate("queue.position", position, waitMsg)))
+		p.SendBossBar(bossbar.New(locale.TranslateFor(p, "queue.position", position, waitMsg)))
 	}
 }
 
@@ -367,8 +395,8 @@ func positionFor(queue []*Entry, h *world.EntityHandle) int {
 		if entry == nil || entry == self {
 			continue
 		}
-		if entry.rank > self.rank ||
-			(entry.rank == self.rank && entry.joinTime.Before(self.joinTime)) {
+		if entry.rank.QueuePriority() > self.rank.QueuePriority() ||
+			(entry.rank.QueuePriority() == self.rank.QueuePriority() && entry.joinTime.Before(self.joinTime)) {
 			position++
 		}
 	}
@@ -382,6 +410,19 @@ func (m *Manager) GetQueuePosition(p *player.Player) int {
 	return positionFor(m.snapshot(), p.H())
 }
 
+// Lookup returns the server the player with the given handle is queued
+// for and their 1-indexed position, or a nil server if they aren't queued.
+func (m *Manager) Lookup(h *world.EntityHandle) (*srv.Server, int) {
+	queueSnap := m.snapshot()
+	for _, entry := range queueSnap {
+		if entry != nil && entry.handle == h {
+			return entry.srv, positionFor(queueSnap, h)
+		}
+	}
+
+	return nil, -1
+}
+
 // IsPlayerInQueue returns true if the given player has an entry in the queue.
 func (m *Manager) IsPlayerInQueue(p *player.Player) bool {
 	m.mu.Lock()
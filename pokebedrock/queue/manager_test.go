@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+func TestUpdateRank(t *testing.T) {
+	m := NewManager()
+	now := time.Now()
+
+	early, late, supporter := &world.EntityHandle{}, &world.EntityHandle{}, &world.EntityHandle{}
+	for _, e := range []*Entry{
+		{joinTime: now, handle: early, rank: rank.Trainer},
+		{joinTime: now.Add(time.Second), handle: late, rank: rank.Trainer},
+		{joinTime: now.Add(2 * time.Second), handle: supporter, rank: rank.Supporter},
+	} {
+		heap.Push(&m.pq, e)
+	}
+
+	if m.UpdateRank(&world.EntityHandle{}, rank.Admin) {
+		t.Fatal("UpdateRank() reported an unqueued player as queued")
+	}
+	if !m.UpdateRank(late, rank.Admin) {
+		t.Fatal("UpdateRank() reported a queued player as not queued")
+	}
+
+	want := []*world.EntityHandle{late, supporter, early}
+	for i, h := range want {
+		e := heap.Pop(&m.pq).(*Entry)
+		if e.handle != h {
+			t.Fatalf("entry %d = %s, want the %d. expected player", i, e, i+1)
+		}
+		if h == late && (e.rank != rank.Admin || !e.joinTime.Equal(now.Add(time.Second))) {
+			t.Fatalf("updated entry = %s, want Admin keeping its join time", e)
+		}
+	}
+}
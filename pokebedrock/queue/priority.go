@@ -7,8 +7,8 @@ import (
 )
 
 // PriorityQueue implements a heap.Interface for queue entries.
-// It prioritizes entries by rank first, and then by join time for equal ranks.
-// Higher ranks get priority over lower ranks (Admin > Trainer).
+// It prioritizes entries by rank queue priority first, and then by join time for equal ranks.
+// Ranks with a higher queue priority come first (Admin > Trainer by default).
 type PriorityQueue []*Entry
 
 // Len returns the length of the priority queue.
@@ -18,13 +18,14 @@ func (pq PriorityQueue) Len() int {
 
 // Less determines the ordering of entries in the priority queue.
 // Returns true if the entry at index i should come before the entry at index j.
-// Higher ranks come first; for equal ranks, earlier join times come first.
+// Higher queue priorities come first; for equal priorities, earlier join times come first.
 func (pq PriorityQueue) Less(i, j int) bool {
-	if pq[i].rank == pq[j].rank {
+	pi, pj := pq[i].rank.QueuePriority(), pq[j].rank.QueuePriority()
+	if pi == pj {
 		return pq[i].joinTime.Before(pq[j].joinTime)
 	}
 
-	return pq[i].rank > pq[j].rank // Higher rank (numerically) has priority
+	return pi > pj
 }
 
 // Swap swaps the entries at indices i and j and updates their indices.
@@ -0,0 +1,237 @@
+package rank
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+const (
+	// defaultDirPerms is the default permission for created directories.
+	defaultDirPerms = 0o755
+
+	// flushInterval is the maximum delay between a change and the resulting
+	// disk write, so the roles fetched for a burst of joins are written once.
+	flushInterval = 5 * time.Second
+)
+
+// CacheEntry holds the last roles fetched for an account. Fetched is a Unix
+// timestamp in milliseconds.
+type CacheEntry struct {
+	Roles   []string `json:"roles"`
+	Fetched int64    `json:"fetched"`
+}
+
+// Cache persists the last known roles of every account so a rank API outage
+// doesn't unlink players. Entries are served for up to staleWindow after
+// they were fetched.
+//
+// Writes are debounced: changes mark the cache dirty and signal a flusher
+// goroutine, which writes the snapshot at most once per flushInterval.
+type Cache struct {
+	log         *slog.Logger
+	path        string
+	staleWindow time.Duration
+
+	mu    sync.RWMutex
+	data  map[string]CacheEntry
+	dirty bool
+
+	flush    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// NewCache creates a cache backed by the file at path, loading the entries
+// that are still within staleWindow. An empty path keeps the cache in
+// memory only. A zero staleWindow serves entries forever.
+func NewCache(log *slog.Logger, path string, staleWindow time.Duration) *Cache {
+	c := &Cache{
+		log:         log,
+		path:        path,
+		staleWindow: staleWindow,
+		data:        make(map[string]CacheEntry),
+		flush:       make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	if path == "" {
+		close(c.stopped)
+
+		return c
+	}
+
+	if err := c.load(time.Now()); err != nil {
+		log.Error("failed to load rank cache", "path", path, "error", err)
+	}
+
+	// Entries dropped while loading are written out; read before the
+	// flusher starts, as Set and Delete change dirty under c.mu.
+	go c.flusher(c.dirty)
+
+	return c
+}
+
+// load reads the cache file, dropping entries outside the stale window.
+func (c *Cache) load(now time.Time) error {
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var entries map[string]CacheEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	for xuid, e := range entries {
+		if c.expired(e, now) {
+			c.dirty = true
+
+			continue
+		}
+		c.data[xuid] = e
+	}
+
+	return nil
+}
+
+// expired reports whether e is too old to be served.
+func (c *Cache) expired(e CacheEntry, now time.Time) bool {
+	return c.staleWindow > 0 && now.Sub(time.UnixMilli(e.Fetched)) > c.staleWindow
+}
+
+// Get returns the cached roles of xuid and when they were fetched, if they
+// are still within the stale window.
+func (c *Cache) Get(xuid string) ([]string, time.Time, bool) {
+	c.mu.RLock()
+	e, ok := c.data[xuid]
+	c.mu.RUnlock()
+
+	if !ok || c.expired(e, time.Now()) {
+		return nil, time.Time{}, false
+	}
+
+	return slices.Clone(e.Roles), time.UnixMilli(e.Fetched), true
+}
+
+// Set stores freshly fetched roles of xuid.
+func (c *Cache) Set(xuid string, roles []string) {
+	c.mu.Lock()
+	c.data[xuid] = CacheEntry{Roles: slices.Clone(roles), Fetched: time.Now().UnixMilli()}
+	c.dirty = true
+	c.mu.Unlock()
+
+	c.signalFlush()
+}
+
+// Delete removes the entry of xuid, used once an account is known to be
+// unlinked.
+func (c *Cache) Delete(xuid string) {
+	c.mu.Lock()
+	_, ok := c.data[xuid]
+	delete(c.data, xuid)
+	c.dirty = c.dirty || ok
+	c.mu.Unlock()
+
+	if ok {
+		c.signalFlush()
+	}
+}
+
+// Len returns the number of cached accounts.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.data)
+}
+
+// signalFlush wakes the flusher without blocking.
+func (c *Cache) signalFlush() {
+	if c.path == "" {
+		return
+	}
+
+	select {
+	case c.flush <- struct{}{}:
+	default:
+	}
+}
+
+// Stop writes pending changes and stops the flusher. Safe to call multiple
+// times.
+func (c *Cache) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.stopped
+}
+
+// flusher serialises disk writes onto a single goroutine, coalescing bursts.
+// dirty schedules a write for changes made while loading.
+func (c *Cache) flusher(dirty bool) {
+	defer close(c.stopped)
+
+	timer := time.NewTimer(flushInterval)
+	timer.Stop()
+
+	if dirty {
+		timer.Reset(flushInterval)
+	}
+
+	for {
+		select {
+		case <-c.stop:
+			c.writeIfDirty()
+
+			return
+		case <-c.flush:
+			timer.Reset(flushInterval)
+		case <-timer.C:
+			c.writeIfDirty()
+		}
+	}
+}
+
+// writeIfDirty writes a snapshot of the cache if it has unsaved changes.
+func (c *Cache) writeIfDirty() {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+
+		return
+	}
+	snapshot := maps.Clone(c.data)
+	c.dirty = false
+	c.mu.Unlock()
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(c.path), defaultDirPerms)
+	}
+	if err == nil {
+		err = util.WriteFileAtomic(c.path, data)
+	}
+	if err != nil {
+		c.log.Error("failed to write rank cache", "path", c.path, "error", err)
+
+		// Retry with the next change.
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+	}
+}
@@ -0,0 +1,88 @@
+package rank
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestCachePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranks.json")
+
+	old := time.Now().Add(-48 * time.Hour).UnixMilli()
+	data, _ := json.Marshal(map[string]CacheEntry{
+		"1": {Roles: []string{"a"}, Fetched: time.Now().UnixMilli()},
+		"2": {Roles: []string{"b"}, Fetched: old},
+	})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache(discard, path, 24*time.Hour)
+	if roles, _, ok := c.Get("1"); !ok || !slices.Equal(roles, []string{"a"}) {
+		t.Fatalf("Get(1) = %v, %v", roles, ok)
+	}
+	if _, _, ok := c.Get("2"); ok {
+		t.Fatal("entry outside the stale window should be dropped")
+	}
+
+	c.Set("3", []string{"c"})
+	c.Delete("1")
+	c.Stop()
+
+	c = NewCache(discard, path, 24*time.Hour)
+	defer c.Stop()
+
+	if _, _, ok := c.Get("1"); ok {
+		t.Fatal("deleted entry was persisted")
+	}
+	if roles, _, ok := c.Get("3"); !ok || !slices.Equal(roles, []string{"c"}) {
+		t.Fatalf("Get(3) after reload = %v, %v", roles, ok)
+	}
+}
+
+func TestRolesOrCached(t *testing.T) {
+	var down atomic.Bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/discord/unlinked":
+			w.WriteHeader(http.StatusNotFound)
+		case down.Load():
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			_, _ = w.Write([]byte(`["role"]`))
+		}
+	}))
+	defer srv.Close()
+
+	NewService(discard, Config{URL: srv.URL, RefreshInterval: time.Hour})
+	s := GlobalService()
+	defer s.Stop()
+
+	if roles, cached, err := s.RolesOrCached(t.Context(), "1"); err != nil || cached || !slices.Equal(roles, []string{"role"}) {
+		t.Fatalf("API up: RolesOrCached() = %v, %v, %v", roles, cached, err)
+	}
+
+	down.Store(true)
+	if roles, cached, err := s.RolesOrCached(t.Context(), "1"); err != nil || !cached || !slices.Equal(roles, []string{"role"}) {
+		t.Fatalf("API down: RolesOrCached() = %v, %v, %v", roles, cached, err)
+	}
+	if _, _, err := s.RolesOrCached(t.Context(), "2"); err == nil {
+		t.Fatal("API down without cached roles: expected error")
+	}
+	if _, _, err := s.RolesOrCached(t.Context(), "unlinked"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unlinked: err = %v, want ErrUserNotFound", err)
+	}
+}
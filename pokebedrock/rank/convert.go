@@ -1,26 +1,15 @@
 // Package rank provides a conversion between external role IDs and in-game ranks.
 package rank
 
-import "sort"
-
-// rolesToRanks maps external role IDs to in-game ranks.
-// It is built automatically from rankInfos.
-var rolesToRanks = make(map[string]Rank)
-
-func init() {
-	for r, info := range rankInfos {
-		if info.RoleID != "" {
-			rolesToRanks[info.RoleID] = r
-		}
-	}
-}
+import "slices"
 
 // RolesToRanks converts a slice of external role IDs into a slice of in-game ranks.
 func RolesToRanks(roles []string) []Rank {
 	var ranks []Rank
 
+	reg := loaded.Load()
 	for _, role := range roles {
-		if rank, ok := rolesToRanks[role]; ok {
+		if rank, ok := reg.roles[role]; ok {
 			ranks = append(ranks, rank)
 		}
 	}
@@ -36,9 +25,5 @@ func GetHighestRank(roles []string) Rank {
 		return UnLinked
 	}
 
-	sort.SliceStable(ranks, func(i, j int) bool {
-		return ranks[i] < ranks[j]
-	})
-
-	return ranks[len(ranks)-1]
+	return slices.MaxFunc(ranks, Rank.Compare)
 }
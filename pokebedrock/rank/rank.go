@@ -1,11 +1,22 @@
 package rank
 
-import "github.com/sandertv/gophertunnel/minecraft/text"
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
 
-// Rank represents the rank of a player.
+	"github.com/sandertv/gophertunnel/minecraft/text"
+)
+
+// Rank identifies the rank of a player. The built-in ranks below have fixed
+// identifiers so code can refer to them; how ranks are ordered, displayed
+// and granted, as well as any additional ranks, comes from the rank
+// definitions loaded with Load. Ranks must be compared with Compare or
+// AtLeast rather than numerically.
 type Rank int
 
-// Rank constants for the rank service.
+// Rank constants for the built-in ranks. They are aliases for the
+// definitions with the matching ID.
 const (
 	UnLinked Rank = iota
 	Trainer
@@ -30,103 +41,313 @@ const (
 	Owner
 )
 
-// Info centralizes all details for each rank.
-type Info struct {
-	DisplayName string // Human-readable name of the rank.
-	Color       string // Color to be used (must follow the color guidelines).
-	Prefix      bool   // If true, the rank's title is prepended to the player's name.
-	RoleID      string // External role identifier.
-}
-
-// RankConfig holds the configurable role IDs for ranks
-type RankConfig struct {
-	TrainerRoleID              string
-	ServerBoosterRoleID        string
-	SupporterRoleID            string
-	PremiumRoleID              string
-	ContentCreatorRoleID       string
-	MonthlyTournamentMVPRoleID string
-	RetiredStaffRoleID         string
-	HelperRoleID               string
-	TeamRoleID                 string
-	TranslatorRoleID           string
-	DevelopmentTeamRoleID      string
-	TrailModelerRoleID         string
-	ModelerRoleID              string
-	HeadModelerRoleID          string
-	ModeratorRoleID            string
-	SeniorModeratorRoleID      string
-	HeadModeratorRoleID        string
-	AdminRoleID                string
-	ManagerRoleID              string
-	OwnerRoleID                string
-}
-
-// rankInfos holds the rank details in the same order as the Rank constants.
-var rankInfos map[Rank]Info
-
-// InitializeRanks initializes the rank system with the provided configuration
-func InitializeRanks(config RankConfig) {
-	rankInfos = map[Rank]Info{
-		UnLinked:             {DisplayName: "UnLinked", Color: "grey", Prefix: false},
-		Trainer:              {DisplayName: "Trainer", Color: "white", Prefix: true, RoleID: config.TrainerRoleID},
-		ServerBooster:        {DisplayName: "Server Booster", Color: "diamond", Prefix: true, RoleID: config.ServerBoosterRoleID},
-		Supporter:            {DisplayName: "Supporter", Color: "emerald", Prefix: true, RoleID: config.SupporterRoleID},
-		Premium:              {DisplayName: "Premium", Color: "green", Prefix: true, RoleID: config.PremiumRoleID},
-		ContentCreator:       {DisplayName: "Content Creator", Color: "amethyst", Prefix: true, RoleID: config.ContentCreatorRoleID},
-		MonthlyTournamentMVP: {DisplayName: "Monthly Tournament MVP", Color: "aqua", Prefix: true, RoleID: config.MonthlyTournamentMVPRoleID},
-		RetiredStaff:         {DisplayName: "Retired Staff", Color: "grey", Prefix: true, RoleID: config.RetiredStaffRoleID},
-		Helper:               {DisplayName: "Helper", Color: "yellow", Prefix: true, RoleID: config.HelperRoleID},
-		Team:                 {DisplayName: "Team", Color: "gold", Prefix: true, RoleID: config.TeamRoleID},
-		Translator:           {DisplayName: "Translator", Color: "dark-yellow", Prefix: true, RoleID: config.TranslatorRoleID},
-		DevelopmentTeam:      {DisplayName: "Development Team", Color: "redstone", Prefix: true, RoleID: config.DevelopmentTeamRoleID},
-		TrailModeler:         {DisplayName: "Trail Modeler", Color: "dark-green", Prefix: true, RoleID: config.TrailModelerRoleID},
-		Modeler:              {DisplayName: "Modeler", Color: "purple", Prefix: true, RoleID: config.ModelerRoleID},
-		HeadModeler:          {DisplayName: "Head Modeler", Color: "dark-purple", Prefix: true, RoleID: config.HeadModelerRoleID},
-		Moderator:            {DisplayName: "Moderator", Color: "blue", Prefix: true, RoleID: config.ModeratorRoleID},
-		SeniorModerator:      {DisplayName: "Senior Moderator", Color: "aqua", Prefix: true, RoleID: config.SeniorModeratorRoleID},
-		HeadModerator:        {DisplayName: "Head Moderator", Color: "dark-blue", Prefix: true, RoleID: config.HeadModeratorRoleID},
-		Admin:                {DisplayName: "Admin", Color: "red", Prefix: true, RoleID: config.AdminRoleID},
-		Manager:              {DisplayName: "Manager", Color: "purple", Prefix: true, RoleID: config.ManagerRoleID},
-		Owner:                {DisplayName: "Owner", Color: "dark-red", Prefix: true, RoleID: config.OwnerRoleID},
-	}
-
-	// Rebuild the role to rank mapping
-	rolesToRanks = make(map[string]Rank)
-	for r, info := range rankInfos {
-		if info.RoleID != "" {
-			rolesToRanks[info.RoleID] = r
+// builtinIDs holds the definition IDs of the built-in ranks.
+var builtinIDs = [...]string{
+	UnLinked:             "unlinked",
+	Trainer:              "trainer",
+	ServerBooster:        "server-booster",
+	Supporter:            "supporter",
+	Premium:              "premium",
+	ContentCreator:       "content-creator",
+	MonthlyTournamentMVP: "monthly-tournament-mvp",
+	RetiredStaff:         "retired-staff",
+	Helper:               "helper",
+	Team:                 "team",
+	Translator:           "translator",
+	DevelopmentTeam:      "development-team",
+	TrailModeler:         "trail-modeler",
+	Modeler:              "modeler",
+	HeadModeler:          "head-modeler",
+	Moderator:            "moderator",
+	SeniorModerator:      "senior-moderator",
+	HeadModerator:        "head-moderator",
+	Admin:                "admin",
+	Manager:              "manager",
+	Owner:                "owner",
+}
+
+// Definition configures a single rank.
+type Definition struct {
+	// ID identifies the rank. Every built-in rank ID must be defined.
+	ID string
+	// DisplayName is the human-readable name of the rank.
+	DisplayName string
+	// Colour is the colour tag of the rank, such as "aqua" or "dark-red".
+	// Load rejects tags that text.Colourf doesn't know.
+	Colour string
+	// Prefix prepends the display name to the player's name.
+	Prefix bool
+	// RoleIDs are the external role IDs granting the rank.
+	RoleIDs []string
+	// QueuePriority is the weight ordering server queues, highest first.
+	// Zero uses the rank's position in the definitions.
+	QueuePriority int
+	// Chat allows players whose highest rank this is to use public chat.
+	Chat bool
+}
+
+// DefaultDefinitions returns the definitions of the built-in ranks, lowest
+// first, without any role IDs.
+func DefaultDefinitions() []Definition {
+	def := func(r Rank, name, colour string) Definition {
+		return Definition{ID: builtinIDs[r], DisplayName: name, Colour: colour, Prefix: true, Chat: true}
+	}
+
+	return []Definition{
+		{ID: builtinIDs[UnLinked], DisplayName: "UnLinked", Colour: "grey"},
+		def(Trainer, "Trainer", "white"),
+		def(ServerBooster, "Server Booster", "diamond"),
+		def(Supporter, "Supporter", "emerald"),
+		def(Premium, "Premium", "green"),
+		def(ContentCreator, "Content Creator", "amethyst"),
+		def(MonthlyTournamentMVP, "Monthly Tournament MVP", "aqua"),
+		def(RetiredStaff, "Retired Staff", "grey"),
+		def(Helper, "Helper", "yellow"),
+		def(Team, "Team", "gold"),
+		def(Translator, "Translator", "dark-yellow"),
+		def(DevelopmentTeam, "Development Team", "redstone"),
+		def(TrailModeler, "Trail Modeler", "dark-green"),
+		def(Modeler, "Modeler", "purple"),
+		def(HeadModeler, "Head Modeler", "dark-purple"),
+		def(Moderator, "Moderator", "blue"),
+		def(SeniorModerator, "Senior Moderator", "aqua"),
+		def(HeadModerator, "Head Moderator", "dark-blue"),
+		def(Admin, "Admin", "red"),
+		def(Manager, "Manager", "purple"),
+		def(Owner, "Owner", "dark-red"),
+	}
+}
+
+// BuiltinID returns the definition ID of a built-in rank.
+func BuiltinID(r Rank) (string, bool) {
+	if r < 0 || int(r) >= len(builtinIDs) {
+		return "", false
+	}
+
+	return builtinIDs[r], true
+}
+
+// info is a loaded rank definition.
+type info struct {
+	Definition
+
+	position int
+}
+
+// registry holds the loaded rank definitions.
+type registry struct {
+	ordered []Rank
+	infos   map[Rank]info
+	byID    map[string]Rank
+	roles   map[string]Rank
+}
+
+// loaded holds the registry in use. It starts with the default definitions.
+var loaded atomic.Pointer[registry]
+
+func init() {
+	if err := Load(DefaultDefinitions()); err != nil {
+		panic(err)
+	}
+}
+
+// Load replaces the rank definitions. Definitions are ordered from lowest
+// to highest; the first one must be the "unlinked" rank. Additional ranks
+// get identifiers after the built-in ones in the order they're defined.
+func Load(defs []Definition) error {
+	reg := &registry{
+		ordered: make([]Rank, 0, len(defs)),
+		infos:   make(map[Rank]info, len(defs)),
+		byID:    make(map[string]Rank, len(defs)),
+		roles:   make(map[string]Rank),
+	}
+
+	builtin := make(map[string]Rank, len(builtinIDs))
+	for r, id := range builtinIDs {
+		builtin[id] = Rank(r)
+	}
+
+	if len(defs) == 0 || !strings.EqualFold(defs[0].ID, builtinIDs[UnLinked]) {
+		return fmt.Errorf("the first rank must be %q", builtinIDs[UnLinked])
+	}
+
+	next := Rank(len(builtinIDs))
+	for i, d := range defs {
+		d.ID = strings.ToLower(strings.TrimSpace(d.ID))
+		if d.ID == "" {
+			return fmt.Errorf("rank %d has no id", i+1)
+		}
+		if _, ok := reg.byID[d.ID]; ok {
+			return fmt.Errorf("rank %q is defined twice", d.ID)
+		}
+
+		r, ok := builtin[d.ID]
+		if !ok {
+			r = next
+			next++
+		}
+		if d.DisplayName == "" {
+			d.DisplayName = d.ID
+		}
+		if d.Colour == "" {
+			d.Colour = "white"
+		}
+		if !validColour(d.Colour) {
+			return fmt.Errorf("rank %q has unknown colour %q", d.ID, d.Colour)
+		}
+		if d.QueuePriority == 0 {
+			d.QueuePriority = i
+		}
+
+		for _, role := range d.RoleIDs {
+			if other, ok := reg.roles[role]; ok {
+				return fmt.Errorf("role %s is granted by both %q and %q", role, reg.infos[other].ID, d.ID)
+			}
+			reg.roles[role] = r
+		}
+
+		reg.ordered = append(reg.ordered, r)
+		reg.infos[r] = info{Definition: d, position: i}
+		reg.byID[d.ID] = r
+	}
+
+	for r, id := range builtinIDs {
+		if _, ok := reg.byID[id]; !ok {
+			return fmt.Errorf("built-in rank %q (%d) is not defined", id, r)
+		}
+	}
+
+	loaded.Store(reg)
+
+	return nil
+}
+
+// validColour reports whether c is a colour tag, such as "dark-red", that
+// text.Colourf replaces. Unknown tags are left in the text as is.
+func validColour(c string) bool {
+	for _, r := range c {
+		if (r < 'a' || r > 'z') && r != '-' {
+			return false
 		}
 	}
+
+	return c != "" && !strings.Contains(text.Colourf("<%[1]s>x</%[1]s>", c), "<")
+}
+
+// All returns every rank, lowest first.
+func All() []Rank {
+	return append([]Rank(nil), loaded.Load().ordered...)
+}
+
+// ByID returns the rank with the given definition ID.
+func ByID(id string) (Rank, bool) {
+	r, ok := loaded.Load().byID[strings.ToLower(strings.TrimSpace(id))]
+
+	return r, ok
+}
+
+// info returns the loaded definition of the rank.
+func (r Rank) info() (info, bool) {
+	i, ok := loaded.Load().infos[r]
+
+	return i, ok
+}
+
+// ID returns the definition ID of the rank.
+func (r Rank) ID() string {
+	i, ok := r.info()
+	if !ok {
+		return ""
+	}
+
+	return i.ID
 }
 
 // Name returns the human-readable name of the rank.
 func (r Rank) Name() string {
-	if int(r) < 0 || int(r) >= len(rankInfos) {
+	i, ok := r.info()
+	if !ok {
 		return "Unknown"
 	}
 
-	return rankInfos[r].DisplayName
+	return i.DisplayName
+}
+
+// Colour returns the colour tag of the rank.
+func (r Rank) Colour() string {
+	i, ok := r.info()
+	if !ok {
+		return "grey"
+	}
+
+	return i.Colour
+}
+
+// Compare returns -1, 0 or +1 depending on whether r is ordered below, at
+// or above o. Unknown ranks are ordered below every defined rank.
+func (r Rank) Compare(o Rank) int {
+	ri, rok := r.info()
+	oi, ook := o.info()
+
+	switch {
+	case r == o || (!rok && !ook):
+		return 0
+	case !rok:
+		return -1
+	case !ook:
+		return 1
+	case ri.position < oi.position:
+		return -1
+	case ri.position > oi.position:
+		return 1
+	}
+
+	return 0
+}
+
+// AtLeast reports whether r is o or ordered above it.
+func (r Rank) AtLeast(o Rank) bool {
+	return r.Compare(o) >= 0
+}
+
+// QueuePriority returns the weight ordering server queues, highest first.
+func (r Rank) QueuePriority() int {
+	i, ok := r.info()
+	if !ok {
+		return 0
+	}
+
+	return i.QueuePriority
+}
+
+// CanChat reports whether players whose highest rank is r may use public
+// chat.
+func (r Rank) CanChat() bool {
+	i, ok := r.info()
+
+	return ok && i.Chat
 }
 
 // FormatName formats a player's name according to their rank.
 // If the rank uses a prefix, the rank's title is prepended.
 func (r Rank) FormatName(name string) string {
-	if _, ok := rankInfos[r]; !ok {
+	i, ok := r.info()
+	if !ok {
 		return text.Colourf("<grey>%s</grey>", name)
 	}
 
-	info := rankInfos[r]
-	if info.Prefix {
-		return text.Colourf("<%s>%s %s</%s>", info.Color, info.DisplayName, name, info.Color)
+	if i.Prefix {
+		return text.Colourf("<%s>%s %s</%s>", i.Colour, i.DisplayName, name, i.Colour)
 	}
 
-	return text.Colourf("<%s>%s</%s>", info.Color, name, info.Color)
+	return text.Colourf("<%s>%s</%s>", i.Colour, name, i.Colour)
 }
 
-// Chat formats a chat message with the rank's styled name.
+// Chat formats a chat message with the rank's styled name. The message is
+// not parsed for colour tags, so formatting is limited to the codes it
+// already holds.
 func (r Rank) Chat(name, message string) string {
-	return text.Colourf("%s: <grey>%s</grey>", r.FormatName(name), message)
+	return text.Colourf("%s: ", r.FormatName(name)) + text.Grey + message
 }
 
 // NameTag returns the formatted name tag of the player.
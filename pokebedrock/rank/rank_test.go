@@ -0,0 +1,80 @@
+package rank
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoadValidation(t *testing.T) {
+	t.Cleanup(func() { _ = Load(DefaultDefinitions()) })
+
+	withoutOwner := DefaultDefinitions()
+	withoutOwner = withoutOwner[:len(withoutOwner)-1]
+
+	duplicateRole := DefaultDefinitions()
+	duplicateRole[1].RoleIDs = []string{"1"}
+	duplicateRole[2].RoleIDs = []string{"1"}
+
+	duplicateID := append(DefaultDefinitions(), Definition{ID: "Admin"})
+
+	unknownColour := DefaultDefinitions()
+	unknownColour[1].Colour = "rainbow"
+
+	tagColour := DefaultDefinitions()
+	tagColour[1].Colour = "red></red><bold"
+
+	tests := []struct {
+		name string
+		defs []Definition
+	}{
+		{name: "empty", defs: nil},
+		{name: "unlinked not first", defs: append(DefaultDefinitions()[1:], DefaultDefinitions()[0])},
+		{name: "missing built-in", defs: withoutOwner},
+		{name: "duplicate role", defs: duplicateRole},
+		{name: "duplicate id", defs: duplicateID},
+		{name: "unknown colour", defs: unknownColour},
+		{name: "colour with tags", defs: tagColour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Load(tt.defs); err == nil {
+				t.Fatal("expected error")
+			}
+			if Owner.Name() != "Owner" {
+				t.Fatal("failed load replaced the loaded definitions")
+			}
+		})
+	}
+}
+
+func TestCustomRank(t *testing.T) {
+	t.Cleanup(func() { _ = Load(DefaultDefinitions()) })
+
+	defs := DefaultDefinitions()
+	defs[3].RoleIDs = []string{"supporter-role"}
+	vip := Definition{ID: "vip", DisplayName: "VIP", RoleIDs: []string{"vip-role"}, QueuePriority: 100, Chat: true}
+	defs = slices.Insert(defs, 4, vip)
+	if err := Load(defs); err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok := ByID("VIP")
+	if !ok {
+		t.Fatal("custom rank not registered")
+	}
+	if r <= Owner {
+		t.Fatalf("custom rank id %d overlaps the built-in ranks", r)
+	}
+	if !r.AtLeast(Supporter) || r.AtLeast(Premium) {
+		t.Fatal("custom rank is not ordered between Supporter and Premium")
+	}
+	if got := GetHighestRank([]string{"supporter-role", "vip-role"}); got != r {
+		t.Fatalf("GetHighestRank() = %v, want %v", got, r)
+	}
+	if r.QueuePriority() != 100 || Premium.QueuePriority() != 5 {
+		t.Fatalf("QueuePriority() = %d, %d", r.QueuePriority(), Premium.QueuePriority())
+	}
+	if UnLinked.CanChat() || !r.CanChat() {
+		t.Fatal("unexpected chat permissions")
+	}
+}
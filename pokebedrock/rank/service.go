@@ -7,15 +7,20 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/df-mc/atomic"
+	"golang.org/x/text/language"
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
 )
 
 // globalService holds the singleton rank service.
@@ -26,7 +31,23 @@ func GlobalService() *Service {
 	return globalService
 }
 
-// Service fetches roles for a player from the upstream rank API.
+// Config holds the settings of the rank service.
+type Config struct {
+	// URL is the base URL of the rank API.
+	URL string
+	// CachePath is the file the last known roles of every account are
+	// persisted in. Empty keeps them in memory only.
+	CachePath string
+	// StaleWindow is how long cached roles are served after they were last
+	// fetched while the API is unavailable. 0 serves them forever.
+	StaleWindow time.Duration
+	// RefreshInterval is how often accounts served from the cache are
+	// fetched again in the background.
+	RefreshInterval time.Duration
+}
+
+// Service fetches roles for a player from the upstream rank API, falling
+// back to the last known roles when the API is unavailable.
 //
 // The closed flag is atomic so concurrent readers (request callers) and
 // the shutdown writer don't race.
@@ -36,17 +57,37 @@ type Service struct {
 
 	client *http.Client
 	log    *slog.Logger
+	cache  *Cache
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu        sync.Mutex
+	stale     map[string]struct{}
+	onRefresh func(xuid string, roles []string)
 }
 
+// defaultRefreshInterval is used when no refresh interval is configured.
+const defaultRefreshInterval = 30 * time.Second
+
 // NewService initialises the singleton rank service.
-func NewService(log *slog.Logger, url string) {
+func NewService(log *slog.Logger, conf Config) {
+	if conf.RefreshInterval <= 0 {
+		conf.RefreshInterval = defaultRefreshInterval
+	}
+
 	globalService = &Service{
-		url: url,
+		url: conf.URL,
 		client: &http.Client{
 			Timeout: requestTimeout,
 		},
-		log: log,
+		log:   log,
+		cache: NewCache(log, conf.CachePath, conf.StaleWindow),
+		stop:  make(chan struct{}),
+		stale: make(map[string]struct{}),
 	}
+
+	go globalService.refreshLoop(conf.RefreshInterval)
 }
 
 const (
@@ -63,8 +104,8 @@ var (
 )
 
 // RolesOfXUID fetches the roles for the given XUID, retrying transient
-// failures.
-func (s *Service) RolesOfXUID(xuid string) ([]string, error) {
+// failures until ctx is done.
+func (s *Service) RolesOfXUID(ctx context.Context, xuid string) ([]string, error) {
 	var lastErr error
 
 	for attempt := 0; attempt <= maxRetries; attempt++ {
@@ -72,11 +113,18 @@ func (s *Service) RolesOfXUID(xuid string) ([]string, error) {
 			break
 		}
 		if attempt > 0 {
-			time.Sleep(retryDelay)
+			if err := util.Sleep(ctx, retryDelay); err != nil {
+				return nil, err
+			}
 		}
 
-		roles, retry, err := s.fetchRoles(xuid, attempt)
+		roles, retry, err := s.fetchRoles(ctx, xuid, attempt)
 		if err == nil {
+			s.cache.Set(xuid, roles)
+			s.mu.Lock()
+			delete(s.stale, xuid)
+			s.mu.Unlock()
+
 			return roles, nil
 		}
 
@@ -84,6 +132,9 @@ func (s *Service) RolesOfXUID(xuid string) ([]string, error) {
 		if retry {
 			continue
 		}
+		if errors.Is(err, ErrUserNotFound) {
+			s.cache.Delete(xuid)
+		}
 
 		return nil, err
 	}
@@ -91,10 +142,108 @@ func (s *Service) RolesOfXUID(xuid string) ([]string, error) {
 	return nil, lastErr
 }
 
+// RolesOrCached fetches the roles for the given XUID like RolesOfXUID. When
+// the API can't be reached it returns the last known roles instead, with
+// cached set, and keeps fetching them in the background until the API
+// recovers. ErrUserNotFound is never answered from the cache.
+func (s *Service) RolesOrCached(ctx context.Context, xuid string) (roles []string, cached bool, err error) {
+	roles, err = s.RolesOfXUID(ctx, xuid)
+	if err == nil || errors.Is(err, ErrUserNotFound) {
+		return roles, false, err
+	}
+
+	roles, fetched, ok := s.cache.Get(xuid)
+	if !ok {
+		return nil, false, err
+	}
+
+	s.log.Warn("rank API unavailable, using cached roles", "xuid", xuid, "fetched", fetched, "error", err)
+
+	s.mu.Lock()
+	s.stale[xuid] = struct{}{}
+	s.mu.Unlock()
+
+	return roles, true, nil
+}
+
+// CachedRank returns the highest rank granted by the last known roles of
+// the account without contacting the rank API. Linked accounts without a
+// mapped role are Trainers, as when their roles are fetched.
+func (s *Service) CachedRank(xuid string) (Rank, bool) {
+	roles, _, ok := s.cache.Get(xuid)
+	if !ok {
+		return UnLinked, false
+	}
+	if len(RolesToRanks(roles)) == 0 {
+		return Trainer, true
+	}
+
+	return GetHighestRank(roles), true
+}
+
+// SetRefreshHandler sets the function called when roles served from the
+// cache are fetched again in the background. roles is nil when the account
+// turned out to be unlinked. It is called on the refresh goroutine.
+func (s *Service) SetRefreshHandler(f func(xuid string, roles []string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onRefresh = f
+}
+
+// refreshLoop fetches the roles of accounts served from the cache once per
+// interval, with a single attempt each, until the API answers again.
+func (s *Service) refreshLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		xuids := slices.Collect(maps.Keys(s.stale))
+		s.mu.Unlock()
+
+		for _, xuid := range xuids {
+			if s.closed.Load() {
+				return
+			}
+
+			roles, _, err := s.fetchRoles(context.Background(), xuid, 0)
+			if err != nil && !errors.Is(err, ErrUserNotFound) {
+				// Still down; try the rest next interval.
+				s.log.Debug("rank API still unavailable", "xuid", xuid, "error", err)
+
+				break
+			}
+
+			if err != nil {
+				s.cache.Delete(xuid)
+				roles = nil
+			} else {
+				s.cache.Set(xuid, roles)
+			}
+
+			s.mu.Lock()
+			delete(s.stale, xuid)
+			onRefresh := s.onRefresh
+			s.mu.Unlock()
+
+			if onRefresh != nil {
+				onRefresh(xuid, roles)
+			}
+		}
+	}
+}
+
 // fetchRoles performs a single attempt at fetching roles. The retry flag
 // indicates the caller should sleep and try again.
-func (s *Service) fetchRoles(xuid string, attempt int) (roles []string, retry bool, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+func (s *Service) fetchRoles(ctx context.Context, xuid string, attempt int) (roles []string, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/api/discord/%s", s.url, xuid), nil)
@@ -131,7 +280,7 @@ func (s *Service) fetchRoles(xuid string, attempt int) (roles []string, retry bo
 	case http.StatusNotFound:
 		return nil, false, ErrUserNotFound
 	case http.StatusTooManyRequests:
-		time.Sleep(time.Duration(attempt+1) * retryDelay)
+		_ = util.Sleep(ctx, time.Duration(attempt+1)*retryDelay)
 
 		return nil, true, fmt.Errorf("rate limited")
 	default:
@@ -143,10 +292,14 @@ func (s *Service) fetchRoles(xuid string, attempt int) (roles []string, retry bo
 	}
 }
 
-// Stop signals the service to stop accepting new requests. In-flight
-// requests still run to completion.
+// Stop signals the service to stop accepting new requests and writes the
+// rank cache. In-flight requests still run to completion.
 func (s *Service) Stop() {
 	s.closed.Store(true)
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.cache.Stop()
 }
 
 // isTemporaryError reports whether the error is transient and worth
@@ -164,15 +317,16 @@ func isTemporaryError(err error) bool {
 	return false
 }
 
-// RolesError converts an error from RolesOfXUID into a user-facing message.
-func RolesError(err error) string {
+// RolesError converts an error from RolesOfXUID into a user-facing message
+// in the given language.
+func RolesError(lang language.Tag, err error) string {
 	switch {
 	case errors.Is(err, ErrUserNotFound):
-		return locale.Translate("error.account_not_linked")
+		return locale.TranslateL(lang, "error.account_not_linked")
 	case errors.Is(err, ErrTimeout):
-		return locale.Translate("error.timeout_fetching_roles")
+		return locale.TranslateL(lang, "error.timeout_fetching_roles")
 	case errors.Is(err, ErrServer) || strings.Contains(err.Error(), "actively refused"):
-		return locale.Translate("error.server_error_fetching_roles")
+		return locale.TranslateL(lang, "error.server_error_fetching_roles")
 	default:
 		return fmt.Sprintf("Failed to fetch roles %s", err)
 	}
@@ -0,0 +1,36 @@
+package report
+
+import (
+	"strings"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// rankHandler ...
+type rankHandler interface {
+	Ranks() *session.Ranks
+}
+
+// NotifyStaff sends a notification about r to every online staff member,
+// pointing them to /reports. Must be called on the world owner.
+func NotifyStaff(tx *world.Tx, r Report) {
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+
+		h, ok := p.Handler().(rankHandler)
+		if !ok || !h.Ranks().HasPermission(p.XUID(), permission.ReportManage) {
+			continue
+		}
+
+		msg := locale.TranslateFor(p, "report.staff.notify", r.ReporterName, r.TargetName, r.Category, r.Reason)
+		for l := range strings.SplitSeq(msg, "<new-line>") {
+			p.Message(l)
+		}
+		p.SendJukeboxPopup(locale.TranslateFor(p, "report.staff.popup", r.TargetName))
+	}
+}
@@ -0,0 +1,382 @@
+// Package report lets players report rule-breakers from the hub. Reports are
+// stored locally, forwarded to the moderation API and routed to online staff.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// maxStoredReports is the number of reports kept in the local store. The
+// oldest reports are dropped first once the limit is reached.
+const maxStoredReports = 500
+
+// forwardRetryInterval is how often reports the moderation API didn't
+// accept are forwarded again.
+const forwardRetryInterval = 5 * time.Minute
+
+// Report is a single player report.
+type Report struct {
+	ID           int64  `json:"id"`
+	ReporterName string `json:"reporter_name"`
+	ReporterXUID string `json:"reporter_xuid"`
+	TargetName   string `json:"target_name"`
+	TargetXUID   string `json:"target_xuid"`
+	Category     string `json:"category"`
+	Reason       string `json:"reason"`
+	Created      int64  `json:"created"`
+
+	// Forwarded reports whether the moderation API accepted the report.
+	Forwarded bool `json:"forwarded"`
+	// HandledBy is the staff member who took action on or dismissed the
+	// report. Empty while the report is open.
+	HandledBy string `json:"handled_by,omitempty"`
+}
+
+// Open reports whether no staff member has handled the report yet.
+func (r Report) Open() bool {
+	return r.HandledBy == ""
+}
+
+// Config holds the settings of the report manager.
+type Config struct {
+	// Path is the file reports are stored in.
+	Path string
+	// Cooldown is the minimum time between two reports by the same player.
+	Cooldown time.Duration
+	// Categories are the report categories players pick from.
+	Categories []string
+}
+
+// Manager stores reports and enforces per-player report cooldowns.
+type Manager struct {
+	log  *slog.Logger
+	conf Config
+
+	mu        sync.Mutex
+	reports   []Report
+	nextID    int64
+	cooldowns map[string]time.Time
+	closed    bool
+
+	// send forwards a report to the moderation API. forwarding holds the
+	// IDs of reports being forwarded, so a retry never sends one twice.
+	send       func(Report) error
+	forwarding map[int64]struct{}
+
+	saveCh   chan []byte
+	saveDone chan struct{}
+	stop     chan struct{}
+}
+
+// global holds the singleton report manager.
+var global *Manager
+
+// Global returns the singleton report manager.
+func Global() *Manager {
+	return global
+}
+
+// NewManager initialises the singleton report manager, loading previously
+// stored reports from conf.Path. Stored reports the moderation API never
+// accepted are forwarded again right away and then periodically.
+func NewManager(log *slog.Logger, conf Config) *Manager {
+	m := newManager(log, conf, sendToModeration)
+	global = m
+
+	return m
+}
+
+// newManager creates a report manager forwarding reports with send.
+func newManager(log *slog.Logger, conf Config, send func(Report) error) *Manager {
+	m := &Manager{
+		log:        log,
+		conf:       conf,
+		cooldowns:  make(map[string]time.Time),
+		send:       send,
+		forwarding: make(map[int64]struct{}),
+		saveCh:     make(chan []byte, 1),
+		saveDone:   make(chan struct{}),
+		stop:       make(chan struct{}),
+	}
+
+	if err := m.load(); err != nil {
+		log.Error("failed to load reports", "path", conf.Path, "error", err)
+	}
+	for _, r := range m.reports {
+		m.nextID = max(m.nextID, r.ID)
+	}
+
+	go m.saveLoop()
+	go m.retryLoop()
+
+	return m
+}
+
+// Categories returns the report categories players pick from.
+func (m *Manager) Categories() []string {
+	return m.conf.Categories
+}
+
+// Cooldown returns how long the player with the given XUID has to wait
+// before filing another report. Zero means they may report now.
+func (m *Manager) Cooldown(xuid string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.cooldownLocked(xuid, time.Now())
+}
+
+// cooldownLocked returns the remaining cooldown. Caller must hold m.mu.
+func (m *Manager) cooldownLocked(xuid string, now time.Time) time.Duration {
+	last, ok := m.cooldowns[xuid]
+	if !ok {
+		return 0
+	}
+
+	return max(m.conf.Cooldown-now.Sub(last), 0)
+}
+
+// Submit stores a new report and forwards it to the moderation API in the
+// background. It fails if the reporter is still on cooldown.
+func (m *Manager) Submit(r Report) (Report, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	if remaining := m.cooldownLocked(r.ReporterXUID, now); remaining > 0 {
+		m.mu.Unlock()
+
+		return Report{}, fmt.Errorf("report cooldown active for %s", remaining.Round(time.Second))
+	}
+
+	m.nextID++
+	r.ID = m.nextID
+	r.Created = now.UnixMilli()
+	m.cooldowns[r.ReporterXUID] = now
+
+	m.reports = append(m.reports, r)
+	if len(m.reports) > maxStoredReports {
+		m.reports = slices.Delete(m.reports, 0, len(m.reports)-maxStoredReports)
+	}
+	m.queueSaveLocked()
+	m.mu.Unlock()
+
+	go m.forward(r)
+
+	return r, nil
+}
+
+// forward sends the report to the moderation API and records whether it was
+// accepted. Runs off the world owner.
+func (m *Manager) forward(r Report) {
+	m.mu.Lock()
+	if _, ok := m.forwarding[r.ID]; ok || m.forwardedLocked(r.ID) {
+		m.mu.Unlock()
+
+		return
+	}
+	m.forwarding[r.ID] = struct{}{}
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		delete(m.forwarding, r.ID)
+		m.mu.Unlock()
+	}()
+
+	if err := m.send(r); err != nil {
+		m.log.Error("failed to forward report", "id", r.ID, "target", r.TargetName, "error", err)
+
+		return
+	}
+
+	m.update(r.ID, func(r *Report) {
+		r.Forwarded = true
+	})
+}
+
+// forwardedLocked reports whether the stored report with the given ID was
+// accepted by the moderation API. Caller must hold m.mu.
+func (m *Manager) forwardedLocked(id int64) bool {
+	for _, r := range m.reports {
+		if r.ID == id {
+			return r.Forwarded
+		}
+	}
+
+	return false
+}
+
+// retryLoop forwards reports the moderation API didn't accept until the
+// manager is closed.
+func (m *Manager) retryLoop() {
+	t := time.NewTicker(forwardRetryInterval)
+	defer t.Stop()
+
+	for {
+		m.retryUnforwarded()
+
+		select {
+		case <-t.C:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// retryUnforwarded forwards every stored report not yet accepted by the
+// moderation API.
+func (m *Manager) retryUnforwarded() {
+	m.mu.Lock()
+	var pending []Report
+	for _, r := range m.reports {
+		if !r.Forwarded {
+			pending = append(pending, r)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, r := range pending {
+		m.forward(r)
+	}
+}
+
+// sendToModeration forwards the report to the moderation API.
+func sendToModeration(r Report) error {
+	svc := moderation.GlobalService()
+	if svc == nil {
+		return errors.New("moderation service not loaded")
+	}
+
+	return svc.AddReport(moderation.ReportRequest{
+		ReporterName: r.ReporterName,
+		ReporterXUID: r.ReporterXUID,
+		TargetName:   r.TargetName,
+		TargetXUID:   r.TargetXUID,
+		Category:     r.Category,
+		Reason:       r.Reason,
+		DateReported: r.Created,
+	})
+}
+
+// OpenReports returns all reports that have not been handled, newest first.
+func (m *Manager) OpenReports() []Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Report, 0)
+	for i := len(m.reports) - 1; i >= 0; i-- {
+		if m.reports[i].Open() {
+			out = append(out, m.reports[i])
+		}
+	}
+
+	return out
+}
+
+// Resolve marks the report with the given ID as handled by staff. It
+// returns false if no open report with that ID exists.
+func (m *Manager) Resolve(id int64, staff string) bool {
+	var resolved bool
+
+	m.update(id, func(r *Report) {
+		if r.Open() {
+			r.HandledBy = staff
+			resolved = true
+		}
+	})
+
+	return resolved
+}
+
+// update applies f to the stored report with the given ID and queues a save.
+func (m *Manager) update(id int64, f func(r *Report)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.reports {
+		if m.reports[i].ID == id {
+			f(&m.reports[i])
+			m.queueSaveLocked()
+
+			return
+		}
+	}
+}
+
+// load reads stored reports from disk.
+func (m *Manager) load() error {
+	data, err := os.ReadFile(m.conf.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	return json.Unmarshal(data, &m.reports)
+}
+
+// queueSaveLocked snapshots the reports while m.mu is held and coalesces
+// disk writes on the background save loop.
+func (m *Manager) queueSaveLocked() {
+	if m.closed {
+		return
+	}
+
+	data, err := json.MarshalIndent(m.reports, "", "  ")
+	if err != nil {
+		return
+	}
+
+	select {
+	case m.saveCh <- data:
+	default:
+		select {
+		case <-m.saveCh:
+		default:
+		}
+		select {
+		case m.saveCh <- data:
+		default:
+		}
+	}
+}
+
+// saveLoop writes queued snapshots without blocking the world owner.
+func (m *Manager) saveLoop() {
+	defer close(m.saveDone)
+
+	for data := range m.saveCh {
+		if err := os.MkdirAll(filepath.Dir(m.conf.Path), os.ModePerm); err != nil {
+			m.log.Error("failed to create reports directory", "path", filepath.Dir(m.conf.Path), "error", err)
+
+			continue
+		}
+		if err := util.WriteFileAtomic(m.conf.Path, data); err != nil {
+			m.log.Error("failed to write reports", "path", m.conf.Path, "error", err)
+		}
+	}
+}
+
+// Close flushes queued report snapshots to disk.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	close(m.saveCh)
+	close(m.stop)
+	m.mu.Unlock()
+
+	<-m.saveDone
+}
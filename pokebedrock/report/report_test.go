@@ -0,0 +1,108 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubmitCooldownAndPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports.json")
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := NewManager(log, Config{Path: path, Cooldown: time.Minute})
+
+	r, err := m.Submit(Report{ReporterXUID: "1", TargetName: "Target", Category: "Spam", Reason: "flooding"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Submit(Report{ReporterXUID: "1", TargetName: "Target"}); err == nil {
+		t.Fatal("expected second report within cooldown to fail")
+	}
+	if _, err := m.Submit(Report{ReporterXUID: "2", TargetName: "Target"}); err != nil {
+		t.Fatalf("other reporter blocked by cooldown: %v", err)
+	}
+
+	if !m.Resolve(r.ID, "Staff") {
+		t.Fatal("expected open report to resolve")
+	}
+	if m.Resolve(r.ID, "Staff") {
+		t.Fatal("resolved report resolved twice")
+	}
+	m.Close()
+
+	reloaded := NewManager(log, Config{Path: path})
+	defer reloaded.Close()
+
+	open := reloaded.OpenReports()
+	if len(open) != 1 || open[0].ReporterXUID != "2" {
+		t.Fatalf("expected only the second report open after reload, got %+v", open)
+	}
+	if next, _ := reloaded.Submit(Report{ReporterXUID: "3"}); next.ID <= open[0].ID {
+		t.Fatalf("report ID %d reused after reload", next.ID)
+	}
+}
+
+func TestForwardRetry(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		up   bool
+		sent int
+	)
+	send := func(Report) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !up {
+			return errors.New("moderation API down")
+		}
+		sent++
+
+		return nil
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	m := newManager(log, Config{Path: filepath.Join(t.TempDir(), "reports.json")}, send)
+	defer m.Close()
+
+	r, err := m.Submit(Report{ReporterXUID: "1", TargetName: "Target"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.forward(r)
+	if isForwarded(m, r.ID) {
+		t.Fatal("report marked forwarded while the API is down")
+	}
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+
+	// The forward started by Submit may still be in flight; retry until
+	// one of them gets through.
+	deadline := time.Now().Add(2 * time.Second)
+	for !isForwarded(m, r.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("report not forwarded on retry")
+		}
+		m.retryUnforwarded()
+	}
+	m.retryUnforwarded()
+	m.forward(r)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if sent != 1 {
+		t.Fatalf("report sent %d times, want 1", sent)
+	}
+}
+
+// isForwarded reports whether the stored report was accepted.
+func isForwarded(m *Manager, id int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.forwardedLocked(id)
+}
@@ -0,0 +1,57 @@
+package scoreboard
+
+import (
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// Event is a scheduled event shown on the sidebar.
+type Event struct {
+	// Name is the name of the event.
+	Name string
+	// Start is when the event first starts.
+	Start time.Time
+	// Duration is how long the event is shown as running after it starts.
+	Duration util.Duration
+	// Every repeats the event at this interval after Start. 0 runs it once.
+	Every util.Duration
+}
+
+// Next returns when the current run of e started if it is running at now,
+// otherwise when it next starts. ok is false if it never runs again.
+func (e Event) Next(now time.Time) (at time.Time, ok bool) {
+	if e.Start.IsZero() {
+		return time.Time{}, false
+	}
+
+	at = e.Start
+	if every := time.Duration(e.Every); every > 0 && now.After(at) {
+		// Step to the last start at or before now.
+		at = at.Add(now.Sub(at) / every * every)
+	}
+	if at.Add(time.Duration(e.Duration)).After(now) || !at.Before(now) {
+		return at, true
+	}
+	if e.Every > 0 {
+		return at.Add(time.Duration(e.Every)), true
+	}
+
+	return time.Time{}, false
+}
+
+// NextEvent returns the event running at now, or else the event starting
+// soonest, along with its start. ok is false if no event runs again.
+func NextEvent(events []Event, now time.Time) (next Event, at time.Time, ok bool) {
+	for _, e := range events {
+		t, upcoming := e.Next(now)
+		if !upcoming {
+			continue
+		}
+		if !ok || t.Before(at) {
+			next, at, ok = e, t, true
+		}
+	}
+
+	return next, at, ok
+}
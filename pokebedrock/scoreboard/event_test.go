@@ -0,0 +1,55 @@
+package scoreboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+func TestEventNext(t *testing.T) {
+	start := time.Date(2026, 1, 3, 18, 0, 0, 0, time.UTC)
+	weekly := Event{Name: "Tournament", Start: start, Duration: util.Duration(2 * time.Hour), Every: util.Duration(7 * 24 * time.Hour)}
+	once := Event{Name: "Launch", Start: start, Duration: util.Duration(time.Hour)}
+
+	tests := []struct {
+		name   string
+		event  Event
+		now    time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"before first run", weekly, start.Add(-time.Hour), start, true},
+		{"running", weekly, start.Add(time.Hour), start, true},
+		{"running later week", weekly, start.Add(14*24*time.Hour + time.Minute), start.Add(14 * 24 * time.Hour), true},
+		{"between runs", weekly, start.Add(3 * time.Hour), start.Add(7 * 24 * time.Hour), true},
+		{"one-off running", once, start.Add(30 * time.Minute), start, true},
+		{"one-off over", once, start.Add(2 * time.Hour), time.Time{}, false},
+		{"no start", Event{Name: "Unscheduled"}, start, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.event.Next(tt.now)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Fatalf("Next() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNextEvent(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Name: "Later", Start: now.Add(2 * time.Hour)},
+		{Name: "Past", Start: now.Add(-2 * time.Hour)},
+		{Name: "Sooner", Start: now.Add(time.Hour)},
+	}
+
+	e, at, ok := NextEvent(events, now)
+	if !ok || e.Name != "Sooner" || !at.Equal(now.Add(time.Hour)) {
+		t.Fatalf("NextEvent() = %q at %v, %v, want Sooner", e.Name, at, ok)
+	}
+	if _, _, ok := NextEvent(events[1:2], now); ok {
+		t.Fatal("NextEvent() found an event that is over")
+	}
+}
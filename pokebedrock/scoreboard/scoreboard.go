@@ -0,0 +1,367 @@
+// Package scoreboard shows every player a sidebar with their rank, the
+// network player count, their queue, their parkour personal best and the
+// next scheduled event.
+package scoreboard
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/scoreboard"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/profile"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/queue"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/srv"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// PreferenceKey is the profile preference set to "off" by players who
+// turned the sidebar off.
+const PreferenceKey = "scoreboard"
+
+// Line IDs that may be listed in Config.Lines.
+const (
+	LineRank    = "rank"
+	LinePlayers = "players"
+	LineQueue   = "queue"
+	LineParkour = "parkour"
+	LineEvent   = "event"
+	LineBlank   = "blank"
+)
+
+// maxLines is the number of lines a sidebar can hold.
+const maxLines = 15
+
+// defaultRefreshInterval is used when no refresh interval is configured.
+const defaultRefreshInterval = 5 * time.Second
+
+// Config holds the settings of a Manager.
+type Config struct {
+	// Enabled shows the sidebar to players who haven't turned it off.
+	Enabled bool
+	// Lines are the IDs of the lines shown, top first.
+	Lines []string
+	// RefreshInterval is how long it takes to refresh the sidebar of every
+	// player. Players are refreshed in batches spread over the interval.
+	RefreshInterval time.Duration
+	// Events are the scheduled events, the next of which is shown.
+	Events []Event
+	// PersonalBest returns the fastest parkour time of the player with the
+	// given XUID and the name of that course. Nil shows no times.
+	PersonalBest func(xuid string) (course string, best time.Duration, ok bool)
+}
+
+// DefaultLines returns the lines shown when none are configured.
+func DefaultLines() []string {
+	return []string{LineRank, LineBlank, LinePlayers, LineQueue, LineBlank, LineParkour, LineEvent}
+}
+
+// rankHandler is implemented by player handlers that track ranks.
+type rankHandler interface {
+	Ranks() *session.Ranks
+}
+
+// Manager keeps the sidebars of online players up to date.
+type Manager struct {
+	conf Config
+
+	mu sync.Mutex
+	// order holds the handles of online players, refreshed round-robin
+	// starting at cursor.
+	order  []*world.EntityHandle
+	cursor int
+	// pending holds players refreshed on the next update, ahead of the
+	// rotation.
+	pending map[*world.EntityHandle]struct{}
+	// sent holds the lines last sent to each player, nil if their sidebar
+	// is hidden.
+	sent       map[*world.EntityHandle][]string
+	lastUpdate time.Time
+	progress   float64
+}
+
+// global holds the singleton scoreboard manager. It starts out disabled
+// until NewManager is called.
+var global = newManager(slog.Default(), Config{})
+
+// Global returns the singleton scoreboard manager.
+func Global() *Manager {
+	return global
+}
+
+// NewManager initialises the singleton scoreboard manager. Unknown line IDs
+// are logged and skipped.
+func NewManager(log *slog.Logger, conf Config) *Manager {
+	global = newManager(log, conf)
+
+	return global
+}
+
+// newManager creates a scoreboard manager.
+func newManager(log *slog.Logger, conf Config) *Manager {
+	if conf.RefreshInterval <= 0 {
+		conf.RefreshInterval = defaultRefreshInterval
+	}
+	if len(conf.Lines) == 0 {
+		conf.Lines = DefaultLines()
+	}
+
+	conf.Lines = slices.DeleteFunc(slices.Clone(conf.Lines), func(id string) bool {
+		switch id {
+		case LineRank, LinePlayers, LineQueue, LineParkour, LineEvent, LineBlank:
+			return false
+		}
+		log.Warn("unknown scoreboard line", "line", id)
+
+		return true
+	})
+	if len(conf.Lines) > maxLines {
+		log.Warn("too many scoreboard lines, extra lines are not shown", "lines", len(conf.Lines), "max", maxLines)
+		conf.Lines = conf.Lines[:maxLines]
+	}
+
+	return &Manager{
+		conf:    conf,
+		pending: make(map[*world.EntityHandle]struct{}),
+		sent:    make(map[*world.EntityHandle][]string),
+	}
+}
+
+// HandleJoin schedules the sidebar of p to be shown on the next update,
+// once their profile had a moment to load.
+func (m *Manager) HandleJoin(p *player.Player) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.order = append(m.order, p.H())
+	m.pending[p.H()] = struct{}{}
+}
+
+// HandleQuit forgets the sidebar of p.
+func (m *Manager) HandleQuit(p *player.Player) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if i := slices.Index(m.order, p.H()); i >= 0 {
+		m.order = slices.Delete(m.order, i, i+1)
+		if m.cursor > i {
+			m.cursor--
+		}
+	}
+	delete(m.pending, p.H())
+	delete(m.sent, p.H())
+}
+
+// Available reports whether the sidebar is enabled on the hub.
+func (m *Manager) Available() bool {
+	return m.conf.Enabled
+}
+
+// Enabled reports whether p sees the sidebar.
+func (m *Manager) Enabled(p *player.Player) bool {
+	if !m.conf.Enabled {
+		return false
+	}
+	v, _ := profile.Global().Preference(p.XUID(), PreferenceKey)
+
+	return v != "off"
+}
+
+// SetEnabled turns the sidebar of p on or off and refreshes it right away.
+func (m *Manager) SetEnabled(p *player.Player, enabled bool) {
+	v := "off"
+	if enabled {
+		v = ""
+	}
+	profile.Global().SetPreference(p.XUID(), PreferenceKey, v)
+
+	m.refresh(p, m.newState(p.Tx(), time.Now()), enabled && m.conf.Enabled)
+}
+
+// Update refreshes the next batch of sidebars, sized so every player is
+// refreshed once per refresh interval, along with pending players. It is
+// called on the world owner every tick.
+func (m *Manager) Update(tx *world.Tx) {
+	if !m.conf.Enabled {
+		return
+	}
+	now := time.Now()
+
+	m.mu.Lock()
+	batch := make([]*world.EntityHandle, 0, len(m.pending))
+	for h := range m.pending {
+		batch = append(batch, h)
+	}
+	clear(m.pending)
+
+	if !m.lastUpdate.IsZero() && len(m.order) > 0 {
+		m.progress += float64(len(m.order)) * float64(now.Sub(m.lastUpdate)) / float64(m.conf.RefreshInterval)
+		n := min(int(math.Floor(m.progress)), len(m.order))
+		m.progress -= float64(n)
+
+		for range n {
+			m.cursor %= len(m.order)
+			if h := m.order[m.cursor]; !slices.Contains(batch, h) {
+				batch = append(batch, h)
+			}
+			m.cursor++
+		}
+	}
+	m.lastUpdate = now
+	m.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	st := m.newState(tx, now)
+	for _, h := range batch {
+		ent, ok := h.Entity(tx)
+		if !ok {
+			continue
+		}
+		p := ent.(*player.Player)
+		// Players may have turned the sidebar off, which isn't known until
+		// their profile has loaded.
+		if !profile.Global().Loaded(p.XUID()) {
+			m.mu.Lock()
+			m.pending[h] = struct{}{}
+			m.mu.Unlock()
+
+			continue
+		}
+		m.refresh(p, st, m.Enabled(p))
+	}
+}
+
+// state holds the values shared by every sidebar refreshed in an update.
+type state struct {
+	players   int
+	event     Event
+	eventAt   time.Time
+	eventNext bool
+	now       time.Time
+}
+
+// newState collects the values shared by every sidebar.
+func (m *Manager) newState(tx *world.Tx, now time.Time) state {
+	st := state{now: now}
+	for range tx.Players() {
+		st.players++
+	}
+	for _, s := range srv.All() {
+		if status := s.Status(); status.Online {
+			st.players += status.PlayerCount
+		}
+	}
+	st.event, st.eventAt, st.eventNext = NextEvent(m.conf.Events, now)
+
+	return st
+}
+
+// refresh sends the sidebar of p if it changed, or removes it if it is
+// hidden.
+func (m *Manager) refresh(p *player.Player, st state, enabled bool) {
+	if !enabled {
+		m.mu.Lock()
+		shown := m.sent[p.H()] != nil
+		m.sent[p.H()] = nil
+		m.mu.Unlock()
+
+		if shown {
+			p.RemoveScoreboard()
+		}
+
+		return
+	}
+
+	lines := m.lines(p, st)
+
+	m.mu.Lock()
+	unchanged := slices.Equal(m.sent[p.H()], lines)
+	m.sent[p.H()] = lines
+	m.mu.Unlock()
+
+	if unchanged {
+		return
+	}
+
+	sb := scoreboard.New(locale.TranslateFor(p, "scoreboard.title"))
+	for i, line := range lines {
+		sb.Set(i, line)
+	}
+	p.SendScoreboard(sb)
+}
+
+// lines renders the sidebar lines of p.
+func (m *Manager) lines(p *player.Player, st state) []string {
+	lines := make([]string, 0, len(m.conf.Lines))
+	for _, id := range m.conf.Lines {
+		switch id {
+		case LineRank:
+			r := rankOf(p)
+			lines = append(lines, locale.TranslateFor(p, "scoreboard.rank", locale.Args{"rank": r.Name(), "colour": r.Colour()}))
+		case LinePlayers:
+			lines = append(lines, locale.TranslateFor(p, "scoreboard.players", locale.Args{"count": st.players}))
+		case LineQueue:
+			if s, position := queue.QueueManager.Lookup(p.H()); s != nil {
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.queue", locale.Args{"server": s.Name(), "position": position}))
+			} else {
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.queue.none"))
+			}
+		case LineParkour:
+			if course, best, ok := m.personalBest(p.XUID()); ok {
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.parkour", locale.Args{
+					"time":   fmt.Sprintf("%.2f", best.Seconds()),
+					"course": course,
+				}))
+			} else {
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.parkour.none"))
+			}
+		case LineEvent:
+			switch {
+			case !st.eventNext:
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.event.none"))
+			case !st.eventAt.After(st.now):
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.event.live", locale.Args{"event": st.event.Name}))
+			default:
+				lines = append(lines, locale.TranslateFor(p, "scoreboard.event", locale.Args{
+					"event": st.event.Name,
+					"in":    util.FormatDuration(st.eventAt.Sub(st.now).Round(time.Minute)),
+				}))
+			}
+		case LineBlank:
+			lines = append(lines, "")
+		}
+	}
+
+	return lines
+}
+
+// rankOf returns the highest rank of p.
+func rankOf(p *player.Player) rank.Rank {
+	if h, ok := p.Handler().(rankHandler); ok && h.Ranks() != nil {
+		return h.Ranks().HighestRank()
+	}
+
+	return rank.UnLinked
+}
+
+// personalBest returns the fastest parkour time of the player with the
+// given XUID.
+func (m *Manager) personalBest(xuid string) (string, time.Duration, bool) {
+	if m.conf.PersonalBest == nil {
+		return "", 0, false
+	}
+
+	return m.conf.PersonalBest(xuid)
+}
@@ -0,0 +1,84 @@
+package session
+
+import (
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/form"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+)
+
+// activityHandler is implemented by player handlers that track ranks and
+// activity.
+type activityHandler interface {
+	Ranks() *Ranks
+	Movement() *Movement
+}
+
+// afkSuffix is appended to the name tag of AFK players.
+var afkSuffix = text.Colourf(" <grey>[AFK]</grey>")
+
+// NameTag returns the name tag of p for their highest rank, marked if they
+// are AFK.
+func NameTag(p *player.Player, highest rank.Rank) string {
+	tag := highest.NameTag(p.Name())
+	if h, ok := p.Handler().(activityHandler); ok && h.Movement().AFK() {
+		tag += afkSuffix
+	}
+
+	return tag
+}
+
+// SetAFK marks p as AFK or back, updating their name tag. It reports
+// whether their AFK status changed. Must be called on the world owner.
+func SetAFK(p *player.Player, afk bool) bool {
+	h, ok := p.Handler().(activityHandler)
+	if !ok || h.Movement().AFK() == afk {
+		return false
+	}
+	if afk {
+		h.Movement().SetAFK(true)
+	} else {
+		h.Movement().SetActive(time.Now())
+	}
+	p.SetNameTag(NameTag(p, h.Ranks().HighestRank()))
+
+	return true
+}
+
+// Active records activity of p, resetting their idle timer and telling
+// them they are no longer AFK if they were. Must be called on the world
+// owner.
+func Active(p *player.Player) {
+	h, ok := p.Handler().(activityHandler)
+	if !ok {
+		return
+	}
+	if h.Movement().SetActive(time.Now()) {
+		p.SetNameTag(NameTag(p, h.Ranks().HighestRank()))
+		p.Message(locale.TranslateFor(p, "afk.back"))
+	}
+}
+
+// SendForm sends f to p, counting answering it as activity.
+func SendForm(p *player.Player, f form.Form) {
+	p.SendForm(activeForm{Form: f})
+}
+
+// activeForm records activity of the player answering the form it wraps.
+type activeForm struct {
+	form.Form
+}
+
+// SubmitJSON ...
+func (f activeForm) SubmitJSON(b []byte, submitter form.Submitter, tx *world.Tx) error {
+	if p, ok := submitter.(*player.Player); ok {
+		Active(p)
+	}
+
+	return f.Form.SubmitJSON(b, submitter, tx)
+}
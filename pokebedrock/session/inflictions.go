@@ -2,6 +2,7 @@
 package session
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -132,7 +133,7 @@ func processInflictionRequest(req inflictionRequest) {
 		return
 	}
 
-	resp, err := modSvc.InflictionOfXUID(req.xuid)
+	resp, err := modSvc.InflictionOfXUID(context.Background(), req.xuid)
 	if err != nil || resp == nil {
 		return
 	}
@@ -187,6 +188,16 @@ func (i *Inflictions) Muted() bool {
 	return i.muted.Load()
 }
 
+// MutedNow returns whether the player is muted and the mute hasn't expired.
+func (i *Inflictions) MutedNow() bool {
+	if !i.Muted() {
+		return false
+	}
+	dur := i.MuteDuration()
+
+	return dur == 0 || dur >= time.Now().UnixMilli()
+}
+
 // SetMuteDuration sets the mute duration for the player.
 func (i *Inflictions) SetMuteDuration(duration int64) {
 	i.muteDuration.Store(duration)
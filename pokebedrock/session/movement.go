@@ -8,16 +8,16 @@ import (
 	"github.com/go-gl/mathgl/mgl64"
 )
 
-// Movement provides tracking of a player’s last move time,
-// position, yaw, and pitch, along with per-idle-streak AFK warning flags
-// consumed by the hub's AFK evaluator.
+// Movement provides tracking of a player’s last activity, position, yaw,
+// and pitch, along with their AFK status and per-idle-streak AFK warning
+// flags consumed by the hub's AFK evaluator.
 type Movement struct {
-	lastMoveTime time.Time
+	lastActive   time.Time
 	lastPosition mgl64.Vec3
 	lastRotation cube.Rotation
 
 	warnedApproaching bool
-	markedAFK         bool
+	afk               bool
 	warnedFinal       bool
 
 	mu sync.RWMutex
@@ -26,29 +26,35 @@ type Movement struct {
 // NewMovement creates a new instance of Movement.
 func NewMovement() *Movement {
 	return &Movement{
-		lastMoveTime: time.Now(),
+		lastActive:   time.Now(),
 		lastPosition: mgl64.Vec3{},
 		lastRotation: cube.Rotation{},
 	}
 }
 
-// LastMoveTime returns the time of the last movement.
-func (m *Movement) LastMoveTime() time.Time {
+// LastActive returns the time of the last activity, such as moving,
+// looking around, chatting or using an item or form.
+func (m *Movement) LastActive() time.Time {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	return m.lastMoveTime
+	return m.lastActive
 }
 
-// SetLastMoveTime updates the time of the last movement. Warning flags are
-// reset so the next idle streak starts from scratch.
-func (m *Movement) SetLastMoveTime(t time.Time) {
+// SetActive updates the time of the last activity and clears the AFK
+// status. Warning flags are reset so the next idle streak starts from
+// scratch. It returns whether the player was AFK.
+func (m *Movement) SetActive(t time.Time) bool {
 	m.mu.Lock()
-	m.lastMoveTime = t
+	defer m.mu.Unlock()
+
+	wasAFK := m.afk
+	m.lastActive = t
 	m.warnedApproaching = false
-	m.markedAFK = false
+	m.afk = false
 	m.warnedFinal = false
-	m.mu.Unlock()
+
+	return wasAFK
 }
 
 // WarnedApproaching reports whether the approaching-AFK soft warning has
@@ -66,18 +72,18 @@ func (m *Movement) SetWarnedApproaching(v bool) {
 	m.mu.Unlock()
 }
 
-// MarkedAFK reports whether the "you are now AFK" soft warning has already
-// been sent for the current idle streak.
-func (m *Movement) MarkedAFK() bool {
+// AFK reports whether the player is AFK, either because they were idle
+// long enough or because they used /afk. It is cleared by activity.
+func (m *Movement) AFK() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.markedAFK
+	return m.afk
 }
 
-// SetMarkedAFK marks the "you are now AFK" soft warning as sent.
-func (m *Movement) SetMarkedAFK(v bool) {
+// SetAFK sets whether the player is AFK.
+func (m *Movement) SetAFK(v bool) {
 	m.mu.Lock()
-	m.markedAFK = v
+	m.afk = v
 	m.mu.Unlock()
 }
 
@@ -1,8 +1,8 @@
 package session
 
 import (
+	"context"
 	"slices"
-	"sort"
 	"sync"
 	"time"
 
@@ -13,6 +13,8 @@ import (
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/queue"
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
 )
 
@@ -106,16 +108,16 @@ func processRankUpdate(update rankUpdate) {
 
 	if update.notify {
 		player.Do(update.handle, func(_ *world.Tx, p *player.Player) {
-			p.SendJukeboxPopup(locale.Translate("rank.fetching"))
+			p.SendJukeboxPopup(locale.TranslateFor(p, "rank.fetching"))
 		})
 	}
 
-	roles, err := rank.GlobalService().RolesOfXUID(update.xuid)
+	roles, cached, err := rank.GlobalService().RolesOrCached(context.Background(), update.xuid)
 	if err != nil {
 		update.ranks.SetRanks([]rank.Rank{rank.UnLinked})
 
-		msg := text.Colourf("<red>%s</red>", rank.RolesError(err))
 		player.Do(update.handle, func(_ *world.Tx, p *player.Player) {
+			msg := text.Colourf("<red>%s</red>", rank.RolesError(locale.Language(p), err))
 			p.SendJukeboxPopup(msg)
 			p.Message(msg)
 		})
@@ -123,6 +125,22 @@ func processRankUpdate(update rankUpdate) {
 		return
 	}
 
+	// Keep players with cached ranks around so the background refresh can
+	// apply their roles once the rank API recovers.
+	cachedRanksMu.Lock()
+	if cached {
+		cachedRanks[update.xuid] = update
+	} else {
+		delete(cachedRanks, update.xuid)
+	}
+	cachedRanksMu.Unlock()
+
+	applyRoles(update, roles, cached)
+}
+
+// applyRoles resolves roles into ranks and applies them to the player,
+// announcing the result. Cached roles are announced as such.
+func applyRoles(update rankUpdate, roles []string, cached bool) {
 	ranks := rank.RolesToRanks(roles)
 	if len(ranks) == 0 {
 		// Player has no valid mapped roles; default to Trainer.
@@ -130,16 +148,115 @@ func processRankUpdate(update rankUpdate) {
 	}
 
 	update.ranks.SetRanks(ranks)
-	update.ranks.SetLastRankFetch(time.Now())
+	update.ranks.SetCached(cached)
+	if !cached {
+		update.ranks.SetLastRankFetch(time.Now())
+	}
 
 	highest := update.ranks.HighestRank()
-	syncedMsg := text.Colourf("<green>%s</green>", locale.Translate("rank.synced", highest.Name()))
-
-	player.Do(update.handle, func(_ *world.Tx, p *player.Player) {
-		p.SendJukeboxPopup(syncedMsg)
-		p.Message(syncedMsg)
-		p.SetNameTag(highest.NameTag(p.Name()))
+	player.Do(update.handle, func(tx *world.Tx, p *player.Player) {
+		msg := text.Colourf("<green>%s</green>", locale.TranslateFor(p, "rank.synced", highest.Name()))
+		if cached {
+			msg = text.Colourf("<yellow>%s</yellow>", locale.TranslateFor(p, "rank.cached", highest.Name()))
+		}
+		p.SendJukeboxPopup(msg)
+		p.Message(msg)
+		p.SetNameTag(NameTag(p, highest))
+
+		ranksHandlerMu.Lock()
+		onRanks := ranksHandler
+		ranksHandlerMu.Unlock()
+		if onRanks != nil {
+			onRanks(tx, p, highest)
+		}
 	})
+	queue.QueueManager.UpdateRank(update.handle, highest)
+}
+
+var (
+	// ranksHandler is called on the world owner whenever ranks are applied
+	// to an online player.
+	ranksHandler   func(tx *world.Tx, p *player.Player, highest rank.Rank)
+	ranksHandlerMu sync.Mutex
+)
+
+// SetRanksHandler sets the function called on the world owner whenever
+// ranks are applied to an online player, including the first time after
+// they join.
+func SetRanksHandler(f func(tx *world.Tx, p *player.Player, highest rank.Rank)) {
+	ranksHandlerMu.Lock()
+	defer ranksHandlerMu.Unlock()
+
+	ranksHandler = f
+}
+
+var (
+	// cachedRanks holds the online players whose ranks were resolved from
+	// cached roles, keyed by XUID, until the rank API answers again.
+	cachedRanks   = make(map[string]rankUpdate)
+	cachedRanksMu sync.Mutex
+)
+
+// ApplyRefreshedRoles applies roles fetched in the background to an online
+// player whose ranks came from the cache. Nil roles mean the account is no
+// longer linked. It is registered with rank.Service.SetRefreshHandler.
+func ApplyRefreshedRoles(xuid string, roles []string) {
+	cachedRanksMu.Lock()
+	update, ok := cachedRanks[xuid]
+	delete(cachedRanks, xuid)
+	cachedRanksMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if roles == nil {
+		update.ranks.SetRanks([]rank.Rank{rank.UnLinked})
+		update.ranks.SetCached(false)
+
+		player.Do(update.handle, func(_ *world.Tx, p *player.Player) {
+			p.Message(text.Colourf("<red>%s</red>", locale.TranslateFor(p, "error.account_not_linked")))
+			p.SetNameTag(NameTag(p, rank.UnLinked))
+		})
+		queue.QueueManager.UpdateRank(update.handle, rank.UnLinked)
+
+		return
+	}
+
+	applyRoles(update, roles, false)
+}
+
+var (
+	// onlineRanks holds the last rank update of every online player, keyed
+	// by XUID, so their ranks can be refreshed on request.
+	onlineRanks   = make(map[string]rankUpdate)
+	onlineRanksMu sync.Mutex
+)
+
+// RefreshRanks fetches the ranks of the online player with the given XUID
+// again, such as after their roles changed. It reports whether the player
+// is online.
+func RefreshRanks(xuid string) bool {
+	onlineRanksMu.Lock()
+	update, ok := onlineRanks[xuid]
+	onlineRanksMu.Unlock()
+
+	if ok {
+		update.ranks.enqueue(update)
+	}
+
+	return ok
+}
+
+// ForgetRanks stops tracking the player for rank refreshes.
+func ForgetRanks(xuid string) {
+	cachedRanksMu.Lock()
+	delete(cachedRanks, xuid)
+	cachedRanksMu.Unlock()
+
+	onlineRanksMu.Lock()
+	delete(onlineRanks, xuid)
+	onlineRanksMu.Unlock()
 }
 
 // Ranks tracks a player's resolved ranks and the last time they were
@@ -148,6 +265,9 @@ type Ranks struct {
 	rankMu sync.Mutex
 	ranks  []rank.Rank
 
+	// cached is set while the ranks come from cached roles because the rank
+	// API was unavailable.
+	cached        atomic.Bool
 	lastRankFetch atomic.Value[time.Time]
 }
 
@@ -165,6 +285,10 @@ func NewRanks() *Ranks {
 // soon as the request is accepted by the worker pool (or dropped, if the
 // queue is full); the player will be updated asynchronously.
 func (r *Ranks) Load(xuid string, handle *world.EntityHandle) {
+	onlineRanksMu.Lock()
+	onlineRanks[xuid] = rankUpdate{xuid: xuid, handle: handle, ranks: r}
+	onlineRanksMu.Unlock()
+
 	r.enqueue(rankUpdate{xuid: xuid, handle: handle, ranks: r, notify: true})
 }
 
@@ -182,7 +306,7 @@ func (r *Ranks) enqueue(update rankUpdate) {
 	}
 
 	player.Do(update.handle, func(_ *world.Tx, p *player.Player) {
-		p.SendJukeboxPopup(locale.Translate("rank.update.queue.full"))
+		p.SendJukeboxPopup(locale.TranslateFor(p, "rank.update.queue.full"))
 	})
 }
 
@@ -193,9 +317,7 @@ func (r *Ranks) SetRanks(ranks []rank.Rank) {
 	defer r.rankMu.Unlock()
 
 	r.ranks = ranks
-	sort.SliceStable(r.ranks, func(i, j int) bool {
-		return r.ranks[i] < r.ranks[j]
-	})
+	slices.SortStableFunc(r.ranks, rank.Rank.Compare)
 }
 
 // HighestRank returns the player's highest rank, or UnLinked if none are
@@ -230,7 +352,24 @@ func (r *Ranks) HasRank(ra rank.Rank) bool {
 // HasRankOrHigher reports whether the player has the given rank or any
 // rank above it.
 func (r *Ranks) HasRankOrHigher(ra rank.Rank) bool {
-	return r.HighestRank() >= ra
+	return r.HighestRank().AtLeast(ra)
+}
+
+// HasPermission reports whether the player with the given XUID holds the
+// permission node through these ranks or an override of their account.
+func (r *Ranks) HasPermission(xuid, node string) bool {
+	return permission.Has(xuid, r.Ranks(), node)
+}
+
+// Cached reports whether the ranks come from cached roles because the rank
+// API was unavailable.
+func (r *Ranks) Cached() bool {
+	return r.cached.Load()
+}
+
+// SetCached sets whether the ranks come from cached roles.
+func (r *Ranks) SetCached(cached bool) {
+	r.cached.Store(cached)
 }
 
 // LastRankFetch returns the last time ranks were fetched successfully.
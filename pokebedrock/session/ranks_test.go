@@ -0,0 +1,23 @@
+package session
+
+import "testing"
+
+func TestRefreshRanks(t *testing.T) {
+	const xuid = "2535400000000001"
+
+	if RefreshRanks(xuid) {
+		t.Fatal("RefreshRanks() reported an unknown player online")
+	}
+
+	// Updates without a handle are dropped by the workers, so no rank API
+	// is needed.
+	NewRanks().Load(xuid, nil)
+	if !RefreshRanks(xuid) {
+		t.Fatal("RefreshRanks() reported a loaded player offline")
+	}
+
+	ForgetRanks(xuid)
+	if RefreshRanks(xuid) {
+		t.Fatal("RefreshRanks() reported a forgotten player online")
+	}
+}
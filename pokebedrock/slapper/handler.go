@@ -4,9 +4,10 @@ import (
 	"github.com/df-mc/dragonfly/server/player"
 
 	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/form"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
 )
 
 // handleInteract ...
 func (s *Slapper) handleInteract(p *player.Player) {
-	p.SendForm(form.NewServerConfirm(s.Server()))
+	session.SendForm(p, form.NewServerConfirm(s.Server()))
 }
@@ -0,0 +1,46 @@
+package staffchat
+
+import (
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/player/title"
+	"github.com/df-mc/dragonfly/server/world"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/locale"
+)
+
+// Target is where a broadcast is displayed on screen.
+type Target string
+
+// Target constants for Broadcast.
+const (
+	TargetChat      Target = "chat"
+	TargetTitle     Target = "title"
+	TargetActionBar Target = "actionbar"
+)
+
+// Targets returns every broadcast target.
+func Targets() []Target {
+	return []Target{TargetChat, TargetTitle, TargetActionBar}
+}
+
+// Broadcast shows an announcement to every player in the hub and returns the
+// number of players it reached. Must be called on the world owner.
+func Broadcast(tx *world.Tx, target Target, message string) int {
+	var n int
+
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+
+		switch target {
+		case TargetTitle:
+			p.SendTitle(title.New(locale.TranslateFor(p, "broadcast.title")).WithSubtitle(message))
+		case TargetActionBar:
+			p.SendTip(locale.TranslateFor(p, "broadcast.actionbar", message))
+		default:
+			p.Message(locale.TranslateFor(p, "broadcast.chat", message))
+		}
+		n++
+	}
+
+	return n
+}
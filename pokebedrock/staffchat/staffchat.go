@@ -0,0 +1,164 @@
+// Package staffchat provides a private chat channel for staff members, and
+// the announcement broadcasts staff send to everyone in the hub.
+package staffchat
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/df-mc/dragonfly/server/player"
+	"github.com/df-mc/dragonfly/server/world"
+	"github.com/sandertv/gophertunnel/minecraft/text"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/moderation"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/permission"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/rank"
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/session"
+)
+
+// auditAction is the action recorded for mirrored staff chat messages.
+const auditAction = "staff_chat"
+
+// Config holds the settings of the staff chat channel.
+type Config struct {
+	// Prefix sends a single message to staff chat when a staff member's
+	// public chat message starts with it. Empty disables the prefix.
+	Prefix string
+	// MirrorToAuditLog records every staff chat message in the moderation
+	// audit log.
+	MirrorToAuditLog bool
+}
+
+// Channel routes staff chat messages and tracks which staff members have
+// staff chat toggled on.
+type Channel struct {
+	log  *slog.Logger
+	conf Config
+
+	mu      sync.Mutex
+	toggled map[string]struct{}
+}
+
+// global holds the singleton staff chat channel. It starts out with the
+// prefix disabled so /sc works before NewChannel is called.
+var global = &Channel{log: slog.Default(), toggled: make(map[string]struct{})}
+
+// Global returns the singleton staff chat channel.
+func Global() *Channel {
+	return global
+}
+
+// NewChannel initialises the singleton staff chat channel.
+func NewChannel(log *slog.Logger, conf Config) *Channel {
+	c := &Channel{
+		log:     log,
+		conf:    conf,
+		toggled: make(map[string]struct{}),
+	}
+	global = c
+
+	return c
+}
+
+// Toggle flips staff chat for the player with the given XUID and returns
+// whether it is now on.
+func (c *Channel) Toggle(xuid string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.toggled[xuid]; ok {
+		delete(c.toggled, xuid)
+
+		return false
+	}
+	c.toggled[xuid] = struct{}{}
+
+	return true
+}
+
+// Forget turns staff chat off for the player, e.g. when they leave.
+func (c *Channel) Forget(xuid string) {
+	c.mu.Lock()
+	delete(c.toggled, xuid)
+	c.mu.Unlock()
+}
+
+// Route reports whether a public chat message should go to staff chat
+// instead, returning the message with the staff chat prefix removed. The
+// caller is responsible for checking the sender is staff.
+func (c *Channel) Route(xuid, message string) (string, bool) {
+	if c.conf.Prefix != "" && strings.HasPrefix(message, c.conf.Prefix) {
+		return strings.TrimSpace(strings.TrimPrefix(message, c.conf.Prefix)), true
+	}
+
+	c.mu.Lock()
+	_, ok := c.toggled[xuid]
+	c.mu.Unlock()
+
+	return message, ok
+}
+
+// Format formats a staff chat message with the sender's rank colours.
+func Format(r rank.Rank, name, message string) string {
+	return text.Colourf("<dark-aqua>[Staff]</dark-aqua> %s<grey>:</grey> <aqua>%s</aqua>", r.FormatName(name), message)
+}
+
+// Send delivers a staff chat message from sender to every online staff
+// member and mirrors it to the audit log if configured. Must be called on the
+// world owner.
+func (c *Channel) Send(tx *world.Tx, sender *player.Player, r rank.Rank, message string) {
+	if message == "" {
+		return
+	}
+
+	msg := Format(r, sender.Name(), message)
+	for _, p := range Staff(tx) {
+		p.Message(msg)
+	}
+	c.log.Info("staff chat", "name", sender.Name(), "message", message)
+
+	if !c.conf.MirrorToAuditLog {
+		return
+	}
+
+	req := moderation.AuditRequest{
+		Actor:     sender.Name(),
+		ActorXUID: sender.XUID(),
+		Action:    auditAction,
+		Message:   message,
+		Date:      time.Now().UnixMilli(),
+	}
+	go func() {
+		svc := moderation.GlobalService()
+		if svc == nil {
+			return
+		}
+		if err := svc.AddAuditLog(req); err != nil {
+			c.log.Error("failed to mirror staff chat to audit log", "name", req.Actor, "error", err)
+		}
+	}()
+}
+
+// rankHandler ...
+type rankHandler interface {
+	Ranks() *session.Ranks
+}
+
+// Staff returns every online player allowed to read staff chat.
+func Staff(tx *world.Tx) []*player.Player {
+	var staff []*player.Player
+
+	for ent := range tx.Players() {
+		p := ent.(*player.Player)
+
+		h, ok := p.Handler().(rankHandler)
+		if !ok || !h.Ranks().HasPermission(p.XUID(), permission.StaffChat) {
+			continue
+		}
+		staff = append(staff, p)
+	}
+
+	return staff
+}
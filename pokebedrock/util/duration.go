@@ -28,3 +28,30 @@ func (d *Duration) UnmarshalText(text []byte) error {
 func (d *Duration) MarshalText() ([]byte, error) {
 	return []byte(time.Duration(*d).String()), nil
 }
+
+// FormatDuration renders a duration in a compact, human-readable form such as
+// "2d 4h 30m". Durations under a minute are rendered in seconds.
+func FormatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+
+	parts := make([]string, 0, 3)
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,16 @@
+package util
+
+import "os"
+
+// WriteFileAtomic writes data to a temporary file next to path and renames
+// it over path, so readers never see a partially written file.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		_ = os.Remove(tmp)
+
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,49 @@
+package util
+
+import "sync"
+
+// SingleFlight deduplicates concurrent calls for the same key: while a call
+// is in flight, later callers with the same key wait for it and share its
+// result instead of starting their own. The zero value is ready to use.
+type SingleFlight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flightCall[V]
+}
+
+// flightCall is an in-flight or completed call of a SingleFlight.
+type flightCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+}
+
+// Do runs fn for key unless a call for key is already in flight, in which
+// case it waits for that call and returns its result. shared reports
+// whether the result came from another caller's call.
+func (g *SingleFlight[K, V]) Do(key K, fn func() (V, error)) (val V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*flightCall[V])
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+
+		return c.val, c.err, true
+	}
+
+	c := &flightCall[V]{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.val, c.err = fn()
+
+	return c.val, c.err, false
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleFlightDeduplicates(t *testing.T) {
+	var (
+		g       SingleFlight[string, int]
+		calls   atomic.Int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	fn := func() (int, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+
+		return 42, nil
+	}
+
+	results := make([]int, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _, _ = g.Do("ip", fn)
+	}()
+	<-started
+
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _, shared := g.Do("ip", fn)
+			if !shared {
+				t.Error("expected shared result")
+			}
+			results[i] = v
+		}()
+	}
+
+	// Give the waiters time to join the in-flight call before releasing it.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fn called %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("result %d = %d, want 42", i, v)
+		}
+	}
+}
+
+func TestSingleFlightForgetsCompletedCalls(t *testing.T) {
+	var g SingleFlight[string, int]
+
+	failing := errors.New("down")
+	if _, err, _ := g.Do("xuid", func() (int, error) { return 0, failing }); !errors.Is(err, failing) {
+		t.Fatalf("err = %v, want %v", err, failing)
+	}
+
+	v, err, shared := g.Do("xuid", func() (int, error) { return 1, nil })
+	if v != 1 || err != nil || shared {
+		t.Fatalf("Do() = %d, %v, %v; want a fresh call", v, err, shared)
+	}
+}
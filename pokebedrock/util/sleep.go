@@ -0,0 +1,20 @@
+package util
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep pauses for d or until ctx is done, whichever comes first. It
+// returns the error of ctx if it is done before d passed.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
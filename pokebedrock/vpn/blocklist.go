@@ -0,0 +1,296 @@
+package vpn
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// blocklistLabel labels ranges from the user maintained blocklist.
+const blocklistLabel = "blocklist"
+
+// Blocklist holds offline ranges that are always treated as proxies: a CSV
+// dataset of datacenter ranges, a user maintained blocklist and the ranges
+// of blocked ASNs, found through an IP to ASN dataset. Lookups use prefix
+// tries so they are cheap enough to run before any provider is asked. The
+// files are reloaded when they change on disk.
+type Blocklist struct {
+	log *slog.Logger
+
+	datacenterPath string
+	asnPath        string
+	blocklistPath  string
+	static         []string
+
+	mu       sync.RWMutex
+	trie     *prefixTrie
+	asns     *prefixTrie
+	blocked  map[string]struct{}
+	modTimes map[string]time.Time
+}
+
+// NewBlocklist loads the datacenter dataset at datacenterPath, the IP to
+// ASN dataset at asnPath, the user blocklist at blocklistPath and the
+// static entries from the configuration. Missing files are treated as
+// empty; any path may be empty.
+//
+// Both datasets are CSV files with rows of "cidr,asn,organisation". Every
+// datacenter range is blocked; its ASN and organisation are optional and
+// only label verdicts. A range of the ASN dataset is only blocked if its
+// ASN is. The user blocklist and static entries are CIDR ranges, addresses
+// or ASNs such as "AS64500", one per line with # comments in the file.
+func NewBlocklist(log *slog.Logger, datacenterPath, asnPath, blocklistPath string, static []string) *Blocklist {
+	b := &Blocklist{
+		log:            log,
+		datacenterPath: datacenterPath,
+		asnPath:        asnPath,
+		blocklistPath:  blocklistPath,
+		static:         static,
+		trie:           newPrefixTrie(),
+		asns:           newPrefixTrie(),
+		blocked:        make(map[string]struct{}),
+		modTimes:       make(map[string]time.Time),
+	}
+	if err := b.Reload(); err != nil {
+		log.Error("failed to load vpn blocklist", "error", err)
+	}
+
+	return b
+}
+
+// Lookup returns the label of the blocked range containing addr, such as
+// the ASN and organisation of a datacenter range or blocked ASN.
+func (b *Blocklist) Lookup(addr netip.Addr) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if label, ok := b.trie.lookup(addr); ok {
+		return label, true
+	}
+	if label, ok := b.asns.lookup(addr); ok {
+		if _, blocked := b.blocked[asnOf(label)]; blocked {
+			return label, true
+		}
+	}
+
+	return "", false
+}
+
+// parseASN parses an ASN such as "AS64500" or "as64500", returning it in
+// upper case.
+func parseASN(s string) (string, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	num, ok := strings.CutPrefix(s, "AS")
+	if !ok || num == "" || strings.Trim(num, "0123456789") != "" {
+		return "", false
+	}
+
+	return s, true
+}
+
+// asnOf returns the ASN at the start of a dataset label.
+func asnOf(label string) string {
+	asn, _, _ := strings.Cut(label, " ")
+
+	return strings.ToUpper(asn)
+}
+
+// Len returns the number of blocked ranges.
+func (b *Blocklist) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.trie.len()
+}
+
+// Reload reads the blocklist files from disk again. The current ranges are
+// kept if a file can't be read.
+func (b *Blocklist) Reload() error {
+	trie, asns := newPrefixTrie(), newPrefixTrie()
+	blocked := make(map[string]struct{})
+	modTimes := make(map[string]time.Time)
+
+	for _, s := range b.static {
+		if asn, ok := parseASN(s); ok {
+			blocked[asn] = struct{}{}
+
+			continue
+		}
+		p, err := parsePrefix(strings.TrimSpace(s))
+		if err != nil {
+			b.log.Warn("ignoring invalid vpn blocklist cidr", "cidr", s, "error", err)
+
+			continue
+		}
+		trie.insert(p, blocklistLabel)
+	}
+
+	if err := b.loadFile(b.datacenterPath, modTimes, func(r io.Reader) error {
+		return b.readDataset(r, trie, "datacenter")
+	}); err != nil {
+		return fmt.Errorf("datacenter dataset: %w", err)
+	}
+	if err := b.loadFile(b.asnPath, modTimes, func(r io.Reader) error {
+		return b.readDataset(r, asns, "")
+	}); err != nil {
+		return fmt.Errorf("asn dataset: %w", err)
+	}
+	if err := b.loadFile(b.blocklistPath, modTimes, func(r io.Reader) error {
+		return b.readBlocklist(r, trie, blocked)
+	}); err != nil {
+		return fmt.Errorf("blocklist: %w", err)
+	}
+
+	b.mu.Lock()
+	b.trie, b.asns, b.blocked, b.modTimes = trie, asns, blocked, modTimes
+	b.mu.Unlock()
+
+	b.log.Info("loaded vpn blocklist", "ranges", trie.len(), "asn_ranges", asns.len(), "blocked_asns", len(blocked))
+
+	return nil
+}
+
+// loadFile opens path, records its modification time and passes it to read.
+// An empty path or a missing file is skipped.
+func (b *Blocklist) loadFile(path string, modTimes map[string]time.Time, read func(io.Reader) error) error {
+	if path == "" {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil {
+		modTimes[path] = info.ModTime()
+	}
+
+	return read(f)
+}
+
+// readDataset inserts the rows of a CSV dataset labelled with their ASN and
+// organisation, or def if a row has neither. A header row and invalid rows
+// are skipped, as are unlabelled rows if def is empty.
+func (b *Blocklist) readDataset(r io.Reader, trie *prefixTrie, def string) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	for row := 1; ; row++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		p, err := parsePrefix(strings.TrimSpace(rec[0]))
+		if err != nil {
+			if row > 1 {
+				b.log.Debug("skipping invalid dataset range", "row", row, "value", rec[0])
+			}
+
+			continue
+		}
+
+		label := strings.TrimSpace(strings.Join(rec[1:], " "))
+		if label == "" {
+			if def == "" {
+				continue
+			}
+			label = def
+		}
+		trie.insert(p, label)
+	}
+}
+
+// readBlocklist inserts the ranges of the user blocklist and records the
+// ASNs it blocks.
+func (b *Blocklist) readBlocklist(r io.Reader, trie *prefixTrie, blocked map[string]struct{}) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		if asn, ok := parseASN(s); ok {
+			blocked[asn] = struct{}{}
+
+			continue
+		}
+
+		p, err := parsePrefix(s)
+		if err != nil {
+			b.log.Warn("ignoring invalid vpn blocklist line", "path", b.blocklistPath, "line", line, "error", err)
+
+			continue
+		}
+		trie.insert(p, blocklistLabel)
+	}
+
+	return sc.Err()
+}
+
+// changed reports whether any blocklist file was created, removed or
+// modified since it was last loaded.
+func (b *Blocklist) changed() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, path := range []string{b.datacenterPath, b.asnPath, b.blocklistPath} {
+		if path == "" {
+			continue
+		}
+
+		var mod time.Time
+		if info, err := os.Stat(path); err == nil {
+			mod = info.ModTime()
+		}
+		if !mod.Equal(b.modTimes[path]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watch reloads the blocklist whenever its files change, until stop is
+// closed.
+func (b *Blocklist) watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !b.changed() {
+			continue
+		}
+		if err := b.Reload(); err != nil {
+			b.log.Error("failed to reload vpn blocklist", "error", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package vpn
+
+import (
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPrefixTrieLongestMatch(t *testing.T) {
+	trie := newPrefixTrie()
+	trie.insert(netip.MustParsePrefix("10.0.0.0/8"), "wide")
+	trie.insert(netip.MustParsePrefix("10.1.0.0/16"), "narrow")
+	trie.insert(netip.MustParsePrefix("2001:db8::/32"), "v6")
+	trie.insert(netip.MustParsePrefix("::ffff:192.0.2.0/120"), "mapped")
+
+	tests := []struct {
+		addr  string
+		label string
+		found bool
+	}{
+		{"10.2.3.4", "wide", true},
+		{"10.1.3.4", "narrow", true},
+		{"11.0.0.1", "", false},
+		{"2001:db8::1", "v6", true},
+		{"2001:db9::1", "", false},
+		{"192.0.2.9", "mapped", true},
+		{"::ffff:10.1.0.1", "narrow", true},
+	}
+	for _, tt := range tests {
+		label, found := trie.lookup(netip.MustParseAddr(tt.addr))
+		if label != tt.label || found != tt.found {
+			t.Errorf("lookup(%s) = %q, %v; want %q, %v", tt.addr, label, found, tt.label, tt.found)
+		}
+	}
+	if trie.len() != 4 {
+		t.Errorf("len() = %d, want 4", trie.len())
+	}
+}
+
+func TestBlocklistLoadAndReload(t *testing.T) {
+	dir := t.TempDir()
+	datacenters := filepath.Join(dir, "datacenters.csv")
+	blocklist := filepath.Join(dir, "blocklist.txt")
+
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(datacenters, "cidr,asn,organisation\n203.0.113.0/24,AS64500,Example Hosting\nnot-a-range,AS1,Broken\n")
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	b := NewBlocklist(log, datacenters, "", blocklist, []string{"192.0.2.0/24"})
+
+	if label, ok := b.Lookup(netip.MustParseAddr("203.0.113.7")); !ok || label != "AS64500 Example Hosting" {
+		t.Fatalf("datacenter Lookup() = %q, %v", label, ok)
+	}
+	if _, ok := b.Lookup(netip.MustParseAddr("192.0.2.1")); !ok {
+		t.Fatal("static range should be blocked")
+	}
+	if _, ok := b.Lookup(netip.MustParseAddr("198.51.100.1")); ok {
+		t.Fatal("unlisted address should not be blocked")
+	}
+	if b.changed() {
+		t.Fatal("unchanged files reported as changed")
+	}
+
+	write(blocklist, "# manual\n198.51.100.0/24\n")
+	if !b.changed() {
+		t.Fatal("new blocklist file not detected")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go b.watch(10*time.Millisecond, stop)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if label, ok := b.Lookup(netip.MustParseAddr("198.51.100.1")); ok && label == blocklistLabel {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("blocklist not reloaded after file change")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBlocklistASNs(t *testing.T) {
+	dir := t.TempDir()
+	asns := filepath.Join(dir, "asns.csv")
+	blocklist := filepath.Join(dir, "blocklist.txt")
+
+	data := "cidr,asn,organisation\n203.0.113.0/24,AS64500,Example Hosting\n198.51.100.0/24,AS64501,Example ISP\n"
+	if err := os.WriteFile(asns, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blocklist, []byte("# hosting\nas64500\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	b := NewBlocklist(log, "", asns, blocklist, []string{"AS64502"})
+
+	tests := []struct {
+		addr  string
+		label string
+		ok    bool
+	}{
+		{"203.0.113.7", "AS64500 Example Hosting", true},
+		{"198.51.100.1", "", false},
+		{"192.0.2.1", "", false},
+	}
+	for _, tt := range tests {
+		if label, ok := b.Lookup(netip.MustParseAddr(tt.addr)); label != tt.label || ok != tt.ok {
+			t.Errorf("Lookup(%s) = %q, %v; want %q, %v", tt.addr, label, ok, tt.label, tt.ok)
+		}
+	}
+
+	for s, want := range map[string]bool{"AS64500": true, "as1": true, "AS": false, "64500": false, "AS64500x": false} {
+		if _, ok := parseASN(s); ok != want {
+			t.Errorf("parseASN(%q) = %v, want %v", s, ok, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by a guarded provider whose circuit breaker is
+// open after too many consecutive failures.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// guarded wraps a provider with a per-lookup timeout and a circuit breaker.
+// After threshold consecutive failures the provider is skipped for cooldown,
+// after which a single lookup is let through to probe whether it recovered.
+type guarded struct {
+	Provider
+
+	timeout   time.Duration
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// Guard wraps p with a per-lookup timeout and a circuit breaker that opens
+// after threshold consecutive failures for cooldown.
+func Guard(p Provider, timeout time.Duration, threshold int, cooldown time.Duration) Provider {
+	return &guarded{Provider: p, timeout: timeout, threshold: threshold, cooldown: cooldown}
+}
+
+// Check ...
+func (g *guarded) Check(ctx context.Context, addr netip.Addr) (Verdict, error) {
+	if !g.allow(time.Now()) {
+		return Verdict{}, fmt.Errorf("%s: %w", g.Name(), ErrCircuitOpen)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+
+	v, err := g.Provider.Check(lookupCtx, addr)
+	if err != nil && ctx.Err() != nil {
+		// The caller gave up, such as a player leaving mid-login, which
+		// says nothing about the provider.
+		g.abandon()
+	} else {
+		g.record(err, time.Now())
+	}
+
+	if err != nil {
+		return Verdict{}, fmt.Errorf("%s: %w", g.Name(), err)
+	}
+	v.Provider = g.Name()
+
+	return v, nil
+}
+
+// allow reports whether a lookup may be made now.
+func (g *guarded) allow(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.failures < g.threshold {
+		return true
+	}
+	if now.Before(g.openUntil) || g.probing {
+		return false
+	}
+	g.probing = true
+
+	return true
+}
+
+// abandon ends a lookup without counting its outcome, letting another probe
+// through if it was one.
+func (g *guarded) abandon() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.probing = false
+}
+
+// record updates the breaker with the outcome of a lookup. Rate limits are
+// not counted as failures: the provider is healthy, just busy, and tracks
+// its own reset time.
+func (g *guarded) record(err error, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.probing = false
+
+	switch {
+	case err == nil:
+		g.failures = 0
+	case errors.Is(err, ErrRateLimited):
+	default:
+		g.failures++
+		if g.failures >= g.threshold {
+			g.openUntil = now.Add(g.cooldown)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package vpn
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"maps"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
 )
 
 const (
@@ -19,7 +24,36 @@ const (
 	flushInterval = 5 * time.Second
 )
 
-// Cache stores IP -> isProxy results and persists them to disk.
+// Entry is a cached verdict for a single IP address. Times are Unix
+// timestamps in milliseconds.
+type Entry struct {
+	Proxy    bool   `json:"proxy"`
+	Provider string `json:"provider,omitempty"`
+	Isp      string `json:"isp,omitempty"`
+	Org      string `json:"org,omitempty"`
+	// FirstSeen is when the address was first checked, zero for entries
+	// migrated from the old format. LastChecked is when its verdict was
+	// last fetched from a provider and LastSeen when a player last joined
+	// from it.
+	FirstSeen   int64 `json:"first_seen"`
+	LastChecked int64 `json:"last_checked"`
+	LastSeen    int64 `json:"last_seen,omitempty"`
+}
+
+// seen returns when a player last joined from the address. Entries written
+// before joins were recorded fall back to when they were last checked.
+func (e Entry) seen() int64 {
+	if e.LastSeen == 0 {
+		return e.LastChecked
+	}
+
+	return e.LastSeen
+}
+
+// Cache stores IP verdicts and persists them to disk. Entries expire after
+// a TTL that differs for proxy and clean verdicts, so an address that was
+// once a VPN, or a residential address later reassigned to one, gets
+// re-checked. Addresses nobody joins from anymore are evicted.
 //
 // Writes are debounced: Set marks the cache dirty and signals a flusher
 // goroutine, which writes the snapshot at most once per flushInterval.
@@ -27,7 +61,10 @@ const (
 type Cache struct {
 	mu   sync.RWMutex
 	path string
-	data map[string]bool
+	data map[string]Entry
+
+	positiveTTL time.Duration
+	negativeTTL time.Duration
 
 	dirty    bool
 	flush    chan struct{}
@@ -37,14 +74,19 @@ type Cache struct {
 }
 
 // NewCache creates a cache instance backed by the given file path. If the
-// file exists, it will be loaded.
-func NewCache(path string) (*Cache, error) {
+// file exists, it will be loaded. Files in the old format, a plain map of
+// IP to proxy flag, are migrated: their verdicts are kept and treated as
+// checked at load time with an unknown first seen time, and the file is
+// rewritten in the new format.
+func NewCache(path string, positiveTTL, negativeTTL time.Duration) (*Cache, error) {
 	c := &Cache{
-		path:    path,
-		data:    make(map[string]bool),
-		flush:   make(chan struct{}, 1),
-		stop:    make(chan struct{}),
-		stopped: make(chan struct{}),
+		path:        path,
+		data:        make(map[string]Entry),
+		positiveTTL: positiveTTL,
+		negativeTTL: negativeTTL,
+		flush:       make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
 	}
 
 	if path == "" {
@@ -53,17 +95,12 @@ func NewCache(path string) (*Cache, error) {
 		return c, nil
 	}
 
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	switch {
 	case err == nil:
-		defer f.Close()
-
-		dec := json.NewDecoder(f)
-		if decErr := dec.Decode(&c.data); decErr != nil {
-			// Keep the file but start with an empty in-memory map; the
-			// next flush will rewrite a clean snapshot.
-			c.data = make(map[string]bool)
-		}
+		// On a decode error keep the file but start with an empty
+		// in-memory map; the next flush will rewrite a clean snapshot.
+		c.data, c.dirty = decodeEntries(data, time.Now())
 	case errors.Is(err, os.ErrNotExist):
 		_ = os.MkdirAll(filepath.Dir(path), defaultDirPerms)
 		_ = writeJSONFile(path, c.data)
@@ -73,39 +110,211 @@ func NewCache(path string) (*Cache, error) {
 
 	go c.flusher()
 
+	if c.dirty {
+		c.signalFlush()
+	}
+
 	return c, nil
 }
 
-// Get returns the cached value and whether it existed.
-func (c *Cache) Get(ip string) (bool, bool) {
+// decodeEntries decodes a cache file, migrating entries stored in the old
+// IP -> proxy flag format. It reports whether any entry was migrated.
+func decodeEntries(data []byte, now time.Time) (map[string]Entry, bool) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return make(map[string]Entry), false
+	}
+
+	entries := make(map[string]Entry, len(raw))
+	migrated := false
+
+	for ip, v := range raw {
+		var proxy bool
+		if err := json.Unmarshal(v, &proxy); err == nil {
+			entries[ip] = Entry{Proxy: proxy, LastChecked: now.UnixMilli()}
+			migrated = true
+
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(v, &e); err == nil {
+			entries[ip] = e
+		}
+	}
+
+	return entries, migrated
+}
+
+// Get returns the cached entry, fresh or not, and whether it existed.
+func (c *Cache) Get(ip string) (Entry, bool) {
 	if c == nil {
-		return false, false
+		return Entry{}, false
 	}
 
 	c.mu.RLock()
-	v, ok := c.data[ip]
+	e, ok := c.data[ip]
 	c.mu.RUnlock()
 
-	return v, ok
+	return e, ok
 }
 
-// Set stores the value and schedules a debounced disk write. Multiple Set
-// calls within flushInterval coalesce into a single write.
-func (c *Cache) Set(ip string, isProxy bool) {
+// Expired reports whether the entry's verdict is older than the TTL for
+// its kind of verdict. A zero TTL never expires.
+func (c *Cache) Expired(e Entry, now time.Time) bool {
+	ttl := c.negativeTTL
+	if e.Proxy {
+		ttl = c.positiveTTL
+	}
+	if ttl <= 0 {
+		return false
+	}
+
+	return now.Sub(time.UnixMilli(e.LastChecked)) >= ttl
+}
+
+// Set stores a fresh verdict and schedules a debounced disk write. Multiple
+// Set calls within flushInterval coalesce into a single write.
+func (c *Cache) Set(ip string, v Verdict) {
 	if c == nil || c.path == "" {
 		return
 	}
 
+	now := time.Now().UnixMilli()
+
 	c.mu.Lock()
 	if c.data == nil {
-		c.data = make(map[string]bool)
+		c.data = make(map[string]Entry)
 	}
-	c.data[ip] = isProxy
+	e, ok := c.data[ip]
+	if !ok {
+		e.FirstSeen, e.LastSeen = now, now
+	}
+	e.Proxy, e.Provider, e.Isp, e.Org = v.Proxy, v.Provider, v.Isp, v.Org
+	e.LastChecked = now
+	c.data[ip] = e
 	c.dirty = true
 	c.mu.Unlock()
 
-	// Non-blocking signal; the flusher will pick up the latest snapshot
-	// regardless of how many times we signal.
+	c.signalFlush()
+}
+
+// Seen records that a player joined from the address, keeping its entry
+// from being evicted.
+func (c *Cache) Seen(ip string, now time.Time) {
+	if c == nil || c.path == "" {
+		return
+	}
+
+	c.mu.Lock()
+	e, ok := c.data[ip]
+	if ok {
+		e.LastSeen = now.UnixMilli()
+		c.data[ip] = e
+		c.dirty = true
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.signalFlush()
+	}
+}
+
+// Evict removes the entries of addresses nobody joined from since before
+// and returns how many were removed.
+func (c *Cache) Evict(before time.Time) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	var n int
+	for ip, e := range c.data {
+		if e.seen() < before.UnixMilli() {
+			delete(c.data, ip)
+			n++
+		}
+	}
+	if n > 0 {
+		c.dirty = true
+	}
+	c.mu.Unlock()
+
+	if n > 0 {
+		c.signalFlush()
+	}
+
+	return n
+}
+
+// Expiring returns up to limit addresses whose entries have expired,
+// least recently checked first. Only addresses a player joined from at or
+// after seenSince are returned, so no provider quota is spent on addresses
+// that never come back.
+func (c *Cache) Expiring(now, seenSince time.Time, limit int) []string {
+	if c == nil || limit <= 0 {
+		return nil
+	}
+
+	type due struct {
+		ip      string
+		checked int64
+	}
+
+	var expired []due
+
+	c.mu.RLock()
+	for ip, e := range c.data {
+		if c.Expired(e, now) && e.seen() >= seenSince.UnixMilli() {
+			expired = append(expired, due{ip: ip, checked: e.LastChecked})
+		}
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(expired, func(a, b due) int {
+		return cmp.Compare(a.checked, b.checked)
+	})
+
+	ips := make([]string, 0, min(limit, len(expired)))
+	for _, d := range expired[:min(limit, len(expired))] {
+		ips = append(ips, d.ip)
+	}
+
+	return ips
+}
+
+// Purge removes the entries of every address inside p and returns how many
+// were removed.
+func (c *Cache) Purge(p netip.Prefix) int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	var n int
+	for ip := range c.data {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || !p.Contains(addr.Unmap()) {
+			continue
+		}
+		delete(c.data, ip)
+		n++
+	}
+	if n > 0 {
+		c.dirty = true
+	}
+	c.mu.Unlock()
+
+	if n > 0 {
+		c.signalFlush()
+	}
+
+	return n
+}
+
+// signalFlush wakes the flusher without blocking; it picks up the latest
+// snapshot regardless of how many times it is signalled.
+func (c *Cache) signalFlush() {
 	select {
 	case c.flush <- struct{}{}:
 	default:
@@ -164,7 +373,7 @@ func (c *Cache) writeIfDirty() {
 
 		return
 	}
-	snapshot := make(map[string]bool, len(c.data))
+	snapshot := make(map[string]Entry, len(c.data))
 	maps.Copy(snapshot, c.data)
 	c.dirty = false
 	c.mu.Unlock()
@@ -186,24 +395,13 @@ func (c *Cache) markDirty() {
 	c.mu.Unlock()
 }
 
+// writeJSONFile writes v to path as indented JSON, replacing the file
+// atomically.
 func writeJSONFile(path string, v any) error {
-	tmp := path + ".tmp"
-	f, err := os.Create(tmp)
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
 		return err
 	}
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	err = enc.Encode(v)
-	cerr := f.Close()
-	if err == nil {
-		err = cerr
-	}
-	if err != nil {
-		_ = os.Remove(tmp)
-
-		return err
-	}
 
-	return os.Rename(tmp, path)
+	return util.WriteFileAtomic(path, append(data, '\n'))
 }
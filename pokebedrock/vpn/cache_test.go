@@ -0,0 +1,138 @@
+package vpn
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheMigratesLegacyFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vpnResults.json")
+	if err := os.WriteFile(path, []byte(`{"203.0.113.7":true,"198.51.100.1":false}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCache(path, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, want := range map[string]bool{"203.0.113.7": true, "198.51.100.1": false} {
+		e, ok := c.Get(ip)
+		if !ok || e.Proxy != want || e.LastChecked == 0 || e.FirstSeen != 0 {
+			t.Errorf("Get(%s) = %+v, %v; want proxy %v", ip, e, ok, want)
+		}
+	}
+	c.Stop()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries map[string]Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("cache not rewritten in new format: %v\n%s", err, data)
+	}
+	if !entries["203.0.113.7"].Proxy || len(entries) != 2 {
+		t.Fatalf("migrated entries = %+v", entries)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, err := NewCache("", time.Hour, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	checked := now.Add(-2 * time.Hour).UnixMilli()
+
+	if !c.Expired(Entry{Proxy: true, LastChecked: checked}, now) {
+		t.Error("proxy verdict older than positive TTL should expire")
+	}
+	if c.Expired(Entry{Proxy: false, LastChecked: checked}, now) {
+		t.Error("clean verdict younger than negative TTL should not expire")
+	}
+
+	never, _ := NewCache("", 0, 0)
+	if never.Expired(Entry{Proxy: true}, now) {
+		t.Error("zero TTL should never expire")
+	}
+}
+
+func TestCacheSetAndExpiring(t *testing.T) {
+	c, err := NewCache(filepath.Join(t.TempDir(), "cache.json"), time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	c.Set("203.0.113.7", Verdict{Proxy: true, Provider: "ip-api", Isp: "Hosting Ltd"})
+	first, _ := c.Get("203.0.113.7")
+
+	c.Set("203.0.113.7", Verdict{Proxy: false, Provider: "proxycheck"})
+	e, _ := c.Get("203.0.113.7")
+	if e.Proxy || e.Provider != "proxycheck" || e.FirstSeen != first.FirstSeen {
+		t.Fatalf("Set() = %+v, want updated verdict keeping first seen %d", e, first.FirstSeen)
+	}
+
+	c.Set("198.51.100.1", Verdict{})
+	c.mu.Lock()
+	old := c.data["198.51.100.1"]
+	old.LastChecked = time.Now().Add(-3 * time.Hour).UnixMilli()
+	c.data["198.51.100.1"] = old
+	older := c.data["203.0.113.7"]
+	older.LastChecked = time.Now().Add(-2 * time.Hour).UnixMilli()
+	c.data["203.0.113.7"] = older
+	c.mu.Unlock()
+
+	got := c.Expiring(time.Now(), time.Time{}, 5)
+	if want := []string{"198.51.100.1", "203.0.113.7"}; !slices.Equal(got, want) {
+		t.Fatalf("Expiring() = %v, want %v", got, want)
+	}
+	if got := c.Expiring(time.Now(), time.Time{}, 1); len(got) != 1 {
+		t.Fatalf("Expiring(limit 1) = %v", got)
+	}
+}
+
+func TestCacheSeenAndEvict(t *testing.T) {
+	c, err := NewCache(filepath.Join(t.TempDir(), "cache.json"), time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	now := time.Now()
+	c.Set("203.0.113.7", Verdict{Proxy: true})
+	c.Set("198.51.100.1", Verdict{})
+
+	// Both verdicts expired, but only the first address was joined from
+	// recently.
+	c.mu.Lock()
+	for ip, seen := range map[string]time.Duration{"203.0.113.7": time.Hour, "198.51.100.1": 40 * 24 * time.Hour} {
+		e := c.data[ip]
+		e.LastChecked = now.Add(-2 * time.Hour).UnixMilli()
+		e.LastSeen = now.Add(-seen).UnixMilli()
+		c.data[ip] = e
+	}
+	c.mu.Unlock()
+
+	if got := c.Expiring(now, now.Add(-24*time.Hour), 5); !slices.Equal(got, []string{"203.0.113.7"}) {
+		t.Fatalf("Expiring() = %v, want only the recently seen address", got)
+	}
+
+	if n := c.Evict(now.Add(-30 * 24 * time.Hour)); n != 1 {
+		t.Fatalf("Evict() = %d, want 1", n)
+	}
+	if _, ok := c.Get("198.51.100.1"); ok {
+		t.Fatal("unseen address not evicted")
+	}
+
+	c.Seen("203.0.113.7", now)
+	if e, _ := c.Get("203.0.113.7"); e.LastSeen != now.UnixMilli() {
+		t.Fatalf("Seen() left last seen at %d", e.LastSeen)
+	}
+}
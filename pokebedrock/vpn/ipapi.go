@@ -0,0 +1,106 @@
+package vpn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
+)
+
+// IPAPI detects proxies with the ip-api.com JSON API.
+type IPAPI struct {
+	name   string
+	url    string
+	client *http.Client
+
+	mu             sync.Mutex
+	rateLimitReset time.Time
+}
+
+// NewIPAPI creates an ip-api provider querying the given base URL, e.g.
+// http://ip-api.com/json.
+func NewIPAPI(name, url string) *IPAPI {
+	return &IPAPI{name: name, url: strings.TrimSuffix(url, "/"), client: &http.Client{}}
+}
+
+// Name ...
+func (a *IPAPI) Name() string {
+	return a.name
+}
+
+// Check ...
+func (a *IPAPI) Check(ctx context.Context, addr netip.Addr) (Verdict, error) {
+	a.mu.Lock()
+	reset := a.rateLimitReset
+	a.mu.Unlock()
+
+	if time.Now().Before(reset) {
+		return Verdict{}, fmt.Errorf("%w, please wait until %v", ErrRateLimited, reset)
+	}
+
+	url := fmt.Sprintf("%s/%s?fields=status,message,proxy,isp,org", a.url, addr)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	response, err := a.client.Do(request)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	a.handleRateLimitHeaders(response.Header)
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return Verdict{}, fmt.Errorf("%w: rate limited by api", ErrRateLimited)
+	default:
+		return Verdict{}, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var m ResponseModel
+	if err := json.NewDecoder(response.Body).Decode(&m); err != nil {
+		return Verdict{}, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	if strings.EqualFold(m.Status, StatusFail) {
+		// Private and reserved ranges can't be proxies.
+		if strings.EqualFold(m.Message, "reserved range") || strings.EqualFold(m.Message, "private range") {
+			return Verdict{Proxy: false}, nil
+		}
+
+		return Verdict{}, fmt.Errorf("query failed: %s", m.Message)
+	}
+
+	return Verdict{Proxy: m.Proxy, Isp: m.Isp, Org: m.Org}, nil
+}
+
+// handleRateLimitHeaders handles the rate limit headers.
+func (a *IPAPI) handleRateLimitHeaders(header http.Header) {
+	requestsRemainingStr := header.Get("X-Rl")
+	timeToResetStr := header.Get("X-Ttl")
+
+	if requestsRemainingStr == "0" && timeToResetStr != "" {
+		ttl, err := strconv.Atoi(timeToResetStr)
+		if err != nil {
+			// couldn't parse header for whatever reason, just default to fallback wait time.
+			ttl = internal.DefaultTTL
+		}
+
+		a.mu.Lock()
+		a.rateLimitReset = time.Now().Add(time.Duration(ttl) * time.Second)
+		a.mu.Unlock()
+		slog.Default().Warn("rate limit reached. waiting for reset.", "provider", a.name, "ttl_seconds", ttl)
+	}
+}
@@ -0,0 +1,90 @@
+package vpn
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// List is an offline provider that flags addresses inside a fixed set of
+// CIDR ranges as proxies and everything else as clean. It never fails, so it
+// makes a good last resort behind network providers.
+type List struct {
+	name     string
+	prefixes []netip.Prefix
+}
+
+// NewList creates an offline provider from the given ranges.
+func NewList(name string, prefixes []netip.Prefix) *List {
+	return &List{name: name, prefixes: prefixes}
+}
+
+// LoadList reads an offline provider from a file of CIDR ranges or single
+// addresses, one per line. Blank lines and lines starting with # are skipped.
+func LoadList(name, path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open vpn list: %w", err)
+	}
+	defer f.Close()
+
+	var prefixes []netip.Prefix
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		s := strings.TrimSpace(sc.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+
+		p, err := parsePrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		prefixes = append(prefixes, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read vpn list: %w", err)
+	}
+
+	return NewList(name, prefixes), nil
+}
+
+// parsePrefix parses a CIDR range or a single address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+
+	return p.Masked(), nil
+}
+
+// Name ...
+func (l *List) Name() string {
+	return l.name
+}
+
+// Check ...
+func (l *List) Check(_ context.Context, addr netip.Addr) (Verdict, error) {
+	addr = addr.Unmap()
+	for _, p := range l.prefixes {
+		if p.Contains(addr) {
+			return Verdict{Proxy: true}, nil
+		}
+	}
+
+	return Verdict{Proxy: false}, nil
+}
@@ -0,0 +1,142 @@
+package vpn
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrConfigured is returned when removing a whitelist range that is defined
+// in the configuration rather than added at runtime.
+var ErrConfigured = errors.New("range is defined in the configuration")
+
+// whitelisted returns the whitelist range, from the configuration or added
+// at runtime, containing addr.
+func (s *Service) whitelisted(addr netip.Addr) (netip.Prefix, bool) {
+	addr = addr.Unmap()
+	for _, p := range s.whitelist {
+		if p.Contains(addr) {
+			return p, true
+		}
+	}
+
+	return s.overrides.whitelisted(addr)
+}
+
+// Whitelist returns every whitelisted range: those from the configuration
+// followed by those added at runtime.
+func (s *Service) Whitelist() (configured, runtime []netip.Prefix) {
+	return slices.Clone(s.whitelist), s.overrides.whitelistRanges()
+}
+
+// AddWhitelist whitelists a CIDR range or single address. The change is
+// persisted and applies to the next check. It reports whether the range
+// was not whitelisted yet.
+func (s *Service) AddWhitelist(cidr string) (netip.Prefix, bool, error) {
+	p, err := parsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return netip.Prefix{}, false, fmt.Errorf("invalid range %q: %w", cidr, err)
+	}
+	if slices.Contains(s.whitelist, p) {
+		return p, false, nil
+	}
+
+	return p, s.overrides.addWhitelist(p), nil
+}
+
+// RemoveWhitelist removes a range added at runtime. Ranges from the
+// configuration can't be removed and return ErrConfigured.
+func (s *Service) RemoveWhitelist(cidr string) (netip.Prefix, bool, error) {
+	p, err := parsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return netip.Prefix{}, false, fmt.Errorf("invalid range %q: %w", cidr, err)
+	}
+	if slices.Contains(s.whitelist, p) {
+		return p, false, ErrConfigured
+	}
+
+	return p, s.overrides.removeWhitelist(p), nil
+}
+
+// Lookup describes what the service knows about an address without asking
+// any provider.
+type Lookup struct {
+	IP string `json:"ip"`
+	// Whitelisted is the whitelist range containing the address, if any.
+	Whitelisted string `json:"whitelisted,omitempty"`
+	// Blocklisted is the label of the blocked range containing the
+	// address, if any.
+	Blocklisted string `json:"blocklisted,omitempty"`
+	// Cached is the cached verdict, and Expired whether it is due to be
+	// checked again.
+	Cached  *Entry `json:"cached,omitempty"`
+	Expired bool   `json:"expired,omitempty"`
+}
+
+// Lookup returns the whitelist, blocklist and cache state of an address.
+func (s *Service) Lookup(ip string) (Lookup, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return Lookup{}, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	l := Lookup{IP: addr.String()}
+	if p, ok := s.whitelisted(addr); ok {
+		l.Whitelisted = p.String()
+	}
+	if label, ok := s.blocklist.Lookup(addr); ok {
+		l.Blocklisted = label
+	}
+	if e, ok := s.cache.Get(l.IP); ok {
+		l.Cached = &e
+		l.Expired = s.cache.Expired(e, time.Now())
+	}
+
+	return l, nil
+}
+
+// Purge removes the cached verdicts of an address or every address in a
+// CIDR range, so they are checked again on the next join. It returns how
+// many entries were removed.
+func (s *Service) Purge(target string) (int, error) {
+	p, err := parsePrefix(strings.TrimSpace(target))
+	if err != nil {
+		return 0, fmt.Errorf("invalid address or range %q: %w", target, err)
+	}
+
+	return s.cache.Purge(p), nil
+}
+
+// Bypassed reports whether the account with the XUID may connect through a
+// VPN or proxy.
+func (s *Service) Bypassed(xuid string) bool {
+	_, ok := s.overrides.bypassOf(xuid)
+
+	return ok
+}
+
+// Bypasses returns every VPN bypass keyed by XUID.
+func (s *Service) Bypasses() map[string]Bypass {
+	return s.overrides.bypasses()
+}
+
+// GrantBypass lets the account with the XUID connect through a VPN or
+// proxy. The change is persisted and applies to the next login.
+func (s *Service) GrantBypass(xuid, name, grantedBy string) error {
+	if xuid = strings.TrimSpace(xuid); xuid == "" {
+		return fmt.Errorf("xuid is required")
+	}
+
+	s.overrides.grant(xuid, Bypass{Name: name, GrantedBy: grantedBy, Granted: time.Now().UnixMilli()})
+
+	return nil
+}
+
+// RevokeBypass removes the VPN bypass of the account with the XUID and
+// reports whether it had one.
+func (s *Service) RevokeBypass(xuid string) bool {
+	return s.overrides.revoke(strings.TrimSpace(xuid))
+}
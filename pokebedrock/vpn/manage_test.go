@@ -0,0 +1,88 @@
+package vpn
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, dir string) *Service {
+	t.Helper()
+
+	NewService(slog.New(slog.NewTextHandler(io.Discard, nil)), Config{
+		CachePath:     filepath.Join(dir, "cache.json"),
+		OverridesPath: filepath.Join(dir, "overrides.json"),
+		Whitelist:     []string{"45.230.64.0/22"},
+		PositiveTTL:   time.Hour,
+		NegativeTTL:   time.Hour,
+	})
+
+	return GlobalService()
+}
+
+func TestServiceRuntimeOverridesPersist(t *testing.T) {
+	dir := t.TempDir()
+	s := newTestService(t, dir)
+
+	if _, added, err := s.AddWhitelist("198.51.100.0/24"); err != nil || !added {
+		t.Fatalf("AddWhitelist() = %v, %v", added, err)
+	}
+	if _, added, _ := s.AddWhitelist("198.51.100.0/24"); added {
+		t.Fatal("adding the same range twice should report false")
+	}
+	if _, _, err := s.RemoveWhitelist("45.230.64.0/22"); !errors.Is(err, ErrConfigured) {
+		t.Fatalf("removing configured range: err = %v, want ErrConfigured", err)
+	}
+	if err := s.GrantBypass("2535400000000000", "Player", "Staff"); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := s.CheckIP(t.Context(), "198.51.100.7")
+	if err != nil || m.Proxy {
+		t.Fatalf("whitelisted CheckIP() = %+v, %v", m, err)
+	}
+	s.Stop()
+
+	s = newTestService(t, dir)
+	defer s.Stop()
+
+	if l, _ := s.Lookup("198.51.100.7"); l.Whitelisted != "198.51.100.0/24" {
+		t.Fatalf("runtime whitelist not persisted: %+v", l)
+	}
+	if !s.Bypassed("2535400000000000") {
+		t.Fatal("bypass not persisted")
+	}
+	if !s.RevokeBypass("2535400000000000") || s.Bypassed("2535400000000000") {
+		t.Fatal("RevokeBypass() did not remove the bypass")
+	}
+	if _, removed, err := s.RemoveWhitelist("198.51.100.0/24"); err != nil || !removed {
+		t.Fatalf("RemoveWhitelist() = %v, %v", removed, err)
+	}
+}
+
+func TestServicePurge(t *testing.T) {
+	s := newTestService(t, t.TempDir())
+	defer s.Stop()
+
+	s.cache.Set("203.0.113.7", Verdict{Proxy: true, Provider: "ip-api"})
+	s.cache.Set("203.0.113.8", Verdict{Proxy: true})
+	s.cache.Set("192.0.2.1", Verdict{})
+
+	l, err := s.Lookup("203.0.113.7")
+	if err != nil || l.Cached == nil || !l.Cached.Proxy || l.Cached.Provider != "ip-api" {
+		t.Fatalf("Lookup() = %+v, %v", l, err)
+	}
+
+	if n, err := s.Purge("203.0.113.0/24"); err != nil || n != 2 {
+		t.Fatalf("Purge(range) = %d, %v; want 2", n, err)
+	}
+	if n, _ := s.Purge("192.0.2.1"); n != 1 {
+		t.Fatalf("Purge(ip) = %d, want 1", n)
+	}
+	if _, err := s.Purge("nonsense"); err == nil {
+		t.Fatal("expected error for invalid purge target")
+	}
+}
@@ -17,4 +17,7 @@ type ResponseModel struct {
 	// they are not cached.
 	Isp string `json:"isp,omitempty"`
 	Org string `json:"org,omitempty"`
+	// Provider is the detection provider that produced the verdict. Empty
+	// for whitelisted and cached results.
+	Provider string `json:"-"`
 }
@@ -0,0 +1,252 @@
+package vpn
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// Bypass is a VPN bypass granted to a single account by staff.
+type Bypass struct {
+	Name      string `json:"name,omitempty"`
+	GrantedBy string `json:"granted_by,omitempty"`
+	// Granted is a Unix timestamp in milliseconds.
+	Granted int64 `json:"granted"`
+}
+
+// overridesFile is the on-disk format of the runtime overrides.
+type overridesFile struct {
+	Whitelist []string          `json:"whitelist"`
+	Bypass    map[string]Bypass `json:"bypass"`
+}
+
+// overrides holds the whitelist ranges and per-XUID bypasses staff manage
+// at runtime, on top of those in the configuration. Changes are written to
+// disk on a background goroutine.
+type overrides struct {
+	log  *slog.Logger
+	path string
+
+	mu        sync.RWMutex
+	whitelist []netip.Prefix
+	bypass    map[string]Bypass
+	closed    bool
+
+	saveCh   chan []byte
+	saveDone chan struct{}
+}
+
+// loadOverrides reads the overrides stored at path. A missing file starts
+// empty; an empty path keeps overrides in memory only.
+func loadOverrides(log *slog.Logger, path string) *overrides {
+	o := &overrides{
+		log:      log,
+		path:     path,
+		bypass:   make(map[string]Bypass),
+		saveCh:   make(chan []byte, 1),
+		saveDone: make(chan struct{}),
+	}
+
+	if path != "" {
+		if err := o.load(); err != nil {
+			log.Error("failed to load vpn overrides", "path", path, "error", err)
+		}
+	}
+
+	go o.saveLoop()
+
+	return o
+}
+
+// load reads the overrides file.
+func (o *overrides) load() error {
+	data, err := os.ReadFile(o.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f overridesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+
+	for _, c := range f.Whitelist {
+		p, err := parsePrefix(c)
+		if err != nil {
+			o.log.Warn("ignoring invalid vpn whitelist override", "cidr", c, "error", err)
+
+			continue
+		}
+		o.whitelist = append(o.whitelist, p)
+	}
+	if f.Bypass != nil {
+		o.bypass = f.Bypass
+	}
+
+	return nil
+}
+
+// whitelisted returns the runtime whitelist range containing addr.
+func (o *overrides) whitelisted(addr netip.Addr) (netip.Prefix, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	for _, p := range o.whitelist {
+		if p.Contains(addr) {
+			return p, true
+		}
+	}
+
+	return netip.Prefix{}, false
+}
+
+// whitelistRanges returns the runtime whitelist ranges.
+func (o *overrides) whitelistRanges() []netip.Prefix {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return slices.Clone(o.whitelist)
+}
+
+// addWhitelist adds a range and reports whether it was new.
+func (o *overrides) addWhitelist(p netip.Prefix) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if slices.Contains(o.whitelist, p) {
+		return false
+	}
+	o.whitelist = append(o.whitelist, p)
+	o.queueSaveLocked()
+
+	return true
+}
+
+// removeWhitelist removes a range and reports whether it was present.
+func (o *overrides) removeWhitelist(p netip.Prefix) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	i := slices.Index(o.whitelist, p)
+	if i < 0 {
+		return false
+	}
+	o.whitelist = slices.Delete(o.whitelist, i, i+1)
+	o.queueSaveLocked()
+
+	return true
+}
+
+// bypassOf returns the bypass granted to the XUID.
+func (o *overrides) bypassOf(xuid string) (Bypass, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	b, ok := o.bypass[xuid]
+
+	return b, ok
+}
+
+// bypasses returns every granted bypass keyed by XUID.
+func (o *overrides) bypasses() map[string]Bypass {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return maps.Clone(o.bypass)
+}
+
+// grant stores a bypass for the XUID, replacing any existing one.
+func (o *overrides) grant(xuid string, b Bypass) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.bypass[xuid] = b
+	o.queueSaveLocked()
+}
+
+// revoke removes the bypass of the XUID and reports whether it existed.
+func (o *overrides) revoke(xuid string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.bypass[xuid]; !ok {
+		return false
+	}
+	delete(o.bypass, xuid)
+	o.queueSaveLocked()
+
+	return true
+}
+
+// queueSaveLocked snapshots the overrides while o.mu is held and coalesces
+// disk writes on the background save loop.
+func (o *overrides) queueSaveLocked() {
+	if o.closed || o.path == "" {
+		return
+	}
+
+	f := overridesFile{Whitelist: make([]string, 0, len(o.whitelist)), Bypass: o.bypass}
+	for _, p := range o.whitelist {
+		f.Whitelist = append(f.Whitelist, p.String())
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return
+	}
+
+	select {
+	case o.saveCh <- data:
+	default:
+		select {
+		case <-o.saveCh:
+		default:
+		}
+		select {
+		case o.saveCh <- data:
+		default:
+		}
+	}
+}
+
+// saveLoop writes queued snapshots without blocking the world owner.
+func (o *overrides) saveLoop() {
+	defer close(o.saveDone)
+
+	for data := range o.saveCh {
+		if err := os.MkdirAll(filepath.Dir(o.path), defaultDirPerms); err != nil {
+			o.log.Error("failed to create vpn overrides directory", "path", filepath.Dir(o.path), "error", err)
+
+			continue
+		}
+		if err := util.WriteFileAtomic(o.path, data); err != nil {
+			o.log.Error("failed to write vpn overrides", "path", o.path, "error", err)
+		}
+	}
+}
+
+// close flushes queued snapshots to disk.
+func (o *overrides) close() {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+
+		return
+	}
+	o.closed = true
+	close(o.saveCh)
+	o.mu.Unlock()
+
+	<-o.saveDone
+}
@@ -0,0 +1,137 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Policy decides how the verdicts of several providers are combined.
+type Policy string
+
+// Policy constants.
+const (
+	// PolicyPrimary asks providers in order and uses the first answer, so
+	// later providers are only fallbacks for when earlier ones fail.
+	PolicyPrimary Policy = "primary"
+	// PolicyAnyOf asks every provider and flags the address if any of them
+	// does.
+	PolicyAnyOf Policy = "any"
+	// PolicyMajority asks every provider and flags the address if more than
+	// half of those that answered do.
+	PolicyMajority Policy = "majority"
+)
+
+// ParsePolicy parses a policy name. Empty defaults to PolicyPrimary.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return PolicyPrimary, nil
+	case PolicyPrimary, PolicyAnyOf, PolicyMajority:
+		return p, nil
+	case "any-of", "anyof":
+		return PolicyAnyOf, nil
+	default:
+		return "", fmt.Errorf("unknown vpn policy %q", s)
+	}
+}
+
+// Detector combines the verdicts of several providers according to a
+// Policy.
+type Detector struct {
+	policy    Policy
+	providers []Provider
+}
+
+// NewDetector creates a detector asking the given providers, in order of
+// preference.
+func NewDetector(policy Policy, providers ...Provider) *Detector {
+	return &Detector{policy: policy, providers: providers}
+}
+
+// Check returns the combined verdict for addr. It fails only if no provider
+// answered; the error wraps ErrRateLimited if every provider was rate
+// limited.
+func (d *Detector) Check(ctx context.Context, addr netip.Addr) (Verdict, error) {
+	if len(d.providers) == 0 {
+		return Verdict{}, errors.New("no vpn providers configured")
+	}
+
+	if d.policy == PolicyPrimary {
+		var errs []error
+
+		for _, p := range d.providers {
+			v, err := p.Check(ctx, addr)
+			if err == nil {
+				return v, nil
+			}
+			errs = append(errs, err)
+		}
+
+		return Verdict{}, combineErrors(errs)
+	}
+
+	type result struct {
+		v   Verdict
+		err error
+	}
+
+	results := make(chan result, len(d.providers))
+	for _, p := range d.providers {
+		go func() {
+			v, err := p.Check(ctx, addr)
+			results <- result{v: v, err: err}
+		}()
+	}
+
+	var (
+		errs             []error
+		answers, proxies int
+		flagged, clean   Verdict
+		haveClean        bool
+	)
+
+	for range d.providers {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+
+			continue
+		}
+
+		answers++
+		if r.v.Proxy {
+			if proxies == 0 {
+				flagged = r.v
+			}
+			proxies++
+		} else if !haveClean {
+			clean, haveClean = r.v, true
+		}
+	}
+
+	if answers == 0 {
+		return Verdict{}, combineErrors(errs)
+	}
+
+	if proxies > 0 && (d.policy == PolicyAnyOf || proxies*2 > answers) {
+		return flagged, nil
+	}
+
+	return clean, nil
+}
+
+// combineErrors joins the errors of every provider, wrapping ErrRateLimited
+// when they were all rate limited so callers can fail open.
+func combineErrors(errs []error) error {
+	err := errors.Join(errs...)
+	for _, e := range errs {
+		if !errors.Is(e, ErrRateLimited) {
+			return fmt.Errorf("all vpn providers failed: %v", err)
+		}
+	}
+
+	return fmt.Errorf("%w: %w", ErrRateLimited, err)
+}
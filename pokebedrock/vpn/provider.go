@@ -0,0 +1,111 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/util"
+)
+
+// ErrRateLimited is returned when a provider, or every provider of a
+// detector, is rate limited and can't answer right now.
+var ErrRateLimited = errors.New("rate limit active")
+
+// Verdict is a provider's answer on whether an address is a VPN or proxy.
+type Verdict struct {
+	Proxy bool
+	// Isp and Org identify the connection's provider, when known. They are
+	// logged for denied connections so false positives can be audited.
+	Isp string
+	Org string
+	// Provider is the name of the provider that produced the verdict.
+	Provider string
+}
+
+// Provider detects whether an address belongs to a VPN or proxy.
+type Provider interface {
+	// Name returns the name of the provider used in logs and verdicts.
+	Name() string
+	// Check looks up the address. Implementations must respect ctx.
+	Check(ctx context.Context, addr netip.Addr) (Verdict, error)
+}
+
+// Provider types accepted in ProviderConfig.Type.
+const (
+	ProviderIPAPI      = "ip-api"
+	ProviderProxyCheck = "proxycheck"
+	ProviderList       = "list"
+)
+
+// ProviderConfig configures a single detection provider.
+type ProviderConfig struct {
+	// Name identifies the provider in logs. Defaults to Type.
+	Name string
+	// Type is one of "ip-api", "proxycheck" or "list".
+	Type string
+	// URL is the base URL of HTTP providers.
+	URL string
+	// Key is the API key of the proxycheck provider, if any.
+	Key string
+	// Path is the file of CIDR ranges read by the list provider.
+	Path string
+	// Timeout bounds a single lookup. Defaults to one second.
+	Timeout util.Duration
+	// FailureThreshold is the number of consecutive failures that open the
+	// provider's circuit breaker; Cooldown is how long it stays open.
+	FailureThreshold int
+	Cooldown         util.Duration
+}
+
+const (
+	defaultProviderTimeout  = time.Second
+	defaultFailureThreshold = 5
+	defaultBreakerCooldown  = time.Minute
+)
+
+// NewProvider creates the provider described by conf, wrapped with its
+// timeout and circuit breaker.
+func NewProvider(conf ProviderConfig) (Provider, error) {
+	var (
+		p   Provider
+		err error
+	)
+
+	name := conf.Name
+	if name == "" {
+		name = conf.Type
+	}
+
+	switch strings.ToLower(conf.Type) {
+	case ProviderIPAPI:
+		p = NewIPAPI(name, conf.URL)
+	case ProviderProxyCheck:
+		p = NewProxyCheck(name, conf.URL, conf.Key)
+	case ProviderList:
+		p, err = LoadList(name, conf.Path)
+	default:
+		return nil, fmt.Errorf("unknown vpn provider type %q", conf.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := time.Duration(conf.Timeout)
+	if timeout <= 0 {
+		timeout = defaultProviderTimeout
+	}
+	threshold := conf.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	cooldown := time.Duration(conf.Cooldown)
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	return Guard(p, timeout, threshold, cooldown), nil
+}
@@ -0,0 +1,262 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testAddr = netip.MustParseAddr("203.0.113.7")
+
+func TestIPAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/json/") {
+		case "203.0.113.7":
+			fmt.Fprint(w, `{"status":"success","proxy":true,"isp":"Hosting Ltd","org":"VPN Co"}`)
+		case "10.0.0.1":
+			fmt.Fprint(w, `{"status":"fail","message":"private range"}`)
+		case "198.51.100.1":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, `{"status":"fail","message":"invalid query"}`)
+		}
+	}))
+	defer srv.Close()
+
+	p := NewIPAPI("ip-api", srv.URL+"/json")
+
+	v, err := p.Check(context.Background(), testAddr)
+	if err != nil || !v.Proxy || v.Isp != "Hosting Ltd" || v.Org != "VPN Co" {
+		t.Fatalf("Check() = %+v, %v", v, err)
+	}
+
+	if v, err := p.Check(context.Background(), netip.MustParseAddr("10.0.0.1")); err != nil || v.Proxy {
+		t.Fatalf("private range: Check() = %+v, %v", v, err)
+	}
+
+	if _, err := p.Check(context.Background(), netip.MustParseAddr("198.51.100.1")); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("429: err = %v, want ErrRateLimited", err)
+	}
+
+	if _, err := p.Check(context.Background(), netip.MustParseAddr("192.0.2.1")); err == nil {
+		t.Fatal("failed query: expected error")
+	}
+}
+
+func TestIPAPIRateLimitHeaders(t *testing.T) {
+	var hits atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		w.Header().Set("X-Rl", "0")
+		w.Header().Set("X-Ttl", "60")
+		fmt.Fprint(w, `{"status":"success","proxy":false}`)
+	}))
+	defer srv.Close()
+
+	p := NewIPAPI("ip-api", srv.URL)
+	if _, err := p.Check(context.Background(), testAddr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Check(context.Background(), testAddr); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Fatalf("api hit %d times while rate limited, want 1", n)
+	}
+}
+
+func TestProxyCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "k" {
+			fmt.Fprint(w, `{"status":"denied","message":"Free query limit reached"}`)
+
+			return
+		}
+		ip := strings.TrimPrefix(r.URL.Path, "/v2/")
+		fmt.Fprintf(w, `{"status":"ok","%s":{"proxy":"yes","type":"VPN","provider":"Hosting Ltd","organisation":"VPN Co"}}`, ip)
+	}))
+	defer srv.Close()
+
+	v, err := NewProxyCheck("proxycheck", srv.URL+"/v2", "k").Check(context.Background(), testAddr)
+	if err != nil || !v.Proxy || v.Isp != "Hosting Ltd" || v.Org != "VPN Co" {
+		t.Fatalf("Check() = %+v, %v", v, err)
+	}
+
+	if _, err := NewProxyCheck("proxycheck", srv.URL+"/v2", "").Check(context.Background(), testAddr); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("denied: err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestLoadList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges.txt")
+	if err := os.WriteFile(path, []byte("# datacenters\n203.0.113.0/24\n\n198.51.100.9\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := LoadList("offline", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ip, want := range map[string]bool{"203.0.113.7": true, "198.51.100.9": true, "198.51.100.10": false} {
+		v, err := l.Check(context.Background(), netip.MustParseAddr(ip))
+		if err != nil || v.Proxy != want {
+			t.Errorf("Check(%s) = %+v, %v; want proxy %v", ip, v, err, want)
+		}
+	}
+}
+
+// stub is a provider with a fixed answer.
+type stub struct {
+	name  string
+	proxy bool
+	err   error
+	calls atomic.Int32
+}
+
+func (s *stub) Name() string { return s.name }
+
+func (s *stub) Check(context.Context, netip.Addr) (Verdict, error) {
+	s.calls.Add(1)
+
+	return Verdict{Proxy: s.proxy, Provider: s.name}, s.err
+}
+
+func TestDetectorPolicies(t *testing.T) {
+	failing := errors.New("down")
+
+	tests := []struct {
+		name      string
+		policy    Policy
+		providers []*stub
+		proxy     bool
+		provider  string
+		wantErr   bool
+	}{
+		{"primary uses first", PolicyPrimary, []*stub{{name: "a"}, {name: "b", proxy: true}}, false, "a", false},
+		{"primary falls back", PolicyPrimary, []*stub{{name: "a", err: failing}, {name: "b", proxy: true}}, true, "b", false},
+		{"primary all fail", PolicyPrimary, []*stub{{name: "a", err: failing}}, false, "", true},
+		{"any flags", PolicyAnyOf, []*stub{{name: "a"}, {name: "b", proxy: true}, {name: "c"}}, true, "b", false},
+		{"any clean", PolicyAnyOf, []*stub{{name: "a"}, {name: "b", err: failing}}, false, "a", false},
+		{"majority minority", PolicyMajority, []*stub{{name: "a"}, {name: "b", proxy: true}, {name: "c"}}, false, "", false},
+		{"majority flags", PolicyMajority, []*stub{{name: "a", proxy: true}, {name: "b", proxy: true}, {name: "c"}}, true, "", false},
+		{"majority ignores failures", PolicyMajority, []*stub{{name: "a", proxy: true}, {name: "b", err: failing}}, true, "a", false},
+		{"majority tie is clean", PolicyMajority, []*stub{{name: "a", proxy: true}, {name: "b"}}, false, "b", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providers := make([]Provider, len(tt.providers))
+			for i, p := range tt.providers {
+				providers[i] = p
+			}
+
+			v, err := NewDetector(tt.policy, providers...).Check(context.Background(), testAddr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if v.Proxy != tt.proxy {
+				t.Errorf("proxy = %v, want %v", v.Proxy, tt.proxy)
+			}
+			if tt.provider != "" && v.Provider != tt.provider {
+				t.Errorf("provider = %q, want %q", v.Provider, tt.provider)
+			}
+		})
+	}
+}
+
+func TestDetectorAllRateLimited(t *testing.T) {
+	d := NewDetector(PolicyPrimary, &stub{name: "a", err: ErrRateLimited}, &stub{name: "b", err: ErrRateLimited})
+	if _, err := d.Check(context.Background(), testAddr); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+
+	d = NewDetector(PolicyPrimary, &stub{name: "a", err: ErrRateLimited}, &stub{name: "b", err: errors.New("down")})
+	if _, err := d.Check(context.Background(), testAddr); errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, should not be ErrRateLimited when a provider is down", err)
+	}
+}
+
+func TestGuardTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	p := Guard(NewIPAPI("slow", srv.URL), 20*time.Millisecond, 1, time.Minute)
+
+	start := time.Now()
+	if _, err := p.Check(context.Background(), testAddr); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("lookup took %v, timeout not applied", elapsed)
+	}
+}
+
+func TestGuardCircuitBreaker(t *testing.T) {
+	s := &stub{name: "a", err: errors.New("down")}
+	g := Guard(s, time.Second, 2, 20*time.Millisecond)
+
+	for range 2 {
+		_, _ = g.Check(context.Background(), testAddr)
+	}
+	if _, err := g.Check(context.Background(), testAddr); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if n := s.calls.Load(); n != 2 {
+		t.Fatalf("provider called %d times, want 2", n)
+	}
+
+	// After the cooldown a probe is let through; success closes the circuit.
+	time.Sleep(30 * time.Millisecond)
+	s.err = nil
+	if v, err := g.Check(context.Background(), testAddr); err != nil || v.Provider != "a" {
+		t.Fatalf("probe: Check() = %+v, %v", v, err)
+	}
+	if _, err := g.Check(context.Background(), testAddr); err != nil {
+		t.Fatalf("after recovery: err = %v", err)
+	}
+}
+
+func TestGuardIgnoresRateLimits(t *testing.T) {
+	s := &stub{name: "a", err: ErrRateLimited}
+	g := Guard(s, time.Second, 1, time.Minute)
+
+	for range 3 {
+		if _, err := g.Check(context.Background(), testAddr); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("rate limits should not open the circuit")
+		}
+	}
+}
+
+func TestGuardIgnoresCancelledCallers(t *testing.T) {
+	s := &stub{name: "a", err: context.Canceled}
+	g := Guard(s, time.Second, 1, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 3 {
+		if _, err := g.Check(ctx, testAddr); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("lookups cancelled by the caller should not open the circuit")
+		}
+	}
+
+	s.err = nil
+	if _, err := g.Check(context.Background(), testAddr); err != nil {
+		t.Fatalf("err = %v after cancelled lookups, want nil", err)
+	}
+}
@@ -0,0 +1,100 @@
+package vpn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+// ProxyCheck detects proxies with a proxycheck.io style v2 API, which
+// answers GET <url>/<ip>?vpn=1&asn=1 with the verdict keyed by the address.
+type ProxyCheck struct {
+	name   string
+	url    string
+	key    string
+	client *http.Client
+}
+
+// NewProxyCheck creates a proxycheck provider querying the given base URL,
+// e.g. https://proxycheck.io/v2. The key may be empty for the free tier.
+func NewProxyCheck(name, url, key string) *ProxyCheck {
+	return &ProxyCheck{name: name, url: strings.TrimSuffix(url, "/"), key: key, client: &http.Client{}}
+}
+
+// proxyCheckAddress is the per-address part of a proxycheck response.
+type proxyCheckAddress struct {
+	Proxy        string `json:"proxy"`
+	Type         string `json:"type"`
+	Provider     string `json:"provider"`
+	Organisation string `json:"organisation"`
+}
+
+// Name ...
+func (c *ProxyCheck) Name() string {
+	return c.name
+}
+
+// Check ...
+func (c *ProxyCheck) Check(ctx context.Context, addr netip.Addr) (Verdict, error) {
+	q := url.Values{"vpn": {"1"}, "asn": {"1"}}
+	if c.key != "" {
+		q.Set("key", c.key)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/%s?%s", c.url, addr, q.Encode()), nil)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		return Verdict{}, fmt.Errorf("%w: rate limited by api", ErrRateLimited)
+	default:
+		return Verdict{}, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return Verdict{}, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	var status, message string
+	_ = json.Unmarshal(body["status"], &status)
+	_ = json.Unmarshal(body["message"], &message)
+
+	switch strings.ToLower(status) {
+	case "ok", "warning":
+	case "denied":
+		if strings.Contains(strings.ToLower(message), "limit") {
+			return Verdict{}, fmt.Errorf("%w: %s", ErrRateLimited, message)
+		}
+
+		return Verdict{}, fmt.Errorf("query denied: %s", message)
+	default:
+		return Verdict{}, fmt.Errorf("query failed: %s", message)
+	}
+
+	raw, ok := body[addr.String()]
+	if !ok {
+		return Verdict{}, fmt.Errorf("response did not include %s", addr)
+	}
+
+	var a proxyCheckAddress
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return Verdict{}, fmt.Errorf("failed to decode address result: %w", err)
+	}
+
+	return Verdict{Proxy: strings.EqualFold(a.Proxy, "yes"), Isp: a.Provider, Org: a.Organisation}, nil
+}
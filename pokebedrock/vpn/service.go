@@ -2,20 +2,14 @@ package vpn
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net"
-	"net/http"
 	"net/netip"
-	"strconv"
 	"strings"
-	"sync"
 	"sync/atomic"
 	"time"
-
-	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/internal"
 )
 
 // globalService ...
@@ -26,33 +20,75 @@ func GlobalService() *Service {
 	return globalService
 }
 
+// Config holds the settings of the VPN service.
+type Config struct {
+	// CachePath is the file verdicts are persisted in. Empty disables the
+	// cache.
+	CachePath string
+	// Whitelist holds CIDR ranges that are never treated as proxies.
+	Whitelist []string
+	// OverridesPath is the file runtime whitelist ranges and per-XUID
+	// bypasses managed by staff are persisted in.
+	OverridesPath string
+	// Blocklist holds CIDR ranges and ASNs that are always treated as
+	// proxies.
+	Blocklist []string
+	// DatacenterPath is a CSV dataset of datacenter ranges, AsnPath a CSV
+	// dataset mapping ranges to their ASN and BlocklistPath a user
+	// maintained file of blocked ranges and ASNs. All are checked before
+	// any provider and reloaded every ReloadInterval when they change.
+	DatacenterPath string
+	AsnPath        string
+	BlocklistPath  string
+	ReloadInterval time.Duration
+	// Policy decides how the verdicts of several providers are combined.
+	Policy string
+	// Providers are the detection providers, in order of preference.
+	Providers []ProviderConfig
+	// PositiveTTL and NegativeTTL are how long proxy and clean verdicts are
+	// cached for before they are checked again.
+	PositiveTTL time.Duration
+	NegativeTTL time.Duration
+	// RefreshInterval is how often expired cache entries are re-checked in
+	// the background, RefreshBatch entries at a time. A zero interval
+	// disables background refreshes.
+	RefreshInterval time.Duration
+	RefreshBatch    int
+	// RefreshSeenWithin limits background refreshes to addresses a player
+	// joined from within it. EvictAfter is how long the entry of an
+	// address nobody joins from is kept. Zero disables either limit.
+	RefreshSeenWithin time.Duration
+	EvictAfter        time.Duration
+}
+
 // Service is responsible for checking is a player connecting to the hub
 // is on a vpn connection or not.
 type Service struct {
-	url    string
 	closed atomic.Bool
 
-	client *http.Client
-	log    *slog.Logger
+	log       *slog.Logger
+	detector  *Detector
+	blocklist *Blocklist
 
-	rateLimitReset time.Time
-	mu             sync.Mutex
-
-	cache *Cache
+	cache     *Cache
+	overrides *overrides
+	stop      chan struct{}
 
 	// whitelist holds CIDR ranges that are never treated as proxies,
-	// regardless of what the upstream detection API (or a stale cache
-	// entry) says. Used for residential ISP blocks that ip-api
-	// misclassifies (common with CGNAT ranges).
+	// regardless of what the detection providers (or a stale cache
+	// entry) say. Used for residential ISP blocks that ip-api
+	// misclassifies (common with CGNAT ranges). Ranges staff add at
+	// runtime are kept in overrides.
 	whitelist []netip.Prefix
 }
 
 // NewService initializes a new global service instance with the provided
-// logger, URL, cache path and whitelisted CIDR ranges.
-func NewService(log *slog.Logger, url, cachePath string, whitelistCIDRs []string) {
-	whitelist := make([]netip.Prefix, 0, len(whitelistCIDRs))
+// logger and configuration. Invalid whitelist ranges and providers are
+// logged and skipped.
+func NewService(log *slog.Logger, conf Config) {
+	whitelist := make([]netip.Prefix, 0, len(conf.Whitelist))
 
-	for _, c := range whitelistCIDRs {
+	for _, c := range conf.Whitelist {
 		p, err := netip.ParsePrefix(strings.TrimSpace(c))
 		if err != nil {
 			log.Warn("ignoring invalid vpn whitelist cidr", "cidr", c, "error", err)
@@ -63,191 +99,169 @@ func NewService(log *slog.Logger, url, cachePath string, whitelistCIDRs []string
 		whitelist = append(whitelist, p.Masked())
 	}
 
+	policy, err := ParsePolicy(conf.Policy)
+	if err != nil {
+		log.Warn("falling back to primary vpn policy", "error", err)
+
+		policy = PolicyPrimary
+	}
+
+	providers := make([]Provider, 0, len(conf.Providers))
+	for _, pc := range conf.Providers {
+		p, err := NewProvider(pc)
+		if err != nil {
+			log.Warn("ignoring invalid vpn provider", "name", pc.Name, "type", pc.Type, "error", err)
+
+			continue
+		}
+		providers = append(providers, p)
+	}
+
 	globalService = &Service{
-		url: url,
-		client: &http.Client{
-			Timeout: requestTimeout,
-		},
 		log:       log,
+		detector:  NewDetector(policy, providers...),
+		blocklist: NewBlocklist(log, conf.DatacenterPath, conf.AsnPath, conf.BlocklistPath, conf.Blocklist),
 		whitelist: whitelist,
+		overrides: loadOverrides(log, conf.OverridesPath),
+		stop:      make(chan struct{}),
 	}
 
 	// Initialize cache (best-effort)
-	if cachePath != "" {
-		if c, err := NewCache(cachePath); err != nil {
+	if conf.CachePath != "" {
+		if c, err := NewCache(conf.CachePath, conf.PositiveTTL, conf.NegativeTTL); err != nil {
 			log.Warn("failed to initialize vpn cache", "error", err)
 		} else {
 			globalService.cache = c
 		}
 	}
-}
 
-const (
-	maxRetries     = 1
-	retryDelay     = 1 * time.Second
-	requestTimeout = 1 * time.Second
-)
+	if conf.ReloadInterval > 0 {
+		go globalService.blocklist.watch(conf.ReloadInterval, globalService.stop)
+	}
+	if globalService.cache != nil && conf.RefreshInterval > 0 && conf.RefreshBatch > 0 {
+		go globalService.refreshLoop(conf)
+	}
+}
 
 // CheckIP determines whether the provided IP address is associated with a VPN connection.
-func (s *Service) CheckIP(ip string) (*ResponseModel, error) {
+// The returned error wraps ErrRateLimited when no provider could answer
+// because of rate limits.
+func (s *Service) CheckIP(ctx context.Context, ip string) (*ResponseModel, error) {
 	addr, err := netip.ParseAddr(ip)
 	if err != nil {
 		return nil, fmt.Errorf("invalid IP address: %s", ip)
 	}
 
-	// Whitelisted ranges bypass both the cache and the API, so a stale
-	// cached proxy=true entry can never block a whitelisted ISP.
-	for _, p := range s.whitelist {
-		if p.Contains(addr) {
-			return &ResponseModel{Status: StatusSuccess, Proxy: false}, nil
-		}
-	}
-
-	// Fast path: cached result
-	if s.cache != nil {
-		if cached, ok := s.cache.Get(ip); ok {
-			s.log.Info("VPN check result", "ip", ip, "proxy", cached)
-			return &ResponseModel{Status: StatusSuccess, Proxy: cached}, nil
-		}
+	// Whitelisted ranges bypass both the cache and the providers, so a
+	// stale cached proxy=true entry can never block a whitelisted ISP.
+	if _, ok := s.whitelisted(addr); ok {
+		return &ResponseModel{Status: StatusSuccess, Proxy: false}, nil
 	}
 
-	s.mu.Lock()
-
-	if time.Now().Before(s.rateLimitReset) {
-		s.mu.Unlock()
+	// Offline blocklists are authoritative and cheap, so they are checked
+	// before the cache and never cost a provider lookup.
+	if label, ok := s.blocklist.Lookup(addr); ok {
+		s.log.Info("VPN check result", "ip", ip, "proxy", true, "blocklist", label)
 
-		return nil, fmt.Errorf("rate limit active, please wait until %v", s.rateLimitReset)
+		return &ResponseModel{Status: StatusSuccess, Proxy: true, Org: label, Provider: blocklistLabel}, nil
 	}
-	s.mu.Unlock()
-
-	var lastErr error
-
-	for attempt := range maxRetries {
-		if s.closed.Load() {
-			return nil, fmt.Errorf("hub is shutting down")
-		}
-
-		if attempt > 0 {
-			time.Sleep(retryDelay * time.Duration(1<<attempt))
-		}
-
-		url := fmt.Sprintf("%s/%s?fields=status,message,proxy,isp,org", s.url, ip)
-		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-
-		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			cancel()
 
-			return nil, fmt.Errorf("failed to create request: %w", err)
-		}
-
-		response, err := s.client.Do(request)
-
-		cancel()
+	// Fast path: fresh cached result
+	s.cache.Seen(ip, time.Now())
+	cached, hasCached := s.cache.Get(ip)
+	if hasCached && !s.cache.Expired(cached, time.Now()) {
+		s.log.Info("VPN check result", "ip", ip, "proxy", cached.Proxy, "cached", true)
 
-		if err != nil {
-			if ErrorIsTemporary(err) {
-				continue
-			}
-
-			lastErr = fmt.Errorf("request failed: %w", err)
+		return entryResponse(cached), nil
+	}
 
-			return nil, lastErr
-		}
+	if s.closed.Load() {
+		return nil, fmt.Errorf("hub is shutting down")
+	}
 
-		// Process the response in a closure so the body is closed at the
-		// end of every iteration, even on continue. The previous version
-		// used `defer response.Body.Close()` directly, which leaked
-		// connections across retries because deferred calls only fire
-		// when the enclosing function returns.
-		result, retry, perAttemptErr := s.handleResponse(ip, response)
-		if perAttemptErr != nil {
-			lastErr = perAttemptErr
-		}
-		if result != nil {
-			return result, nil
-		}
-		if retry {
-			time.Sleep(time.Duration(attempt+1) * retryDelay)
+	v, err := s.detector.Check(ctx, addr)
+	if err != nil {
+		// An expired verdict is still better than failing the login.
+		if hasCached {
+			s.log.Warn("using expired VPN verdict", "ip", ip, "proxy", cached.Proxy, "error", err)
 
-			continue
+			return entryResponse(cached), nil
 		}
 
-		if perAttemptErr != nil {
-			return nil, perAttemptErr
-		}
+		return nil, err
 	}
 
-	if lastErr == nil {
-		lastErr = fmt.Errorf("max retries reached")
+	if s.cache != nil {
+		s.cache.Set(ip, v)
 	}
+	s.log.Info("VPN check result", "ip", ip, "proxy", v.Proxy, "provider", v.Provider)
 
-	return nil, lastErr
+	return &ResponseModel{Status: StatusSuccess, Proxy: v.Proxy, Isp: v.Isp, Org: v.Org, Provider: v.Provider}, nil
 }
 
-// handleResponse decodes a single VPN service response. It returns:
-//   - a non-nil result when CheckIP should return success
-//   - retry=true when the caller should sleep and retry
-//   - a non-nil error to record the most recent failure cause
-func (s *Service) handleResponse(ip string, response *http.Response) (*ResponseModel, bool, error) {
-	defer response.Body.Close()
-
-	s.handleRateLimitHeaders(response.Header)
+// entryResponse converts a cached entry into a response.
+func entryResponse(e Entry) *ResponseModel {
+	return &ResponseModel{Status: StatusSuccess, Proxy: e.Proxy, Isp: e.Isp, Org: e.Org, Provider: e.Provider}
+}
 
-	switch response.StatusCode {
-	case http.StatusOK:
-		var responseModel ResponseModel
-		if err := json.NewDecoder(response.Body).Decode(&responseModel); err != nil {
-			return nil, false, fmt.Errorf("failed to decode response body: %w", err)
+// refreshLoop re-checks expired cache entries of recently seen addresses in
+// the background so players rejoining from a known address rarely wait on
+// a provider, and evicts the entries of addresses that stopped joining.
+// Batches are kept small to stay within provider rate limits.
+func (s *Service) refreshLoop(conf Config) {
+	ticker := time.NewTicker(conf.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
 		}
 
-		if strings.EqualFold(responseModel.Status, "fail") {
-			failMessage := responseModel.Message
-			if strings.EqualFold(failMessage, "reserved range") {
-				if s.cache != nil {
-					s.cache.Set(ip, false)
-				}
-
-				return &ResponseModel{Status: StatusSuccess, Proxy: false}, false, nil
+		now := time.Now()
+		if conf.EvictAfter > 0 {
+			if n := s.cache.Evict(now.Add(-conf.EvictAfter)); n > 0 {
+				s.log.Debug("evicted unseen VPN cache entries", "count", n)
 			}
-
-			return nil, false, fmt.Errorf("query failed: %s", failMessage)
 		}
 
-		if s.cache != nil {
-			s.cache.Set(ip, responseModel.Proxy)
+		var seenSince time.Time
+		if conf.RefreshSeenWithin > 0 {
+			seenSince = now.Add(-conf.RefreshSeenWithin)
 		}
 
-		return &responseModel, false, nil
-	case http.StatusTooManyRequests:
-		return nil, true, fmt.Errorf("rate limited by api")
-	default:
-		return nil, false, fmt.Errorf("unexpected status code: %d", response.StatusCode)
-	}
-}
+		for _, ip := range s.cache.Expiring(now, seenSince, conf.RefreshBatch) {
+			if s.closed.Load() {
+				return
+			}
+
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				continue
+			}
 
-// handleRateLimitHeaders handles the rate limit headers.
-func (s *Service) handleRateLimitHeaders(header http.Header) {
-	requestsRemainingStr := header.Get("X-Rl")
-	timeToResetStr := header.Get("X-Ttl")
+			v, err := s.detector.Check(context.Background(), addr)
+			if err != nil {
+				if errors.Is(err, ErrRateLimited) {
+					break
+				}
+				s.log.Debug("failed to refresh VPN verdict", "ip", ip, "error", err)
 
-	if requestsRemainingStr == "0" && timeToResetStr != "" {
-		ttl, err := strconv.Atoi(timeToResetStr)
-		if err != nil {
-			// couldn't parse header for whatever reason, just default to fallback wait time.
-			ttl = internal.DefaultTTL
+				continue
+			}
+			s.cache.Set(ip, v)
 		}
-
-		s.mu.Lock()
-		s.rateLimitReset = time.Now().Add(time.Duration(ttl) * time.Second)
-		s.mu.Unlock()
-		s.log.Warn("rate limit reached. waiting for reset.", "ttl_seconds", ttl)
 	}
 }
 
 // Stop stops the service and flushes any pending cache writes.
 func (s *Service) Stop() {
-	s.closed.Store(true)
+	if s.closed.Swap(true) {
+		return
+	}
+	close(s.stop)
+	s.overrides.close()
 	if s.cache != nil {
 		s.cache.Stop()
 	}
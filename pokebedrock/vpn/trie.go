@@ -0,0 +1,109 @@
+package vpn
+
+import "net/netip"
+
+// prefixTrie is a binary trie of CIDR ranges answering longest-prefix
+// matches in time proportional to the address length, independent of the
+// number of ranges stored.
+type prefixTrie struct {
+	v4, v6 *trieNode
+	size   int
+}
+
+// trieNode is a node of a prefixTrie. A node with set stores the label of
+// the range ending at it.
+type trieNode struct {
+	children [2]*trieNode
+	label    string
+	set      bool
+}
+
+// newPrefixTrie creates an empty trie.
+func newPrefixTrie() *prefixTrie {
+	return &prefixTrie{v4: &trieNode{}, v6: &trieNode{}}
+}
+
+// root returns the root node for addresses of the family of addr, which
+// must already be unmapped.
+func (t *prefixTrie) root(addr netip.Addr) *trieNode {
+	if addr.Is4() {
+		return t.v4
+	}
+
+	return t.v6
+}
+
+// insert stores the range with the given label. Inserting the same range
+// again replaces its label.
+func (t *prefixTrie) insert(p netip.Prefix, label string) {
+	p = unmapPrefix(p)
+	addr := p.Addr()
+	n := t.root(addr)
+
+	for i := range p.Bits() {
+		b := bitAt(addr, i)
+		if n.children[b] == nil {
+			n.children[b] = &trieNode{}
+		}
+		n = n.children[b]
+	}
+
+	if !n.set {
+		t.size++
+	}
+	n.label, n.set = label, true
+}
+
+// lookup returns the label of the longest range containing addr.
+func (t *prefixTrie) lookup(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	n := t.root(addr)
+
+	var (
+		label string
+		found bool
+	)
+
+	for i := 0; n != nil; i++ {
+		if n.set {
+			label, found = n.label, true
+		}
+		if i == addr.BitLen() {
+			break
+		}
+		n = n.children[bitAt(addr, i)]
+	}
+
+	return label, found
+}
+
+// len returns the number of ranges stored.
+func (t *prefixTrie) len() int {
+	return t.size
+}
+
+// bitAt returns bit i of addr, counting from the most significant bit.
+func bitAt(addr netip.Addr, i int) int {
+	var b []byte
+	if addr.Is4() {
+		a := addr.As4()
+		b = a[:]
+	} else {
+		a := addr.As16()
+		b = a[:]
+	}
+
+	return int(b[i/8]>>(7-i%8)) & 1
+}
+
+// unmapPrefix converts an IPv4-mapped IPv6 range into its IPv4 form so it
+// matches plain IPv4 addresses.
+func unmapPrefix(p netip.Prefix) netip.Prefix {
+	if !p.Addr().Is4In6() {
+		return p.Masked()
+	}
+
+	bits := max(p.Bits()-96, 0)
+
+	return netip.PrefixFrom(p.Addr().Unmap(), bits).Masked()
+}
@@ -0,0 +1,141 @@
+package pokebedrock
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/smell-of-curry/pokebedrock-hub/pokebedrock/vpn"
+)
+
+// requireAuthentication aborts requests that don't carry the Gin
+// authentication key.
+func (poke *PokeBedrock) requireAuthentication(c *gin.Context) {
+	if c.GetHeader("authorization") != poke.conf.Service.GinAuthenticationKey {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+}
+
+// vpnRangeRequest is the body of the whitelist and purge endpoints.
+type vpnRangeRequest struct {
+	CIDR string `json:"cidr" binding:"required"`
+}
+
+// vpnBypassRequest is the body of the bypass grant endpoint.
+type vpnBypassRequest struct {
+	XUID      string `json:"xuid" binding:"required"`
+	Name      string `json:"name"`
+	GrantedBy string `json:"granted_by"`
+}
+
+// registerVpnRoutes registers the endpoints used to manage the VPN
+// whitelist, cache and bypasses at runtime. Changes are persisted and apply
+// to the next login.
+func (poke *PokeBedrock) registerVpnRoutes(router *gin.Engine) {
+	group := router.Group("/vpn", poke.requireAuthentication)
+
+	group.GET("/whitelist", func(c *gin.Context) {
+		configured, runtime := vpn.GlobalService().Whitelist()
+		c.JSON(http.StatusOK, gin.H{"configured": configured, "runtime": runtime})
+	})
+
+	group.POST("/whitelist", func(c *gin.Context) {
+		var req vpnRangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request format", "details": err.Error()})
+
+			return
+		}
+
+		p, added, err := vpn.GlobalService().AddWhitelist(req.CIDR)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"cidr": p.String(), "added": added})
+	})
+
+	group.DELETE("/whitelist", func(c *gin.Context) {
+		var req vpnRangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request format", "details": err.Error()})
+
+			return
+		}
+
+		p, removed, err := vpn.GlobalService().RemoveWhitelist(req.CIDR)
+		switch {
+		case errors.Is(err, vpn.ErrConfigured):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case err != nil:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case !removed:
+			c.JSON(http.StatusNotFound, gin.H{"error": "range is not whitelisted"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"cidr": p.String(), "removed": true})
+		}
+	})
+
+	group.GET("/lookup/:ip", func(c *gin.Context) {
+		res, err := vpn.GlobalService().Lookup(c.Param("ip"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	})
+
+	group.POST("/purge", func(c *gin.Context) {
+		var req vpnRangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request format", "details": err.Error()})
+
+			return
+		}
+
+		n, err := vpn.GlobalService().Purge(req.CIDR)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"purged": n})
+	})
+
+	group.GET("/bypass", func(c *gin.Context) {
+		c.JSON(http.StatusOK, vpn.GlobalService().Bypasses())
+	})
+
+	group.POST("/bypass", func(c *gin.Context) {
+		var req vpnBypassRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request format", "details": err.Error()})
+
+			return
+		}
+
+		if err := vpn.GlobalService().GrantBypass(req.XUID, req.Name, req.GrantedBy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "granted"})
+	})
+
+	group.DELETE("/bypass/:xuid", func(c *gin.Context) {
+		if !vpn.GlobalService().RevokeBypass(c.Param("xuid")) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no bypass for xuid"})
+
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+	})
+}